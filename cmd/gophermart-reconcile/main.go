@@ -0,0 +1,92 @@
+// Сверка балансов накопительной системы лояльности «Гофермарт» с начислениями по заказам и списаниями
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
+)
+
+func init() {
+	// настройка обработки денежных единиц
+	decimal.MarshalJSONWithoutQuotes = true
+	decimal.DivisionPrecision = 2
+	// настройка логгирования
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006/01/02 15:04:05"})
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+}
+
+func main() {
+	// описываем флаги
+	dlinkFlag := flag.String("d", settings.DefDBlink, "Database URI link")
+	fixFlag := flag.Bool("fix", false, "Fix balances that differ from orders accruals and withdrawals")
+	loginFlag := flag.String("login", "", "Reconcile balance of this login only")
+	flag.DurationVar(&settings.ReconcileFixTimeout, "fix-timeout", settings.ReconcileFixTimeout, "Balance fix timeout for each login")
+	// парсим флаги в переменные
+	flag.Parse()
+	// проверяем наличие переменной окружения, если ее нет, то используем значение из флага
+	dlink, ok := os.LookupEnv("DATABASE_URI")
+	if !ok {
+		dlink = *dlinkFlag
+	}
+	// создаем хранилище через конструктор
+	s := storage.NewSQLStorage(dlink)
+	if s == nil {
+		log.Print("database is not available: ", settings.ColorRed, dlink, settings.ColorReset)
+		os.Exit(2)
+	}
+	defer s.ConnectionClose()
+	// создаем контекст сверки и оснащаем его таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	defer cancel()
+	// получаем балансы с расхождениями
+	drifts, err := s.ReconcileBalances(ctx)
+	if err != nil {
+		log.Print("balances reconciliation error: ", settings.ColorRed, err, settings.ColorReset)
+		os.Exit(2)
+	}
+	// количество найденных и неисправленных расхождений
+	var found, left int
+	for _, dc := range drifts {
+		if *loginFlag != "" && dc.Login != *loginFlag {
+			continue
+		}
+		found++
+		log.Printf("login %s%s%s balance drift: stored current %s withdrawn %s, expected current %s withdrawn %s",
+			settings.ColorYellow, dc.Login, settings.ColorReset,
+			dc.Stored.Current, dc.Stored.Withdrawn, dc.Expected.Current, dc.Expected.Withdrawn)
+		// исправляем расхождение, если задан флаг
+		if !*fixFlag {
+			left++
+			continue
+		}
+		if err := fixBalance(s, dc); err != nil {
+			log.Print("balance fix error for login ", dc.Login, ": ", settings.ColorRed, err, settings.ColorReset)
+			left++
+			continue
+		}
+		log.Print("balance fixed for login ", settings.ColorGreen, dc.Login, settings.ColorReset)
+	}
+	log.Printf("balances reconciliation finished: drifts found %d, not fixed %d", found, left)
+	// ненулевой код возврата, если остались расхождения
+	if left > 0 {
+		os.Exit(1)
+	}
+}
+
+// исправление баланса одного пользователя с отдельным таймаутом,
+// чтобы долгое исправление одного баланса не исчерпывало время исправления остальных
+func fixBalance(s *storage.StorageSQL, dc models.BalanceDrift) error {
+	// создаем контекст и оснащаем его таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.ReconcileFixTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	return s.FixBalance(ctx, dc)
+}
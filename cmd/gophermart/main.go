@@ -2,13 +2,14 @@
 package main
 
 import (
-	"container/list"
 	"context"
+	"errors"
 	"flag"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,6 +17,9 @@ import (
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprouter"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/jwtkeys"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/notifier"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/storage"
@@ -46,6 +50,12 @@ func main() {
 		return
 	}
 	BaseURL.Path = "/api/orders"
+	// набор ключей подписи токенов, без заданных ключей сервис не запускается
+	keys, err := newKeySet()
+	if err != nil {
+		log.Print("jwt keys loading error: ", settings.ColorRed, err, settings.ColorReset)
+		os.Exit(1)
+	}
 	// инициализируем конструкторы
 	// конструкторы хранилища
 	storage := newStrorageProvider(dlink)
@@ -53,25 +63,34 @@ func main() {
 	httpReq := httprequest.NewHTTPRequst(BaseURL)
 	// создаем тикер для обработки задач из очереди
 	ticker := time.NewTicker(settings.RequestsTimeout)
-	// создаем очередь для задач воркер пула апдейта статусов заказов
-	queue := list.New()
 	// опередяляем контекст уведомления о сигнале прерывания
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	// создаем группу синхранизации выполнения горутин
 	var wg sync.WaitGroup
-	// создаем воркер пул для обработки задач очереди
-	pool := workerpool.NewPool(queue, settings.WorkersQty, ticker, storage, calcSys, &wg, httpReq)
+	// создаем воркер пул для обработки задач очереди в хранилище
+	pool := workerpool.NewPool(settings.WorkersQty, ticker, storage, calcSys, &wg, httpReq)
+	// восстанавливаем очередь задач для заказов с нефинальным статусом
+	recoverTasks(ctx, storage, pool)
+	handlerKeys := handlers.NewKeysHandler(keys)
 	// конструкторы структур User
-	serviceUser := services.NewUserService(storage)
+	serviceUser := services.NewUserService(storage, keys, notifier.NewLogNotifier())
 	handlerUser := handlers.NewUserHandler(serviceUser)
 	//конструкторы структур Order
-	serviceOrder := services.NewOrderService(storage, pool, httpReq)
+	serviceOrder := services.NewOrderService(storage, pool)
 	handlerOrder := handlers.NewOrderHandler(serviceOrder)
 	// конструкторы структур Balance
 	serviceBalance := services.NewBalanceService(storage)
 	handlerBalance := handlers.NewBalanceHandler(serviceBalance)
+	// конструкторы структур Idempotency
+	serviceIdempotency := services.NewIdempotencyService(storage)
+	handlerIdempotency := handlers.NewIdempotencyHandler(serviceIdempotency)
+	// конструкторы структур Admin
+	serviceAdmin := services.NewAdminService(storage, pool)
+	handlerAdmin := handlers.NewAdminHandler(serviceAdmin)
+	// назначаем роль admin пользователям из настроек
+	promoteAdmins(ctx, serviceAdmin)
 	// конструктор роутера
-	r := httprouter.NewRouter(handlerUser, handlerOrder, handlerBalance)
+	r := httprouter.NewRouter(handlerUser, handlerOrder, handlerBalance, handlerIdempotency, handlerKeys, handlerAdmin)
 	// запускаем сервер
 	log.Print("accruals calculation service URL: ", settings.ColorGreen, calcSys, settings.ColorReset)
 	log.Print("starting http server on: ", settings.ColorBlue, addr, settings.ColorReset)
@@ -104,6 +123,24 @@ func flagsVars() (dlink string, calcSys string, addr string) {
 	addrFlag := flag.String("a", settings.DefServAddr, "HTTP Server address")
 	calcSysFlag := flag.String("r", settings.DefCalcSysURL, "Accruals calculation service URL")
 	dlinkFlag := flag.String("d", settings.DefDBlink, "Database URI link")
+	flag.Float64Var(&settings.AccrualRPS, "accrual-rps", settings.AccrualRPS, "Accruals calculation service requests per second limit, 0 - unlimited")
+	flag.IntVar(&settings.AccrualBurst, "accrual-burst", settings.AccrualBurst, "Accruals calculation service requests burst size")
+	flag.DurationVar(&settings.IdempotencyWindow, "idempotency-window", settings.IdempotencyWindow, "Idempotency-Key responses retention window")
+	flag.DurationVar(&settings.IdempotencyLease, "idempotency-lease", settings.IdempotencyLease, "Idempotency-Key reservation lease for requests without saved result, not less than request timeout")
+	flag.IntVar(&settings.RecoveryBatchSize, "recovery-batch", settings.RecoveryBatchSize, "Orders batch size for task queue recovery")
+	flag.DurationVar(&settings.RecoveryMaxAge, "recovery-max-age", settings.RecoveryMaxAge, "Max age of orders for task queue recovery, 0 - unlimited")
+	flag.StringVar(&settings.JWTKeysFile, "jwt-keys", settings.JWTKeysFile, "JWT signing keys file in JWK Set or PEM format")
+	flag.StringVar(&settings.JWTActiveKID, "jwt-kid", settings.JWTActiveKID, "JWT active signing key ID, default - first key of the set")
+	flag.StringVar(&settings.JWTSecret, "jwt-secret", settings.JWTSecret, "JWT HS256 signing secret if keys file is not set")
+	flag.BoolVar(&settings.JWTDevRandomKey, "jwt-dev-random-key", settings.JWTDevRandomKey, "Development only: sign JWT with random key if no keys are set, tokens are invalid after restart")
+	flag.IntVar(&settings.LoginMaxFailures, "login-max-failures", settings.LoginMaxFailures, "Failed login attempts per login before lockout, 0 - no lockout")
+	flag.IntVar(&settings.LoginIPMaxFailures, "login-ip-max-failures", settings.LoginIPMaxFailures, "Failed login attempts per client IP before lockout, 0 - no lockout")
+	flag.DurationVar(&settings.LoginFailureDelay, "login-failure-delay", settings.LoginFailureDelay, "Delay before next login attempt after a failure below lockout threshold, doubled on every next failure, 0 - no delay")
+	flag.DurationVar(&settings.LoginLockout, "login-lockout", settings.LoginLockout, "First login lockout duration, doubled on every next failure")
+	flag.DurationVar(&settings.LoginLockoutMax, "login-lockout-max", settings.LoginLockoutMax, "Max login lockout duration")
+	flag.BoolVar(&settings.TrustProxyHeaders, "trust-proxy", settings.TrustProxyHeaders, "Use X-Forwarded-For and X-Real-IP headers as client IP")
+	adminsFlag := flag.String("admins", "", "Comma separated logins to be granted admin role on startup")
+	flag.BoolVar(&settings.RecoveryDryRun, "recovery-dry-run", settings.RecoveryDryRun, "Log orders for task queue recovery without enqueueing")
 	// парсим флаги в переменные
 	flag.Parse()
 	// проверяем наличие переменной окружения, если ее нет или она не валидна, то используем значение из флага
@@ -124,9 +161,61 @@ func flagsVars() (dlink string, calcSys string, addr string) {
 		log.Print("eviroment variable DATABASE_URI is not exist", dlink)
 		dlink = *dlinkFlag
 	}
+	// переменные окружения ключей подписи токенов имеют приоритет над флагами
+	if v, ok := os.LookupEnv("JWT_KEYS_FILE"); ok && v != "" {
+		settings.JWTKeysFile = v
+	}
+	if v, ok := os.LookupEnv("JWT_ACTIVE_KID"); ok && v != "" {
+		settings.JWTActiveKID = v
+	}
+	if v, ok := os.LookupEnv("JWT_SECRET"); ok && v != "" {
+		settings.JWTSecret = v
+	}
+	// переменная окружения списка администраторов имеет приоритет над флагом
+	admins := *adminsFlag
+	if v, ok := os.LookupEnv("ADMIN_LOGINS"); ok && v != "" {
+		admins = v
+	}
+	for _, login := range strings.Split(admins, ",") {
+		if login = strings.TrimSpace(login); login != "" {
+			settings.AdminLogins = append(settings.AdminLogins, login)
+		}
+	}
 	return dlink, calcSys, addr
 }
 
+// newKeySet создает набор ключей подписи токенов из файла или из секретного ключа,
+// без заданных ключей возвращает ошибку, случайный ключ используется только с флагом разработки
+func newKeySet() (*jwtkeys.KeySet, error) {
+	switch {
+	case settings.JWTKeysFile != "":
+		keys, err := jwtkeys.Load(settings.JWTKeysFile, settings.JWTActiveKID)
+		if err != nil {
+			return nil, err
+		}
+		log.Print("jwt signing keys loaded from file, active key: ", settings.ColorGreen, keys.KeyID(), settings.ColorReset)
+		return keys, nil
+	case settings.JWTSecret != "":
+		return jwtkeys.NewHMAC([]byte(settings.JWTSecret))
+	case settings.JWTDevRandomKey:
+		log.Print(settings.ColorRed, "WARNING: jwt signing keys are not set, random development key is used, tokens will be invalid after restart and on other instances, do not use in production", settings.ColorReset)
+		return jwtkeys.Generate()
+	default:
+		return nil, errors.New("jwt signing keys are not set: use -jwt-keys / JWT_KEYS_FILE or -jwt-secret / JWT_SECRET")
+	}
+}
+
+// promoteAdmins назначает роль admin пользователям из настроек, пользователи должны быть зарегистрированы
+func promoteAdmins(ctx context.Context, svc *services.AdminService) {
+	for _, login := range settings.AdminLogins {
+		if err := svc.SetRole(ctx, login, models.RoleAdmin); err != nil {
+			log.Print("admin role assignment error for login ", login, ": ", settings.ColorRed, err, settings.ColorReset)
+			continue
+		}
+		log.Print("admin role assigned to login: ", settings.ColorGreen, login, settings.ColorReset)
+	}
+}
+
 // newStrorageProvider создает структуру хранилища
 func newStrorageProvider(dlink string) (s *storage.StorageSQL) {
 	// проверяем если переменная SQL url не пустая, логгируем
@@ -139,6 +228,42 @@ func newStrorageProvider(dlink string) (s *storage.StorageSQL) {
 	return s
 }
 
+// recoverTasks повторно ставит в очередь пула воркеров все заказы с нефинальным статусом,
+// кроме заказов с исчерпанным бюджетом попыток, опрос которых возобновляется только администратором
+func recoverTasks(ctx context.Context, s *storage.StorageSQL, pool *workerpool.Pool) {
+	// проверяем размер пачки, иначе восстановление не завершится
+	if settings.RecoveryBatchSize <= 0 {
+		log.Print("task queue recovery skipped, wrong batch size: ", settings.ColorRed, settings.RecoveryBatchSize, settings.ColorReset)
+		return
+	}
+	// номер последнего обработанного заказа и количество восстановленных задач
+	var after string
+	var count int
+	for {
+		// получаем очередную пачку заказов
+		tasks, err := s.PendingOrders(ctx, after, settings.RecoveryMaxAge, settings.RecoveryBatchSize)
+		if err != nil {
+			log.Print("task queue recovery error: ", settings.ColorRed, err, settings.ColorReset)
+			return
+		}
+		for _, task := range tasks {
+			// в режиме dry-run только логируем
+			if settings.RecoveryDryRun {
+				log.Printf("task queue recovery dry-run: login %s order %s", task.Login, task.OrderNum)
+			} else {
+				pool.AppendTask(task.Login, task.OrderNum)
+			}
+			after = task.OrderNum
+			count++
+		}
+		// последняя пачка
+		if len(tasks) < settings.RecoveryBatchSize {
+			break
+		}
+	}
+	log.Print("task queue recovery finished, orders found: ", settings.ColorGreen, count, settings.ColorReset)
+}
+
 // httpServerShutdown реализует gracefull shutdown для ListenAndServe
 func httpServerShutdown(ctx context.Context, wg *sync.WaitGroup, srv *http.Server) {
 	// получаем сигнал о завершении приложения
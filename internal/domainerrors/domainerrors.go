@@ -0,0 +1,69 @@
+// пакет ошибок предметной области: хранилище и сервисы возвращают ошибки пакета,
+// обработчики запросов выбирают ответ по ним через errors.Is, не разбирая текст ошибки
+package domainerrors
+
+import "errors"
+
+// категории ошибок, errors.Is(err, категория) верно для всех ошибок категории
+var (
+	// ErrNotFound - запись не найдена
+	ErrNotFound = errors.New("not found")
+	// ErrEmptyList - в списке нет записей
+	ErrEmptyList = errors.New("empty list")
+)
+
+// ошибки отсутствия записей
+var (
+	ErrLoginNotFound        error = &kindError{"login not exist", ErrNotFound}
+	ErrOrderNotFound        error = &kindError{"order not exist", ErrNotFound}
+	ErrRefreshTokenNotFound error = &kindError{"refresh token not exist", ErrNotFound}
+	ErrResetTokenNotFound   error = &kindError{"reset token not exist", ErrNotFound}
+)
+
+// ошибки пустых списков
+var (
+	ErrNoOrders  error = &kindError{"no orders for this login", ErrEmptyList}
+	ErrNoRecords error = &kindError{"no records", ErrEmptyList}
+)
+
+// ошибки пользователя и аутентификации
+var (
+	ErrLoginExists        = errors.New("login exist")
+	ErrInvalidCredentials = errors.New("login or password not exist")
+	ErrWrongPassword      = errors.New("wrong old password")
+)
+
+// ошибки заказов
+var (
+	// ErrOrderLoaded - заказ уже загружен этим пользователем
+	ErrOrderLoaded = errors.New("order number from this login already exist")
+	// ErrOrderOwnedByOther - заказ загружен другим пользователем
+	ErrOrderOwnedByOther = errors.New("the same order number was loaded by another customer")
+	// ErrOrderFinal - статус заказа окончательный, повторный опрос не нужен
+	ErrOrderFinal = errors.New("order status is final")
+	// ErrPollInProgress - заказ опрашивается воркером, повторный опрос поставить нельзя
+	ErrPollInProgress = errors.New("order poll is in progress")
+)
+
+// ошибки баланса
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrWithdrawalExists - списание в счет этого заказа уже выполнено
+	ErrWithdrawalExists = errors.New("new order number already exist")
+	// ErrBalanceChanged - баланс изменился между сверкой и исправлением
+	ErrBalanceChanged = errors.New("balance changed after reconciliation")
+)
+
+// ошибка с категорией: текст ошибки свой, errors.Is верно и для категории
+type kindError struct {
+	msg  string
+	kind error
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
@@ -0,0 +1,79 @@
+package domainerrors__test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainErrors_Kinds(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name     string
+		err      error
+		kind     error
+		expected bool
+	}{
+		// определяем все тесты
+		{
+			name:     "login not found is not found",
+			err:      domainerrors.ErrLoginNotFound,
+			kind:     domainerrors.ErrNotFound,
+			expected: true,
+		},
+		{
+			name:     "wrapped order not found is not found",
+			err:      fmt.Errorf("order diagnostics: %w", domainerrors.ErrOrderNotFound),
+			kind:     domainerrors.ErrNotFound,
+			expected: true,
+		},
+		{
+			name:     "wrapped order not found is order not found",
+			err:      fmt.Errorf("order diagnostics: %w", domainerrors.ErrOrderNotFound),
+			kind:     domainerrors.ErrOrderNotFound,
+			expected: true,
+		},
+		{
+			name:     "no records is empty list",
+			err:      domainerrors.ErrNoRecords,
+			kind:     domainerrors.ErrEmptyList,
+			expected: true,
+		},
+		{
+			name:     "no orders is not not found",
+			err:      domainerrors.ErrNoOrders,
+			kind:     domainerrors.ErrNotFound,
+			expected: false,
+		},
+		{
+			name:     "login not found is not order not found",
+			err:      domainerrors.ErrLoginNotFound,
+			kind:     domainerrors.ErrOrderNotFound,
+			expected: false,
+		},
+		{
+			name:     "error with the same text is not domain error",
+			err:      errors.New("insufficient funds"),
+			kind:     domainerrors.ErrInsufficientFunds,
+			expected: false,
+		},
+	}
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, errors.Is(tCase.err, tCase.kind))
+		})
+	}
+}
+
+func TestDomainErrors_Messages(t *testing.T) {
+	// текст ошибок сохраняется для журналов и ответов
+	assert.EqualError(t, domainerrors.ErrLoginNotFound, "login not exist")
+	assert.EqualError(t, domainerrors.ErrNoOrders, "no orders for this login")
+	assert.EqualError(t, domainerrors.ErrOrderOwnedByOther, "the same order number was loaded by another customer")
+}
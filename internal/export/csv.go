@@ -0,0 +1,36 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+// CSVWriter - запись таблицы в формате CSV с разделителем запятая
+type CSVWriter struct {
+	w   *csv.Writer
+	loc *time.Location
+}
+
+// NewCSVWriter - конструктор записи таблицы в формате CSV
+func NewCSVWriter(w io.Writer, loc *time.Location) *CSVWriter {
+	return &CSVWriter{
+		w:   csv.NewWriter(w),
+		loc: loc,
+	}
+}
+
+// Write пишет строку таблицы
+func (cw *CSVWriter) Write(row ...interface{}) (err error) {
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = formatText(v, cw.loc)
+	}
+	return cw.w.Write(record)
+}
+
+// Close дописывает буферизованные строки
+func (cw *CSVWriter) Close() (err error) {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,70 @@
+// пакет выгрузки табличных данных в файлы CSV и XLSX с записью строк по мере получения
+package export
+
+import (
+	"errors"
+	"io"
+	"time"
+	// база часовых поясов встроена в приложение, выбор часового пояса не зависит от системы
+	_ "time/tzdata"
+
+	"github.com/shopspring/decimal"
+)
+
+// форматы файлов выгрузки
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// Formats - поддерживаемые форматы файлов выгрузки
+var Formats = []string{FormatCSV, FormatXLSX}
+
+// типы содержимого файлов выгрузки
+const (
+	ContentTypeCSV  = "text/csv"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Writer - запись таблицы построчно: первой строкой пишутся заголовки колонок
+// значения ячеек: string, decimal.Decimal и time.Time
+type Writer interface {
+	Write(row ...interface{}) (err error)
+	Close() (err error)
+}
+
+// NewWriter - конструктор записи таблицы в формате format, время в ячейках приводится к часовому поясу loc
+// sheet - имя листа для форматов с листами
+func NewWriter(w io.Writer, format string, loc *time.Location, sheet string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w, loc), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w, loc, sheet), nil
+	default:
+		return nil, errors.New("unsupported export format")
+	}
+}
+
+// ContentType - тип содержимого файла выгрузки в формате format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return ContentTypeXLSX
+	}
+	return ContentTypeCSV + "; charset=utf-8"
+}
+
+// текстовое представление значения ячейки для форматов без типов ячеек
+// суммы пишутся с двумя знаками после точки, время - в формате RFC 3339 в часовом поясе loc
+func formatText(v interface{}, loc *time.Location) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case decimal.Decimal:
+		return v.StringFixed(2)
+	case time.Time:
+		return v.In(loc).Format(time.RFC3339)
+	default:
+		return ""
+	}
+}
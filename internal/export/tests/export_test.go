@@ -0,0 +1,79 @@
+package export__test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/export"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport_CSV(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	ew, err := export.NewWriter(&b, export.FormatCSV, loc, "orders")
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Write("number", "accrual", "uploaded_at"))
+	assert.NoError(t, ew.Write("9278923470", decimal.RequireFromString("500.5"), time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC)))
+	assert.NoError(t, ew.Write("a,\"b\"", decimal.Zero, time.Date(2020, time.December, 31, 22, 0, 0, 0, time.UTC)))
+	assert.NoError(t, ew.Close())
+	// суммы с двумя знаками, время в выбранном часовом поясе, экранирование по RFC 4180
+	expected := "number,accrual,uploaded_at\n" +
+		"9278923470,500.50,2020-05-15T20:45:12+03:00\n" +
+		"\"a,\"\"b\"\"\",0.00,2021-01-01T01:00:00+03:00\n"
+	assert.Equal(t, expected, b.String())
+}
+
+func TestExport_XLSX(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+	var b bytes.Buffer
+	ew, err := export.NewWriter(&b, export.FormatXLSX, loc, "orders")
+	assert.NoError(t, err)
+	assert.NoError(t, ew.Write("number", "accrual", "uploaded_at"))
+	assert.NoError(t, ew.Write("<9278923470>", decimal.RequireFromString("500.5"), time.Date(2020, time.May, 15, 3, 0, 0, 0, time.UTC)))
+	assert.NoError(t, ew.Close())
+	// книга читается как архив zip со всеми частями
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	assert.NoError(t, err)
+	parts := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		assert.NoError(t, err)
+		rc.Close()
+		parts[f.Name] = string(content)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		assert.Contains(t, parts, name)
+	}
+	assert.Contains(t, parts["xl/workbook.xml"], `<sheet name="orders"`)
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	// заголовки и текст - строки, сумма - число, время - дата в выбранном часовом поясе: 2020-05-15 12:00
+	assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t>number</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="A2" t="inlineStr"><is><t>&lt;9278923470&gt;</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="B2" s="1"><v>500.5</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2" s="2"><v>43966.5</v></c>`)
+	assert.Contains(t, sheet, `</sheetData></worksheet>`)
+}
+
+func TestExport_XLSXEmpty(t *testing.T) {
+	// книга без строк содержит пустой лист
+	var b bytes.Buffer
+	ew := export.NewXLSXWriter(&b, time.UTC, "withdrawals")
+	assert.NoError(t, ew.Close())
+	zr, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	assert.NoError(t, err)
+	assert.Len(t, zr.File, 6)
+}
+
+func TestExport_UnsupportedFormat(t *testing.T) {
+	_, err := export.NewWriter(io.Discard, "pdf", time.UTC, "orders")
+	assert.Error(t, err)
+}
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+
+	"github.com/rs/zerolog/log"
+)
+
+// интерфейс методов бизнес логики type Admin
+type AdminServiceProvider interface {
+	User(ctx context.Context, login string) (ec models.AdminUser, err error)
+	Order(ctx context.Context, orderNum string) (ec models.AdminOrder, err error)
+	Orders(ctx context.Context, login string) (ec []models.OrdersList, err error)
+	Balance(ctx context.Context, login string) (ec models.BalanceCheck, err error)
+	Withdrawals(ctx context.Context, login string) (ec []models.WithdrawalsList, err error)
+	SetRole(ctx context.Context, login string, role string) (err error)
+	NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error)
+	History(ctx context.Context, login string) (ec []models.LedgerEntry, err error)
+	OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error)
+	Repoll(ctx context.Context, orderNum string) (err error)
+}
+
+// структура для конструктура обработчика Admin
+type AdminHandler struct {
+	service AdminServiceProvider
+}
+
+// конструктор обработчика Admin
+func NewAdminHandler(hAdmin AdminServiceProvider) *AdminHandler {
+	return &AdminHandler{
+		hAdmin,
+	}
+}
+
+// RequireRole - middleware проверки роли пользователя в claims токена доступа
+// подключается после Authenticator, запросы пользователей с другой ролью получают 403
+// claim role актуален: токены, выданные до смены роли, отклоняются RevocationCheck
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, claims, err := jwtauth.FromContext(r.Context())
+			if err != nil {
+				writeProblem(w, r, problemUnauthorized, "")
+				return
+			}
+			// токены, выданные до введения ролей, не содержат claim role
+			if tokenRole, _ := claims["role"].(string); tokenRole != role {
+				login, _ := claims["login"].(string)
+				log.Printf("access denied for login %s with role %q, required role %q", login, tokenRole, role)
+				writeProblem(w, r, problemForbidden, "role "+role+" required")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// получение карточки пользователя
+func (handler AdminHandler) User(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.User(ctx, chi.URLParam(r, "login"))
+	// 200 - при ошибке nil, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// получение заказа по номеру
+func (handler AdminHandler) Order(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.Order(ctx, chi.URLParam(r, "number"))
+	// 200 - при ошибке nil, 404 - при ошибке "order not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// получение диагностики обработки заказа
+func (handler AdminHandler) OrderDiagnostics(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.OrderDiagnostics(ctx, chi.URLParam(r, "number"))
+	// 200 - при ошибке nil, 404 - при ошибке "order not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// постановка заказа в очередь для немедленного опроса внешнего сервиса
+func (handler AdminHandler) Repoll(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	err := handler.service.Repoll(ctx, chi.URLParam(r, "number"))
+	// 202 - при ошибке nil, 404 - при ошибке "order not exist", 409 - при ошибке "order status is final",
+	// 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// получение списка заказов пользователя
+func (handler AdminHandler) Orders(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.Orders(ctx, chi.URLParam(r, "login"))
+	// 200 - при ошибке nil, 204 - при отсутствии заказов, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// получение баланса пользователя со сверкой по журналу операций
+func (handler AdminHandler) Balance(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.Balance(ctx, chi.URLParam(r, "login"))
+	// 200 - при ошибке nil, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// получение списка списаний пользователя
+func (handler AdminHandler) Withdrawals(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.Withdrawals(ctx, chi.URLParam(r, "login"))
+	// 200 - при ошибке nil, 204 - при отсутствии списаний, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// смена роли пользователя
+func (handler AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// десериализация тела запроса
+	dc := models.DecodeRole{}
+	err := json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil {
+		log.Printf("unmarshal error HandlerSetRole: %s", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	err = handler.service.SetRole(ctx, chi.URLParam(r, "login"), dc.Role)
+	// 200 - при ошибке nil, 400 - при неизвестной роли, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ручная корректировка баланса пользователя, в ответе баланс после корректировки
+func (handler AdminHandler) NewAdjustment(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем логин администратора из claims
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerNewAdjustment: %s", err)
+		writeProblem(w, r, problemInternal, "adjustment handling error")
+		return
+	}
+	admin, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerNewAdjustment: %v", err)
+		writeProblem(w, r, problemInternal, "adjustment handling error")
+		return
+	}
+	// десериализация тела запроса
+	dc := models.NewAdjustment{}
+	err = json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil {
+		log.Printf("unmarshal error HandlerNewAdjustment: %s", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	dc.CreatedBy = admin
+	ec, err := handler.service.NewAdjustment(ctx, chi.URLParam(r, "login"), dc)
+	// 200 - при ошибке nil, 400 - при нарушении правил, 402 - при ошибке "insufficient funds",
+	// 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// получение истории операций пользователя с авторами ручных корректировок
+func (handler AdminHandler) History(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	ec, err := handler.service.History(ctx, chi.URLParam(r, "login"))
+	// 200 - при ошибке nil, 204 - при отсутствии операций, 404 - при ошибке "login not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// сериализация тела ответа со статусом 200
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	// устанавливаем заголовок
+	w.Header().Set("Content-Type", "application/json")
+	//устанавливаем статус-код 200
+	w.WriteHeader(http.StatusOK)
+	// сериализуем и пишем тело ответа
+	json.NewEncoder(w).Encode(v)
+}
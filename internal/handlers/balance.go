@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
-	"strings"
 
 	"github.com/ShiraazMoollatjie/goluhn"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
@@ -18,7 +17,9 @@ import (
 type BalanceServiceProvider interface {
 	Status(ctx context.Context, login string) (ec models.LoginBalance, err error)
 	NewWithdrawal(ctx context.Context, login string, dc models.NewWithdrawal) (err error)
-	WithdrawalsList(ctx context.Context, login string) (ec []models.WithdrawalsList, err error)
+	WithdrawalsList(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.WithdrawalsList, next string, err error)
+	WithdrawalsEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.WithdrawalsList) error) (err error)
+	History(ctx context.Context, login string) (ec []models.LedgerEntry, err error)
 }
 
 // структура для конструктура обработчика Balance
@@ -43,29 +44,24 @@ func (handler BalanceHandler) Status(w http.ResponseWriter, r *http.Request) {
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("FromContext error HandlerStatus: %s", err)
-		http.Error(w, "balance handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "balance handling error")
 		return
 	}
 	// получаем значение login из интерфейса
 	login, ok := claims["login"].(string)
 	if !ok {
 		log.Printf("interface assertion error HandlerStatus: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
-	// устанавливаем заголовок
-	w.Header().Set("Content-Type", "application/json")
 	// отпарвляем запрос серсис и получаем структуру с суммой текущего баланса и суммой списаний и ошибку
 	ec, err := handler.service.Status(ctx, login)
 	// 200 - при ошибке nil, 500 - при иных ошибках сервиса
 	switch {
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
-		//устанавливаем статус-код 200
-		w.WriteHeader(http.StatusOK)
-		// сериализуем и пишем тело ответа
-		json.NewEncoder(w).Encode(ec)
+		writeJSON(w, ec)
 	}
 }
 
@@ -80,27 +76,28 @@ func (handler BalanceHandler) NewWithdrawal(w http.ResponseWriter, r *http.Reque
 	err := json.NewDecoder(r.Body).Decode(&dc)
 	if err != nil {
 		log.Printf("unmarshal error HandlerNewWithdrawal: %s", err)
-		http.Error(w, "invalid JSON structure received", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
 		return
 	}
 	// проверяем на алгоритм Луна, если не ок, возвращаем 422
 	err = goluhn.Validate(dc.Order)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		log.Printf("luhn algo check HandlerNewWithdrawal error :%s", err)
+		writeProblem(w, r, problemInvalidOrderNumber, "order number failed Luhn check")
 		return
 	}
 	// получаем значение login из контекста запроса
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("FromContext error HandlerNewWithdrawal: %s", err)
-		http.Error(w, "balance handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "balance handling error")
 		return
 	}
 	// получаем значение из интерфейса
 	login, ok := claims["login"].(string)
 	if !ok {
 		log.Printf("interface assertion error HandlerNewWithdrawal: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
 	// отпправляем на списание
@@ -108,12 +105,8 @@ func (handler BalanceHandler) NewWithdrawal(w http.ResponseWriter, r *http.Reque
 	// 200 - при ошибке nil, 500 - при иных ошибках сервиса, 422 - проверка Луна не ок
 	// 402 - если получена ошибка "insufficient funds"
 	switch {
-	case err != nil && strings.Contains(err.Error(), "insufficient funds"):
-		w.WriteHeader(http.StatusPaymentRequired)
-	case err != nil && strings.Contains(err.Error(), "new order number already exist"):
-		w.WriteHeader(http.StatusUnprocessableEntity)
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
 		w.WriteHeader(http.StatusOK)
 	}
@@ -129,30 +122,122 @@ func (handler BalanceHandler) WithdrawalsList(w http.ResponseWriter, r *http.Req
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("FromContext error HandlerWithdrawalsList: %s", err)
-		http.Error(w, "balance handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "balance handling error")
 		return
 	}
 	// получаем значение из интерфейса
 	login, ok := claims["login"].(string)
 	if !ok {
 		log.Printf("interface assertion error HandlerNewWithdrawal: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
-	// устанавливаем заголовок
-	w.Header().Set("Content-Type", "application/json")
-	// отпаряляем запрос в серсис, получаем слайс структур списаний и ошибку
-	ec, err := handler.service.WithdrawalsList(ctx, login)
-	// 200 - при ошибке nil, кодирование, 500 - при иных ошибках сервиса, 204 - если получена ошибка "no records"
+	// устанавливаем заголовок, списания выдаются массивом JSON или NDJSON по заголовку Accept
+	le := newListEncoder(w, r)
+	dc := decodeListQuery(r)
+	if dc.Limit == "" {
+		// весь список выдается потоком по мере чтения списаний из хранилища
+		err = handler.service.WithdrawalsEach(ctx, login, dc, func(v models.WithdrawalsList) error {
+			return le.Encode(v)
+		})
+	} else {
+		// страница ограничена по размеру и собирается в памяти для ссылки на следующую страницу
+		var ec []models.WithdrawalsList
+		var next string
+		ec, next, err = handler.service.WithdrawalsList(ctx, login, dc)
+		if err == nil {
+			// ссылка на следующую страницу
+			setNextPage(w, r, next)
+			for _, v := range ec {
+				if err = le.Encode(v); err != nil {
+					break
+				}
+			}
+		}
+	}
+	// 200 - при ошибке nil, 204 - при отсутствии списаний или ошибке "no records", 400 - при неверных параметрах выборки,
+	// 500 - при иных ошибках сервиса, после начала выдачи ошибка только логируется
 	switch {
-	case err != nil && strings.Contains(err.Error(), "no records"):
+	case le.Started() && err != nil:
+		log.Printf("withdrawals list streaming error HandlerWithdrawalsList: %s", err)
+	case le.Started():
+		le.Close()
+	case err != nil:
+		writeError(w, r, err)
+	default:
 		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// получение истории операций по счёту пользователя
+func (handler BalanceHandler) History(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем значение claims из контекста запроса
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerHistory: %s", err)
+		writeProblem(w, r, problemInternal, "balance handling error")
+		return
+	}
+	// получаем значение login из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerHistory: %s", err)
+		writeProblem(w, r, problemInternal, "balance handling error")
+		return
+	}
+	// направляем запрос в сервис, получаем слайс записей журнала операций и ошибку
+	ec, err := handler.service.History(ctx, login)
+	// 200 - при ошибке nil, 204 - при ошибке "no records", 500 - при иных ошибках сервиса
+	switch {
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
-		// устанавливаем статус-код 200
-		w.WriteHeader(http.StatusOK)
-		// сериализуем и пишем тело ответа
-		json.NewEncoder(w).Encode(ec)
+		writeJSON(w, ec)
+	}
+}
+
+// выгрузка информации о выводе средств с накопительного счёта пользователем в файл CSV или XLSX
+func (handler BalanceHandler) WithdrawalsExport(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем значение login из контекста запроса
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerWithdrawalsExport: %s", err)
+		writeProblem(w, r, problemInternal, "balance handling error")
+		return
+	}
+	// получаем значение из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerWithdrawalsExport: %s", err)
+		writeProblem(w, r, problemInternal, "balance handling error")
+		return
+	}
+	// формат файла и часовой пояс из параметров запроса, фильтры - как у списка списаний
+	ex, err := newExportWriter(w, r, "withdrawals", "order", "sum", "processed_at")
+	if err == nil {
+		// списания пишутся в файл по мере чтения из хранилища
+		err = handler.service.WithdrawalsEach(ctx, login, decodeListQuery(r), func(v models.WithdrawalsList) error {
+			return ex.Write(v.Order, v.Sum, v.ProcessedAt)
+		})
+	}
+	// 200 - при ошибке nil, в том числе файл без списаний, 400 - при неверных параметрах выгрузки,
+	// 500 - при иных ошибках сервиса, после начала выдачи ошибка только логируется
+	switch {
+	case ex != nil && ex.Started() && err != nil:
+		log.Printf("withdrawals export streaming error HandlerWithdrawalsExport: %s", err)
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		if err = ex.Close(); err != nil {
+			log.Printf("withdrawals export close error HandlerWithdrawalsExport: %s", err)
+		}
 	}
 }
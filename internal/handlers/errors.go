@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/rs/zerolog/log"
+)
+
+// тип содержимого ответа с описанием ошибки по RFC 7807
+const contentTypeProblem = "application/problem+json"
+
+// начало идентификатора вида ошибки в поле type
+const problemTypeBase = "/problems/"
+
+// вид ошибки запроса: идентификатор для поля type, заголовок и статус ответа
+type problemType struct {
+	slug   string
+	title  string
+	status int
+}
+
+// виды ошибок запроса, идентификаторы не меняются: клиенты выбирают по ним сообщения
+var (
+	problemInvalidJSON        = problemType{"invalid-json", "Invalid JSON structure", http.StatusBadRequest}
+	problemValidation         = problemType{"validation-failed", "Request validation failed", http.StatusBadRequest}
+	problemInvalidOrderNumber = problemType{"invalid-order-number", "Invalid order number", http.StatusUnprocessableEntity}
+	problemUnauthorized       = problemType{"unauthorized", "Unauthorized", http.StatusUnauthorized}
+	problemForbidden          = problemType{"forbidden", "Forbidden", http.StatusForbidden}
+	problemNotFound           = problemType{"not-found", "Not found", http.StatusNotFound}
+	problemMethodNotAllowed   = problemType{"method-not-allowed", "Method not allowed", http.StatusMethodNotAllowed}
+	problemLoginLocked        = problemType{"login-locked", "Too many failed login attempts", http.StatusTooManyRequests}
+	problemInternal           = problemType{"internal-error", "Internal server error", http.StatusInternalServerError}
+	// ошибки заголовка Idempotency-Key
+	problemIdempotencyKeyTooLong = problemType{"idempotency-key-too-long", "Idempotency-Key is too long", http.StatusBadRequest}
+	problemIdempotencyKeyReused  = problemType{"idempotency-key-reused", "Idempotency-Key was used for another request", http.StatusUnprocessableEntity}
+	problemIdempotencyInProgress = problemType{"idempotency-in-progress", "Request with this Idempotency-Key is in progress", http.StatusConflict}
+	// ошибки предметной области
+	problemLoginExists         = problemType{"login-exists", "Login already exists", http.StatusConflict}
+	problemInvalidCredentials  = problemType{"invalid-credentials", "Invalid login or password", http.StatusUnauthorized}
+	problemInvalidRefreshToken = problemType{"invalid-refresh-token", "Invalid refresh token", http.StatusUnauthorized}
+	problemWrongPassword       = problemType{"wrong-password", "Wrong old password", http.StatusForbidden}
+	problemInvalidResetToken   = problemType{"invalid-reset-token", "Invalid or expired reset token", http.StatusBadRequest}
+	problemInsufficientFunds   = problemType{"insufficient-funds", "Insufficient funds", http.StatusPaymentRequired}
+	problemWithdrawalExists    = problemType{"withdrawal-exists", "Withdrawal for this order already exists", http.StatusUnprocessableEntity}
+	problemOrderOwnedByOther   = problemType{"order-owned-by-other", "Order was loaded by another user", http.StatusConflict}
+	problemOrderFinal          = problemType{"order-final", "Order status is final", http.StatusConflict}
+	problemPollInProgress      = problemType{"poll-in-progress", "Order poll is in progress", http.StatusConflict}
+	problemBalanceChanged      = problemType{"balance-changed", "Balance changed after reconciliation", http.StatusConflict}
+	problemLoginNotFound       = problemType{"login-not-found", "Login not found", http.StatusNotFound}
+	problemOrderNotFound       = problemType{"order-not-found", "Order not found", http.StatusNotFound}
+)
+
+// вид ошибки запроса для ошибки предметной области
+type errorResponse struct {
+	err     error
+	problem problemType
+}
+
+// виды ошибок запроса для ошибок предметной области, проверяются по порядку через errors.Is:
+// частные ошибки категории указываются раньше категории
+var errorResponses = []errorResponse{
+	{domainerrors.ErrOrderOwnedByOther, problemOrderOwnedByOther},
+	{domainerrors.ErrOrderFinal, problemOrderFinal},
+	{domainerrors.ErrPollInProgress, problemPollInProgress},
+	{domainerrors.ErrLoginExists, problemLoginExists},
+	{domainerrors.ErrBalanceChanged, problemBalanceChanged},
+	{domainerrors.ErrInvalidCredentials, problemInvalidCredentials},
+	{domainerrors.ErrRefreshTokenNotFound, problemInvalidRefreshToken},
+	{domainerrors.ErrWrongPassword, problemWrongPassword},
+	{domainerrors.ErrResetTokenNotFound, problemInvalidResetToken},
+	{domainerrors.ErrInsufficientFunds, problemInsufficientFunds},
+	{domainerrors.ErrWithdrawalExists, problemWithdrawalExists},
+	{domainerrors.ErrLoginNotFound, problemLoginNotFound},
+	{domainerrors.ErrOrderNotFound, problemOrderNotFound},
+	{domainerrors.ErrNotFound, problemNotFound},
+}
+
+// запись ответа на ошибку сервиса: 204 для пустого списка, 200 для повторной загрузки заказа,
+// 400 со списком нарушенных правил, 429 с заголовком Retry-After при блокировке входа,
+// вид ошибки по таблице ошибок предметной области, 500 - при иных ошибках без текста ошибки в ответе
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErr *models.ValidationError
+	var lockoutErr *models.LockoutError
+	switch {
+	case errors.Is(err, domainerrors.ErrEmptyList):
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case errors.Is(err, domainerrors.ErrOrderLoaded):
+		w.WriteHeader(http.StatusOK)
+		return
+	case errors.As(err, &validationErr):
+		writeValidationError(w, r, validationErr)
+		return
+	case errors.As(err, &lockoutErr):
+		retryAfter := strconv.Itoa(int(math.Ceil(lockoutErr.RetryAfter.Seconds())))
+		w.Header().Set("Retry-After", retryAfter)
+		writeProblem(w, r, problemLoginLocked, "retry after "+retryAfter+" seconds")
+		return
+	}
+	for _, resp := range errorResponses {
+		if errors.Is(err, resp.err) {
+			writeProblem(w, r, resp.problem, err.Error())
+			return
+		}
+	}
+	log.Printf("unexpected service error: %s", err)
+	writeProblem(w, r, problemInternal, "")
+}
+
+// запись списка нарушенных правил в ответ со статусом 400
+func writeValidationError(w http.ResponseWriter, r *http.Request, validationErr *models.ValidationError) {
+	p := newProblem(r, problemValidation, validationErr.Error())
+	p.Violations = validationErr.Violations
+	writeProblemJSON(w, p)
+}
+
+// запись описания ошибки запроса вида pt, detail - пояснение для разработчика клиента
+func writeProblem(w http.ResponseWriter, r *http.Request, pt problemType, detail string) {
+	writeProblemJSON(w, newProblem(r, pt, detail))
+}
+
+// описание ошибки запроса с идентификатором запроса для поиска в журнале
+func newProblem(r *http.Request, pt problemType, detail string) models.Problem {
+	return models.Problem{
+		Type:      problemTypeBase + pt.slug,
+		Title:     pt.title,
+		Status:    pt.status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+}
+
+// сериализация описания ошибки в ответ
+func writeProblemJSON(w http.ResponseWriter, p models.Problem) {
+	if p.RequestID != "" {
+		w.Header().Set(middleware.RequestIDHeader, p.RequestID)
+	}
+	w.Header().Set("Content-Type", contentTypeProblem)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Recoverer - middleware перехвата паники обработчика, повторяет middleware.Recoverer,
+// ответ 500 выдается описанием ошибки по RFC 7807 без текста паники
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			// прерывание ответа сервером http не является ошибкой обработчика
+			if rvr == http.ErrAbortHandler {
+				panic(rvr)
+			}
+			log.Printf("panic in request %s %s (request id %s): %v\n%s", r.Method, r.URL.Path, middleware.GetReqID(r.Context()), rvr, debug.Stack())
+			// соединение, переключенное на другой протокол, ответа не получает
+			if r.Header.Get("Connection") != "Upgrade" {
+				writeProblem(w, r, problemInternal, "")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NotFound - ответ 404 для путей без обработчика
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemNotFound, "no handler for this path")
+}
+
+// MethodNotAllowed - ответ 405 для метода, не поддерживаемого путем
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, problemMethodNotAllowed, "method "+r.Method+" is not supported for this path")
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/export"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// exportWriter пишет строки таблицы в файл выгрузки в ответе по мере получения
+// заголовки ответа, статус 200 и строка заголовков колонок пишутся с первой строкой или при закрытии,
+// пока строк нет, статус ответа можно выбрать
+type exportWriter struct {
+	w       http.ResponseWriter
+	format  string
+	loc     *time.Location
+	name    string
+	columns []interface{}
+	ew      export.Writer
+}
+
+// конструктор exportWriter по параметрам запроса format и tz
+// format - csv или xlsx, без параметра формат выбирается по заголовку Accept, по умолчанию csv
+// tz - часовой пояс IANA для времени в файле, по умолчанию UTC
+// name - имя файла без расширения и имя листа, columns - заголовки колонок
+func newExportWriter(w http.ResponseWriter, r *http.Request, name string, columns ...interface{}) (*exportWriter, error) {
+	var violations []models.Violation
+	values := r.URL.Query()
+	format := strings.ToLower(values.Get("format"))
+	if format == "" {
+		format = export.FormatCSV
+		if strings.Contains(r.Header.Get("Accept"), export.ContentTypeXLSX) {
+			format = export.FormatXLSX
+		}
+	}
+	if !models.Contains(export.Formats, format) {
+		violations = append(violations, models.Violation{Field: "format", Rule: "oneof", Message: "format must be one of: " + strings.Join(export.Formats, ", ")})
+	}
+	loc := time.UTC
+	if tz := values.Get("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			violations = append(violations, models.Violation{Field: "tz", Rule: "timezone", Message: "tz must be an IANA time zone name"})
+		}
+	}
+	if len(violations) > 0 {
+		return nil, &models.ValidationError{Violations: violations}
+	}
+	return &exportWriter{
+		w:       w,
+		format:  format,
+		loc:     loc,
+		name:    name,
+		columns: columns,
+	}, nil
+}
+
+// Write пишет очередную строку таблицы
+func (ex *exportWriter) Write(row ...interface{}) (err error) {
+	if ex.ew == nil {
+		if err = ex.start(); err != nil {
+			return err
+		}
+	}
+	return ex.ew.Write(row...)
+}
+
+// Started - в ответ записан файл выгрузки
+func (ex *exportWriter) Started() bool {
+	return ex.ew != nil
+}
+
+// Close завершает файл, без строк в ответ пишется файл с одними заголовками колонок
+func (ex *exportWriter) Close() (err error) {
+	if ex.ew == nil {
+		if err = ex.start(); err != nil {
+			return err
+		}
+	}
+	return ex.ew.Close()
+}
+
+// запись заголовков ответа и строки заголовков колонок
+func (ex *exportWriter) start() (err error) {
+	ew, err := export.NewWriter(ex.w, ex.format, ex.loc, ex.name)
+	if err != nil {
+		return err
+	}
+	ex.ew = ew
+	ex.w.Header().Set("Content-Type", export.ContentType(ex.format))
+	ex.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, ex.name, ex.format))
+	ex.w.WriteHeader(http.StatusOK)
+	return ex.ew.Write(ex.columns...)
+}
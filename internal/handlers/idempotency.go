@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/go-chi/jwtauth/v5"
+
+	"github.com/rs/zerolog/log"
+)
+
+// максимальная длина значения заголовка Idempotency-Key
+const maxIdempotencyKeyLen = 255
+
+// интерфейс методов бизнес логики Idempotency
+type IdempotencyServiceProvider interface {
+	Begin(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (ec models.IdempotencyRecord, reserved bool, err error)
+	Complete(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error)
+	Release(ctx context.Context, login string, key string) (err error)
+}
+
+// структура для конструктура обработчика Idempotency
+type IdempotencyHandler struct {
+	service IdempotencyServiceProvider
+}
+
+// конструктор обработчика Idempotency
+func NewIdempotencyHandler(hIdempotency IdempotencyServiceProvider) *IdempotencyHandler {
+	return &IdempotencyHandler{
+		hIdempotency,
+	}
+}
+
+// структура записи ответа обработчика для сохранения результата запроса
+type recordWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// метод записи статус-кода ответа
+func (w *recordWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// метод записи тела ответа
+func (w *recordWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Wrap выполняет обработчик next не более одного раза для значения заголовка Idempotency-Key в пределах логина,
+// повторные запросы с тем же ключом получают сохраненный ответ без повторного выполнения
+func (handler IdempotencyHandler) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// запросы без заголовка выполняются без изменений
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeProblem(w, r, problemIdempotencyKeyTooLong, "")
+			return
+		}
+		// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+		ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+		// освобождаем ресурс
+		defer cancel()
+		// получаем значение claims из контекста запроса
+		_, claims, err := jwtauth.FromContext(r.Context())
+		if err != nil {
+			log.Printf("FromContext error HandlerIdempotency: %s", err)
+			writeProblem(w, r, problemInternal, "idempotency handling error")
+			return
+		}
+		// получаем значение login из интерфейса
+		login, ok := claims["login"].(string)
+		if !ok {
+			log.Printf("interface assertion error HandlerIdempotency: %s", err)
+			writeProblem(w, r, problemInternal, "idempotency handling error")
+			return
+		}
+		// читаем Body для расчета хеша и восстанавливаем его для обработчика
+		bs, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("body read HandlerIdempotency error :%s", err)
+			writeProblem(w, r, problemInternal, "idempotency handling error")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(bs))
+		hash := sha256.Sum256(bs)
+		rec := models.IdempotencyRecord{
+			Endpoint:    r.Method + " " + r.URL.Path,
+			RequestHash: hex.EncodeToString(hash[:]),
+		}
+		// резервируем ключ или получаем сохраненный результат
+		ec, reserved, err := handler.service.Begin(ctx, login, key, rec)
+		switch {
+		case err != nil:
+			writeProblem(w, r, problemInternal, "idempotency handling error")
+		// ключ использован для другого запроса - 422
+		case !reserved && (ec.Endpoint != rec.Endpoint || ec.RequestHash != rec.RequestHash):
+			writeProblem(w, r, problemIdempotencyKeyReused, "")
+		// запрос с этим ключом еще выполняется - 409
+		case !reserved && ec.StatusCode == 0:
+			writeProblem(w, r, problemIdempotencyInProgress, "")
+		// повторяем сохраненный ответ
+		case !reserved:
+			if ec.ContentType != "" {
+				w.Header().Set("Content-Type", ec.ContentType)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(ec.StatusCode)
+			w.Write(ec.Body)
+		// выполняем запрос и сохраняем результат
+		default:
+			// при панике обработчика освобождаем ключ, чтобы запрос можно было выполнить повторно
+			defer func() {
+				if p := recover(); p != nil {
+					ctxRelease, cancelRelease := context.WithTimeout(context.Background(), settings.StorageTimeout)
+					defer cancelRelease()
+					if err := handler.service.Release(ctxRelease, login, key); err != nil {
+						log.Printf("idempotency key release error for login %s key %s: %s", login, key, err)
+					}
+					panic(p)
+				}
+			}()
+			rw := &recordWriter{ResponseWriter: w}
+			next(rw, r)
+			rec.StatusCode = rw.status
+			if rec.StatusCode == 0 {
+				rec.StatusCode = http.StatusOK
+			}
+			rec.ContentType = w.Header().Get("Content-Type")
+			rec.Body = rw.body.Bytes()
+			// сохраняем результат независимо от отмены контекста запроса
+			ctxSave, cancelSave := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			defer cancelSave()
+			if err := handler.service.Complete(ctxSave, login, key, rec); err != nil {
+				log.Printf("idempotency result save error for login %s key %s: %s", login, key, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// интерфейс методов набора ключей подписи токенов
+type KeySetProvider interface {
+	Decode(tokenString string) (jwt.Token, error)
+	PublicSet() jwk.Set
+}
+
+// структура для конструктура обработчика ключей подписи токенов
+type KeysHandler struct {
+	keys KeySetProvider
+}
+
+// конструктор обработчика ключей подписи токенов
+func NewKeysHandler(keys KeySetProvider) *KeysHandler {
+	return &KeysHandler{
+		keys,
+	}
+}
+
+// публикация открытых ключей проверки подписи токенов в формате JWKS
+func (handler KeysHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	// сериализуем набор открытых ключей
+	body, err := json.Marshal(handler.keys.PublicSet())
+	if err != nil {
+		log.Printf("marshal error HandlerJWKS: %s", err)
+		writeProblem(w, r, problemInternal, "")
+		return
+	}
+	// устанавливаем заголовки, ключи меняются только при перезапуске приложения
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// Verifier - middleware поиска токена в заголовке Authorization или cookie jwt и проверки подписи
+// ключами набора, результат помещается в контекст запроса для Authenticator и jwtauth.FromContext
+func (handler KeysHandler) Verifier(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token jwt.Token
+		err := jwtauth.ErrNoTokenFound
+		// ищем токен в заголовке, затем в cookie
+		tokenString := jwtauth.TokenFromHeader(r)
+		if tokenString == "" {
+			tokenString = jwtauth.TokenFromCookie(r)
+		}
+		if tokenString != "" {
+			token, err = handler.keys.Decode(tokenString)
+		}
+		ctx := jwtauth.NewContext(r.Context(), token, err)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Authenticator - middleware допуска запросов с действительным токеном доступа, подключается после Verifier
+// повторяет jwtauth.Authenticator, отказ в доступе выдается описанием ошибки по RFC 7807
+func Authenticator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _, err := jwtauth.FromContext(r.Context())
+		if err != nil || token == nil || jwt.Validate(token) != nil {
+			writeProblem(w, r, problemUnauthorized, "valid access token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
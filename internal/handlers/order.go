@@ -2,15 +2,14 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"io"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/ShiraazMoollatjie/goluhn"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 
 	"github.com/rs/zerolog/log"
@@ -19,7 +18,9 @@ import (
 // интерфейс методов бизнес логики Order
 type OrderServiceProvider interface {
 	Load(ctx context.Context, login string, orderNum string) (err error)
-	List(ctx context.Context, login string) (ec []models.OrdersList, err error)
+	List(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.OrdersList, next string, err error)
+	ListEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.OrdersList) error) (err error)
+	Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error)
 }
 
 // структура для конструктура обработчика Order
@@ -45,7 +46,7 @@ func (handler OrderHandler) Load(w http.ResponseWriter, r *http.Request) {
 	// обрабатываем ошибку
 	if err != nil {
 		log.Printf("body read HandlerLoad error :%s", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
 	b := string(bs)
@@ -53,28 +54,28 @@ func (handler OrderHandler) Load(w http.ResponseWriter, r *http.Request) {
 	_, err = strconv.Atoi(b)
 	if err != nil {
 		log.Printf("digits check HandlerLoad error :%s", err)
-		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		writeProblem(w, r, problemInvalidOrderNumber, "order number must contain only digits")
 		return
 	}
 	// проверяем на алгоритм Луна, если не ок, возвращаем 422
 	err = goluhn.Validate(b)
 	if err != nil {
 		log.Printf("luhn algo check HandlerLoad error :%s", err)
-		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		writeProblem(w, r, problemInvalidOrderNumber, "order number failed Luhn check")
 		return
 	}
 	// получаем значение claims из контекста запроса
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("FromContext error HandlerLoad: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
 	// получаем значение login из интерфейса
 	login, ok := claims["login"].(string)
 	if !ok {
 		log.Printf("interface assertion error HandlerLoad: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
 	// загружаем новмер нового заказа 
@@ -82,12 +83,8 @@ func (handler OrderHandler) Load(w http.ResponseWriter, r *http.Request) {
 	// если ордер существует от этого пользователя - статус 200, если иная ошибка - 500
 	// если от другого пользователя - 409 // если нет ошибок - 202
 	switch {
-	case err != nil && strings.Contains(err.Error(), "order number from this login already exist"):
-		w.WriteHeader(http.StatusOK)
-	case err != nil && strings.Contains(err.Error(), "the same order number was loaded by another customer"):
-		w.WriteHeader(http.StatusConflict)
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
 		w.WriteHeader(http.StatusAccepted)
 	}
@@ -104,30 +101,122 @@ func (handler OrderHandler) List(w http.ResponseWriter, r *http.Request) {
 	_, claims, err := jwtauth.FromContext(r.Context())
 	if err != nil {
 		log.Printf("FromContext error HandlerList: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
 	// получаем значение из интерфейса
 	login, ok := claims["login"].(string)
 	if !ok {
 		log.Printf("interface assertion error HandlerList: %s", err)
-		http.Error(w, "order handling error", http.StatusInternalServerError)
+		writeProblem(w, r, problemInternal, "order handling error")
 		return
 	}
-	// устанавливаем заголовок
-	w.Header().Set("Content-Type", "application/json")
-	// направляем запрос в сервис, получаем слайс структур заказов и ошибку
-	ec, err := handler.service.List(ctx, login)
-	// 200 - при ошибке nil, 204 - при ошибке "no records for this login", 500 - при иных ошибках сервиса
+	// устанавливаем заголовок, заказы выдаются массивом JSON или NDJSON по заголовку Accept
+	le := newListEncoder(w, r)
+	dc := decodeListQuery(r)
+	if dc.Limit == "" {
+		// весь список выдается потоком по мере чтения заказов из хранилища
+		err = handler.service.ListEach(ctx, login, dc, func(v models.OrdersList) error {
+			return le.Encode(v)
+		})
+	} else {
+		// страница ограничена по размеру и собирается в памяти для ссылки на следующую страницу
+		var ec []models.OrdersList
+		var next string
+		ec, next, err = handler.service.List(ctx, login, dc)
+		if err == nil {
+			// ссылка на следующую страницу
+			setNextPage(w, r, next)
+			for _, v := range ec {
+				if err = le.Encode(v); err != nil {
+					break
+				}
+			}
+		}
+	}
+	// 200 - при ошибке nil, 204 - при отсутствии заказов, 400 - при неверных параметрах выборки,
+	// 500 - при иных ошибках сервиса, после начала выдачи ошибка только логируется
 	switch {
-	case err != nil && strings.Contains(err.Error(), "no orders for this login"):
+	case le.Started() && err != nil:
+		log.Printf("order list streaming error HandlerList: %s", err)
+	case le.Started():
+		le.Close()
+	case err != nil:
+		writeError(w, r, err)
+	default:
 		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// получение загруженного пользователем заказа с историей изменения статуса и информацией о начислении
+func (handler OrderHandler) Order(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем значение login из контекста запроса
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerOrder: %s", err)
+		writeProblem(w, r, problemInternal, "order handling error")
+		return
+	}
+	// получаем значение из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerOrder: %s", err)
+		writeProblem(w, r, problemInternal, "order handling error")
+		return
+	}
+	// направляем запрос в сервис, получаем структуру заказа и ошибку
+	ec, err := handler.service.Order(ctx, login, chi.URLParam(r, "number"))
+	// 200 - при ошибке nil, 404 - при ошибке "order not exist", 500 - при иных ошибках сервиса
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		writeJSON(w, ec)
+	}
+}
+
+// выгрузка списка загруженных пользователем заказов в файл CSV или XLSX
+func (handler OrderHandler) Export(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем значение login из контекста запроса
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerExport: %s", err)
+		writeProblem(w, r, problemInternal, "order handling error")
+		return
+	}
+	// получаем значение из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerExport: %s", err)
+		writeProblem(w, r, problemInternal, "order handling error")
+		return
+	}
+	// формат файла и часовой пояс из параметров запроса, фильтры - как у списка заказов
+	ex, err := newExportWriter(w, r, "orders", "number", "status", "accrual", "uploaded_at")
+	if err == nil {
+		// заказы пишутся в файл по мере чтения из хранилища
+		err = handler.service.ListEach(ctx, login, decodeListQuery(r), func(v models.OrdersList) error {
+			return ex.Write(v.Number, v.Status, v.Accrual, v.UploadedAt)
+		})
+	}
+	// 200 - при ошибке nil, в том числе файл без заказов, 400 - при неверных параметрах выгрузки,
+	// 500 - при иных ошибках сервиса, после начала выдачи ошибка только логируется
+	switch {
+	case ex != nil && ex.Started() && err != nil:
+		log.Printf("order export streaming error HandlerExport: %s", err)
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
-		// устанавливаем статус-код 200
-		w.WriteHeader(http.StatusOK)
-		// сериализуем и пишем тело ответа
-		json.NewEncoder(w).Encode(ec)
+		if err = ex.Close(); err != nil {
+			log.Printf("order export close error HandlerExport: %s", err)
+		}
 	}
 }
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// параметры постраничной выдачи, фильтров и сортировки списка из строки запроса
+func decodeListQuery(r *http.Request) models.DecodeListQuery {
+	values := r.URL.Query()
+	return models.DecodeListQuery{
+		Limit:  values.Get("limit"),
+		After:  values.Get("after"),
+		Status: values["status"],
+		From:   values.Get("from"),
+		To:     values.Get("to"),
+		Sort:   values.Get("sort"),
+	}
+}
+
+// заголовки ссылки на следующую страницу списка с сохранением остальных параметров запроса
+// для последней страницы заголовки не устанавливаются
+func setNextPage(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	values := r.URL.Query()
+	values.Set("after", next)
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, values.Encode()))
+	w.Header().Set("X-Next-Cursor", next)
+}
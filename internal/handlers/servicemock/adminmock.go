@@ -0,0 +1,160 @@
+package servicemock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// имплементация интерфейса AdminServiceProvider
+type AdminServiceMock struct {
+}
+
+// заглушка
+func (msrv *AdminServiceMock) User(ctx context.Context, login string) (ec models.AdminUser, err error) {
+	switch login {
+	case "dimma":
+		return models.AdminUser{Login: login, Role: models.RoleUser, Orders: 1}, nil
+	case "dimmaServErr":
+		return ec, errors.New("something wrong with server")
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) Order(ctx context.Context, orderNum string) (ec models.AdminOrder, err error) {
+	switch orderNum {
+	case "2377225624":
+		ec.Login = "dimma"
+		ec.OrdersList = models.OrdersList{
+			Number:     orderNum,
+			Status:     "PROCESSED",
+			Accrual:    decimal.NewFromFloatWithExponent(500, -2),
+			UploadedAt: time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC),
+		}
+		return ec, nil
+	case "12345678903":
+		return ec, domainerrors.ErrOrderNotFound
+	default:
+		return ec, errors.New("something wrong with server")
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) Orders(ctx context.Context, login string) (ec []models.OrdersList, err error) {
+	switch login {
+	case "dimma":
+		return []models.OrdersList{{Number: "2377225624", Status: "PROCESSED"}}, nil
+	case "dimma2":
+		return ec, domainerrors.ErrNoOrders
+	case "dimmaServErr":
+		return ec, errors.New("something wrong with server")
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) Balance(ctx context.Context, login string) (ec models.BalanceCheck, err error) {
+	switch login {
+	case "dimma":
+		return models.BalanceCheck{Login: login, Consistent: true}, nil
+	case "dimmaServErr":
+		return ec, errors.New("something wrong with server")
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) Withdrawals(ctx context.Context, login string) (ec []models.WithdrawalsList, err error) {
+	switch login {
+	case "dimma":
+		return []models.WithdrawalsList{{Order: "2377225624", Sum: decimal.NewFromFloatWithExponent(42, -2)}}, nil
+	case "dimma2":
+		return ec, domainerrors.ErrNoRecords
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) SetRole(ctx context.Context, login string, role string) (err error) {
+	switch {
+	case role != models.RoleUser && role != models.RoleAdmin:
+		return &models.ValidationError{Violations: []models.Violation{
+			{Field: "role", Rule: "oneof", Message: "role must be one of: user, admin"},
+		}}
+	case login == "dimma":
+		return nil
+	case login == "dimmaServErr":
+		return errors.New("something wrong with server")
+	default:
+		return domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error) {
+	switch {
+	case dc.Reason == "":
+		return ec, &models.ValidationError{Violations: []models.Violation{
+			{Field: "reason", Rule: "oneof", Message: "reason must be one of: GOODWILL"},
+		}}
+	case login == "dimma" && dc.CreatedBy == "admin" && dc.Operation == models.AdjustmentCredit:
+		return models.LoginBalance{Current: decimal.NewFromFloatWithExponent(600.5, -2)}, nil
+	case login == "dimma" && dc.Operation == models.AdjustmentDebit:
+		return ec, domainerrors.ErrInsufficientFunds
+	case login == "dimmaServErr":
+		return ec, errors.New("something wrong with server")
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	switch login {
+	case "dimma":
+		return []models.LedgerEntry{{EntryType: models.EntryManualAdjustment, Reason: "GOODWILL", CreatedBy: "admin"}}, nil
+	case "dimma2":
+		return ec, domainerrors.ErrNoRecords
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error) {
+	switch orderNum {
+	case "2377225624":
+		ec.AdminOrder, _ = msrv.Order(ctx, orderNum)
+		ec.History = []models.OrderStatusChange{{Status: "NEW"}, {Status: "PROCESSED"}}
+		ec.LastPoll = &models.AccrualPoll{Attempt: 2, StatusCode: 200}
+		ec.Attempts = 2
+		return ec, nil
+	case "12345678903":
+		return ec, domainerrors.ErrOrderNotFound
+	default:
+		return ec, errors.New("something wrong with server")
+	}
+}
+
+// заглушка
+func (msrv *AdminServiceMock) Repoll(ctx context.Context, orderNum string) (err error) {
+	switch orderNum {
+	case "79927398713":
+		return nil
+	case "2377225624":
+		return domainerrors.ErrOrderFinal
+	case "12345678903":
+		return domainerrors.ErrOrderNotFound
+	default:
+		return errors.New("something wrong with server")
+	}
+}
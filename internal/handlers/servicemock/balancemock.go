@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/shopspring/decimal"
 )
@@ -39,9 +40,9 @@ func (mserv *BalanceServiceProvider) NewWithdrawal(ctx context.Context, login st
 	case login == "dimma" && dc.Order == "2377225624" && dc.Sum.Equal(decimal.NewFromFloat(751)):
 		return nil
 	case login == "dimma" && dc.Order == "2377225624" && dc.Sum.GreaterThan(decimal.NewFromFloat(751)):
-		return errors.New("insufficient funds")
+		return domainerrors.ErrInsufficientFunds
 	case login == "dimma" && dc.Order == "24564564536456":
-		return errors.New("new order number already exist")
+		return domainerrors.ErrWithdrawalExists
 	default:
 		log.Printf("error for login: %s", login)
 		return errors.New("something wrong with server")
@@ -50,7 +51,7 @@ func (mserv *BalanceServiceProvider) NewWithdrawal(ctx context.Context, login st
 }
 
 // заглушка WithdrawalsList
-func (mserv *BalanceServiceProvider) WithdrawalsList(ctx context.Context, login string) (ec []models.WithdrawalsList, err error) {
+func (mserv *BalanceServiceProvider) WithdrawalsList(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.WithdrawalsList, next string, err error) {
 	ec = []models.WithdrawalsList{
 		{
 			Order:       "2377225624",
@@ -63,13 +64,64 @@ func (mserv *BalanceServiceProvider) WithdrawalsList(ctx context.Context, login
 			ProcessedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
 		},
 	}
+	// фильтр по статусу для списаний не поддерживается
+	if len(dc.Status) > 0 {
+		return nil, "", &models.ValidationError{Violations: []models.Violation{
+			{Field: "status", Rule: "unsupported", Message: "status filter is not supported for this list"},
+		}}
+	}
+	switch login {
+	case "dimma":
+		// первая страница с курсором следующей страницы
+		if dc.Limit == "1" {
+			return ec[:1], "cursor", nil
+		}
+		return ec, "", nil
+	case "dimma2":
+		return nil, "", domainerrors.ErrNoRecords
+	default:
+		log.Printf("error for login: %s", login)
+		return nil, "", errors.New("something wrong with server")
+	}
+}
+
+// заглушка History
+func (mserv *BalanceServiceProvider) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
 	switch login {
 	case "dimma":
+		ec = []models.LedgerEntry{
+			{
+				EntryType:     models.EntryManualAdjustment,
+				DebitAccount:  models.AccountAdjustments,
+				CreditAccount: "user:dimma",
+				Amount:        decimal.NewFromFloatWithExponent(100, -2),
+				Reason:        "GOODWILL",
+				Comment:       "compensation for delayed accrual",
+			},
+		}
 		return ec, nil
 	case "dimma2":
-		return nil, errors.New("no records")
+		return nil, domainerrors.ErrNoRecords
 	default:
 		log.Printf("error for login: %s", login)
 		return nil, errors.New("something wrong with server")
 	}
 }
+
+// заглушка WithdrawalsEach
+func (mserv *BalanceServiceProvider) WithdrawalsEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.WithdrawalsList) error) (err error) {
+	ec, _, err := mserv.WithdrawalsList(ctx, login, dc)
+	// пустой список выдается без ошибки
+	if errors.Is(err, domainerrors.ErrEmptyList) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, v := range ec {
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
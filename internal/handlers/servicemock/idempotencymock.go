@@ -0,0 +1,48 @@
+package servicemock
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// имплементация интерфейса IdempotencyServiceProvider с хранением ключей в памяти
+type IdempotencyServiceMock struct {
+	mu      sync.Mutex
+	records map[string]models.IdempotencyRecord
+}
+
+// заглушка
+func (mserv *IdempotencyServiceMock) Begin(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (ec models.IdempotencyRecord, reserved bool, err error) {
+	mserv.mu.Lock()
+	defer mserv.mu.Unlock()
+	if login == "dimma2" {
+		return ec, false, errors.New("something wrong with server")
+	}
+	if mserv.records == nil {
+		mserv.records = make(map[string]models.IdempotencyRecord)
+	}
+	if ec, ok := mserv.records[login+key]; ok {
+		return ec, false, nil
+	}
+	mserv.records[login+key] = rec
+	return rec, true, nil
+}
+
+// заглушка
+func (mserv *IdempotencyServiceMock) Complete(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error) {
+	mserv.mu.Lock()
+	defer mserv.mu.Unlock()
+	mserv.records[login+key] = rec
+	return nil
+}
+
+// заглушка
+func (mserv *IdempotencyServiceMock) Release(ctx context.Context, login string, key string) (err error) {
+	mserv.mu.Lock()
+	defer mserv.mu.Unlock()
+	delete(mserv.records, login+key)
+	return nil
+}
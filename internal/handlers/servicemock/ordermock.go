@@ -6,6 +6,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/shopspring/decimal"
 )
@@ -21,10 +22,10 @@ func (mserv *OrderServiceMock) Load(ctx context.Context, login string, orderNum
 		return nil
 	case login == "dimma2login" && orderNum == "1235489802":
 		log.Printf("order number from this login already exist: %s", orderNum)
-		return errors.New("order number from this login already exist")
+		return domainerrors.ErrOrderLoaded
 	case login == "dimma3" && orderNum == "1235489802":
 		log.Printf("the same order number was loaded by another customer: %s", login)
-		return errors.New("the same order number was loaded by another customer")
+		return domainerrors.ErrOrderOwnedByOther
 	default:
 		log.Printf("error for login: %s", login)
 		return errors.New("something wrong woth server")
@@ -33,7 +34,13 @@ func (mserv *OrderServiceMock) Load(ctx context.Context, login string, orderNum
 }
 
 // заглушка
-func (mserv *OrderServiceMock) List(ctx context.Context, login string) (ec []models.OrdersList, err error) {
+func (mserv *OrderServiceMock) List(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.OrdersList, next string, err error) {
+	// неверные параметры выборки
+	if dc.Sort != "" && dc.Sort != "asc" && dc.Sort != "desc" {
+		return nil, "", &models.ValidationError{Violations: []models.Violation{
+			{Field: "sort", Rule: "oneof", Message: "sort must be one of: asc, desc"},
+		}}
+	}
 	switch login {
 	case "dimma":
 		ec = []models.OrdersList{
@@ -54,12 +61,59 @@ func (mserv *OrderServiceMock) List(ctx context.Context, login string) (ec []mod
 				UploadedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
 			},
 		}
-		return ec, nil
+		// первая страница с курсором следующей страницы
+		if dc.Limit == "1" {
+			return ec[:1], "cursor", nil
+		}
+		return ec, "", nil
 	case "dimma2login":
 		log.Printf("no orders for this login: %s", login)
-		return nil, errors.New("no orders for this login")
+		return nil, "", domainerrors.ErrNoOrders
 	default:
 		log.Printf("error for login: %s", login)
-		return nil, errors.New("something wrong woth server")
+		return nil, "", errors.New("something wrong woth server")
+	}
+}
+
+// заглушка
+func (mserv *OrderServiceMock) Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error) {
+	switch {
+	case login == "dimma" && orderNum == "9278923470":
+		ec.OrdersList = models.OrdersList{
+			Number:     orderNum,
+			Status:     "PROCESSED",
+			UploadedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
+		}
+		ec.History = []models.OrderStatusChange{{Status: "NEW"}, {Status: "PROCESSING"}, {Status: "PROCESSED"}}
+		return ec, nil
+	case login == "dimma8":
+		return ec, errors.New("something wrong woth server")
+	default:
+		return ec, domainerrors.ErrOrderNotFound
+	}
+}
+
+// заглушка
+func (mserv *OrderServiceMock) ListEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.OrdersList) error) (err error) {
+	// ошибка чтения после выдачи первого заказа
+	if login == "dimmaStreamErr" {
+		if err = fn(models.OrdersList{Number: "9278923470", Status: "PROCESSED"}); err != nil {
+			return err
+		}
+		return errors.New("something wrong woth server")
+	}
+	ec, _, err := mserv.List(ctx, login, dc)
+	// пустой список выдается без ошибки
+	if errors.Is(err, domainerrors.ErrEmptyList) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, v := range ec {
+		if err = fn(v); err != nil {
+			return err
+		}
 	}
+	return nil
 }
@@ -4,7 +4,9 @@ package servicemock
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 )
 
@@ -13,23 +15,115 @@ type UserServiceMock struct {
 }
 
 // заглушка
-func (msrv *UserServiceMock) Create(ctx context.Context, dc models.DecodeLoginPair) (err error) {
+func (msrv *UserServiceMock) Create(ctx context.Context, dc models.DecodeLoginPair) (login string, err error) {
 	switch {
 	case dc.Login == "dimma" && dc.Password == "12345":
-		return nil
+		return dc.Login, nil
+	case dc.Login == "":
+		return "", &models.ValidationError{Violations: []models.Violation{
+			{Field: "login", Rule: "required", Message: "login is required"},
+			{Field: "password", Rule: "length", Message: "password must be at least 8 characters"},
+		}}
 	case dc.Login == "dimma2login":
-		return errors.New("login exist")
+		return "", domainerrors.ErrLoginExists
 	default:
-		return errors.New("something wrong woth server")
+		return "", errors.New("something wrong woth server")
 	}
 }
 // заглушка
-func (msrv *UserServiceMock) CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair) (err error) {
+func (msrv *UserServiceMock) CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair, ip string) (login string, err error) {
 	switch {
+	case dc.Login == "dimma5login":
+		return "", &models.LockoutError{RetryAfter: 90500 * time.Millisecond}
 	case dc.Login == "dimma" && dc.Password == "12345":
-		return nil
+		return dc.Login, nil
 	case dc.Login == "dimma3login" && dc.Password == "12345":
-		return errors.New("login or password not exist")
+		return "", domainerrors.ErrInvalidCredentials
+	default:
+		return "", errors.New("something wrong woth server")
+	}
+}
+
+// заглушка
+func (msrv *UserServiceMock) IssueTokens(ctx context.Context, login string) (pair models.TokenPair, err error) {
+	return models.TokenPair{AccessToken: "access-" + login, RefreshToken: "refresh-" + login, TokenType: "Bearer", ExpiresIn: 1800}, nil
+}
+
+// заглушка
+func (msrv *UserServiceMock) RefreshTokens(ctx context.Context, refreshToken string) (pair models.TokenPair, err error) {
+	switch refreshToken {
+	case "refresh-dimma":
+		return models.TokenPair{AccessToken: "access-dimma", RefreshToken: "refresh-dimma-2", TokenType: "Bearer", ExpiresIn: 1800}, nil
+	case "refresh-revoked":
+		return pair, domainerrors.ErrRefreshTokenNotFound
+	default:
+		return pair, errors.New("something wrong woth server")
+	}
+}
+
+// заглушка
+func (msrv *UserServiceMock) Logout(ctx context.Context, login string, jti string, expiresAt time.Time) (err error) {
+	if login == "dimma" {
+		return nil
+	}
+	return errors.New("something wrong woth server")
+}
+
+// заглушка
+func (msrv *UserServiceMock) IsRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error) {
+	switch jti {
+	case "revoked":
+		return true, nil
+	case "dberror":
+		return false, errors.New("something wrong woth server")
+	case "changed":
+		// пароль сменен в середине секунды
+		return !issuedAt.After(time.Date(2020, time.May, 15, 17, 45, 12, 500000000, time.UTC)), nil
+	default:
+		return false, nil
+	}
+}
+
+// заглушка
+func (msrv *UserServiceMock) ChangePassword(ctx context.Context, login string, oldPassword string, newPassword string, ip string) (err error) {
+	switch {
+	case login == "dimma" && oldPassword == "Secret123" && newPassword == "Secret456":
+		return nil
+	case login == "dimma" && oldPassword == "locked":
+		return &models.LockoutError{RetryAfter: time.Minute}
+	case login == "dimma" && oldPassword == "Secret123":
+		return &models.ValidationError{Violations: []models.Violation{
+			{Field: "password", Rule: "length", Message: "password must be at least 8 characters"},
+		}}
+	case login == "dimma":
+		return domainerrors.ErrWrongPassword
+	default:
+		return errors.New("something wrong woth server")
+	}
+}
+
+// заглушка
+func (msrv *UserServiceMock) RequestPasswordReset(ctx context.Context, login string, ip string) (err error) {
+	switch login {
+	case "dimmaServErr":
+		return errors.New("something wrong woth server")
+	case "dimmaLocked":
+		return &models.LockoutError{RetryAfter: time.Minute}
+	}
+	return nil
+}
+
+// заглушка
+func (msrv *UserServiceMock) ConfirmPasswordReset(ctx context.Context, token string, newPassword string) (err error) {
+	switch {
+	case token == "reset-dimma" && newPassword == "Secret456":
+		return nil
+	case token == "reset-dimma":
+		return &models.ValidationError{Violations: []models.Violation{
+			{Field: "password", Rule: "digit", Message: "password must contain a digit"},
+		}}
+	case token == "reset-used":
+		return domainerrors.ErrResetTokenNotFound
 	default:
 		return errors.New("something wrong woth server")
 	}
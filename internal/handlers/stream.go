@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// тип содержимого ответа с записями JSON, разделенными переводом строки
+const contentTypeNDJSON = "application/x-ndjson"
+
+// listEncoder пишет элементы списка в ответ по мере получения: JSON массивом или NDJSON,
+// если клиент запросил application/x-ndjson в заголовке Accept
+// заголовок Content-Type, статус 200 и начало массива пишутся с первым элементом,
+// пока элементов нет, статус ответа можно выбрать, ответ 204 без тела передается без Content-Type
+type listEncoder struct {
+	w       http.ResponseWriter
+	ndjson  bool
+	started bool
+}
+
+// конструктор listEncoder, формат выдачи выбирается по заголовку Accept запроса
+func newListEncoder(w http.ResponseWriter, r *http.Request) *listEncoder {
+	return &listEncoder{
+		w:      w,
+		ndjson: strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON),
+	}
+}
+
+// Encode сериализует и пишет очередной элемент списка
+func (le *listEncoder) Encode(v interface{}) (err error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// разделитель перед элементом: начало массива для первого элемента, запятая для следующих
+	sep := ","
+	if !le.started {
+		le.started = true
+		if le.ndjson {
+			le.w.Header().Set("Content-Type", contentTypeNDJSON)
+		} else {
+			le.w.Header().Set("Content-Type", "application/json")
+		}
+		le.w.WriteHeader(http.StatusOK)
+		sep = "["
+	}
+	if le.ndjson {
+		b = append(b, '\n')
+	} else {
+		b = append([]byte(sep), b...)
+	}
+	_, err = le.w.Write(b)
+	return err
+}
+
+// Started - в ответ записан хотя бы один элемент списка
+func (le *listEncoder) Started() bool {
+	return le.started
+}
+
+// Close завершает массив JSON, не вызывается при ошибке чтения списка,
+// чтобы клиент получил незавершенный JSON вместо неполного списка
+func (le *listEncoder) Close() (err error) {
+	if le.started && !le.ndjson {
+		_, err = le.w.Write([]byte("]\n"))
+	}
+	return err
+}
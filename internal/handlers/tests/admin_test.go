@@ -0,0 +1,356 @@
+package handlers__test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_RequireRole(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputRole          string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for role check - admin",
+			inputRole:          models.RoleAdmin,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test role check - user",
+			inputRole:          models.RoleUser,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Negative test role check - token without role",
+			inputRole:          "",
+			expectedStatusCode: http.StatusForbidden,
+		},
+	}
+	// защищенный обработчик
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, "/api/admin/users/dimma", nil)
+			// контекст токена
+			tkn := jwt.New()
+			tkn.Set(`login`, "dimma")
+			if tCase.inputRole != "" {
+				tkn.Set(`role`, tCase.inputRole)
+			}
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			handlers.RequireRole(models.RoleAdmin)(next).ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_Admin(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputMetod         string
+		inputEndpoint      string
+		inputBody          string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for user lookup",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"role":"user"`,
+		},
+		{
+			name:               "Negative test user lookup - login not exist",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma4",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Negative test user lookup - server error",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimmaServErr",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Positive test for order lookup",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/orders/2377225624",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"login":"dimma","number":"2377225624"`,
+		},
+		{
+			name:               "Negative test order lookup - order not exist",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/orders/12345678903",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Positive test for order diagnostics",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/orders/2377225624/diagnostics",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"history":[{"status":"NEW"`,
+		},
+		{
+			name:               "Negative test order diagnostics - order not exist",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/orders/12345678903/diagnostics",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Negative test order diagnostics - server error",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/orders/4561261212345467/diagnostics",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Positive test for order repoll",
+			inputMetod:         http.MethodPost,
+			inputEndpoint:      "/api/admin/orders/79927398713/repoll",
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "Negative test order repoll - final status",
+			inputMetod:         http.MethodPost,
+			inputEndpoint:      "/api/admin/orders/2377225624/repoll",
+			expectedStatusCode: http.StatusConflict,
+		},
+		{
+			name:               "Negative test order repoll - order not exist",
+			inputMetod:         http.MethodPost,
+			inputEndpoint:      "/api/admin/orders/12345678903/repoll",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Positive test for user orders",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma/orders",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"number":"2377225624"`,
+		},
+		{
+			name:               "Positive test for user orders - no orders",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma2/orders",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Negative test user orders - login not exist",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma4/orders",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Positive test for user balance",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma/balance",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"consistent":true`,
+		},
+		{
+			name:               "Negative test user balance - server error",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimmaServErr/balance",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:               "Positive test for user withdrawals",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma/withdrawals",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"order":"2377225624"`,
+		},
+		{
+			name:               "Positive test for user withdrawals - no withdrawals",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma2/withdrawals",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Positive test for user history",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma/history",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"created_by":"admin"`,
+		},
+		{
+			name:               "Positive test for user history - no records",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma2/history",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Negative test user history - login not exist",
+			inputMetod:         http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma4/history",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Positive test for role change",
+			inputMetod:         http.MethodPut,
+			inputEndpoint:      "/api/admin/users/dimma/role",
+			inputBody:          `{ "role": "admin" }`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test role change - wrong JSON",
+			inputMetod:         http.MethodPut,
+			inputEndpoint:      "/api/admin/users/dimma/role",
+			inputBody:          `{ "role": "admin }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test role change - unknown role",
+			inputMetod:         http.MethodPut,
+			inputEndpoint:      "/api/admin/users/dimma/role",
+			inputBody:          `{ "role": "root" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test role change - login not exist",
+			inputMetod:         http.MethodPut,
+			inputEndpoint:      "/api/admin/users/dimma4/role",
+			inputBody:          `{ "role": "user" }`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+	s := &servicemock.AdminServiceMock{}
+	h := handlers.NewAdminHandler(s)
+	// маршрутизатор для разбора параметров пути
+	r := chi.NewRouter()
+	r.Get("/api/admin/users/{login}", h.User)
+	r.Get("/api/admin/users/{login}/orders", h.Orders)
+	r.Get("/api/admin/users/{login}/balance", h.Balance)
+	r.Get("/api/admin/users/{login}/withdrawals", h.Withdrawals)
+	r.Get("/api/admin/users/{login}/history", h.History)
+	r.Put("/api/admin/users/{login}/role", h.SetRole)
+	r.Get("/api/admin/orders/{number}", h.Order)
+	r.Get("/api/admin/orders/{number}/diagnostics", h.OrderDiagnostics)
+	r.Post("/api/admin/orders/{number}/repoll", h.Repoll)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(tCase.inputMetod, tCase.inputEndpoint, bytes.NewBufferString(tCase.inputBody))
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			r.ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tCase.expectedBody)
+			// ответ 204 передается без тела и без типа содержимого
+			if w.Code == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+				assert.Empty(t, w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandler_NewAdjustment(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputLogin         string
+		inputBody          string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for balance adjustment",
+			inputLogin:         "dimma",
+			inputBody:          `{ "operation": "credit", "amount": 100, "reason": "GOODWILL", "comment": "compensation" }`,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `600.5`,
+		},
+		{
+			name:               "Negative test balance adjustment - wrong JSON",
+			inputLogin:         "dimma",
+			inputBody:          `{ "operation": "credit", "amount": 100, "reason": "GOODWILL }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test balance adjustment - validation failed",
+			inputLogin:         "dimma",
+			inputBody:          `{ "operation": "credit", "amount": 100, "comment": "compensation" }`,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `"field":"reason"`,
+		},
+		{
+			name:               "Negative test balance adjustment - insufficient funds",
+			inputLogin:         "dimma",
+			inputBody:          `{ "operation": "debit", "amount": 1000, "reason": "FRAUD", "comment": "duplicate order" }`,
+			expectedStatusCode: http.StatusPaymentRequired,
+		},
+		{
+			name:               "Negative test balance adjustment - login not exist",
+			inputLogin:         "dimma4",
+			inputBody:          `{ "operation": "credit", "amount": 100, "reason": "GOODWILL", "comment": "compensation" }`,
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Negative test balance adjustment - server error",
+			inputLogin:         "dimmaServErr",
+			inputBody:          `{ "operation": "credit", "amount": 100, "reason": "GOODWILL", "comment": "compensation" }`,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.AdminServiceMock{}
+	h := handlers.NewAdminHandler(s)
+	// маршрутизатор для разбора параметров пути
+	r := chi.NewRouter()
+	r.Post("/api/admin/users/{login}/adjustments", h.NewAdjustment)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/admin/users/"+tCase.inputLogin+"/adjustments", bytes.NewBufferString(tCase.inputBody))
+			// контекст логина администратора
+			tkn := jwt.New()
+			tkn.Set(`login`, "admin")
+			tkn.Set(`role`, models.RoleAdmin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			r.ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tCase.expectedBody)
+		})
+	}
+}
@@ -49,7 +49,7 @@ func TestHandler_Status(t *testing.T) {
 			inputLogin:             "dimma2",
 			expectedStatusCode:     http.StatusInternalServerError,
 			expectedHeader1:        "Content-Type",
-			expectedHeaderContent1: "application/json",
+			expectedHeaderContent1: "application/problem+json",
 		},
 	}
 
@@ -195,7 +195,7 @@ func TestHandler_WithdrawalsList(t *testing.T) {
 			inputLogin:             "dimma2",
 			expectedStatusCode:     http.StatusNoContent,
 			expectedHeader1:        "Content-Type",
-			expectedHeaderContent1: "application/json",
+			expectedHeaderContent1: "",
 		},
 		{
 			name:                   "Negative test for user WithdrawalsList - InternalServerError",
@@ -204,7 +204,7 @@ func TestHandler_WithdrawalsList(t *testing.T) {
 			inputLogin:             "dimma3",
 			expectedStatusCode:     http.StatusInternalServerError,
 			expectedHeader1:        "Content-Type",
-			expectedHeaderContent1: "application/json",
+			expectedHeaderContent1: "application/problem+json",
 		},
 	}
 
@@ -240,3 +240,56 @@ func TestHandler_WithdrawalsList(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_History(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputLogin         string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for user history",
+			inputLogin:         "dimma",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"type":"MANUAL_ADJUSTMENT","debit_account":"system:adjustments","credit_account":"user:dimma"`,
+		},
+		{
+			name:               "Negative test for user history - NoContent",
+			inputLogin:         "dimma2",
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Negative test for user history - InternalServerError",
+			inputLogin:         "dimma3",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	s := &servicemock.BalanceServiceProvider{}
+	h := handlers.NewBalanceHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, "/api/user/history", nil)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск запроса
+			h.History(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tCase.expectedBody)
+			assert.NotContains(t, w.Body.String(), "created_by")
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers__test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Export(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name                string
+		inputEndpoint       string
+		inputLogin          string
+		inputAccept         string
+		handler             http.HandlerFunc
+		expectedStatusCode  int
+		expectedContentType string
+		expectedFilename    string
+		expectedRows        int
+		expectedTimeSuffix  string
+	}{
+		// определяем все тесты
+		{
+			name:                "Positive test for order export - CSV by default",
+			inputEndpoint:       "/api/user/orders/export",
+			inputLogin:          "dimma",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedFilename:    "orders.csv",
+			expectedRows:        4,
+		},
+		{
+			name:                "Positive test for order export - CSV in time zone",
+			inputEndpoint:       "/api/user/orders/export?format=csv&tz=Asia/Tokyo",
+			inputLogin:          "dimma",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedFilename:    "orders.csv",
+			expectedRows:        4,
+			expectedTimeSuffix:  "+09:00",
+		},
+		{
+			name:                "Positive test for order export - XLSX by Accept",
+			inputEndpoint:       "/api/user/orders/export",
+			inputLogin:          "dimma",
+			inputAccept:         "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			expectedFilename:    "orders.xlsx",
+			expectedRows:        4,
+		},
+		{
+			name:                "Positive test for order export - header only file for no orders",
+			inputEndpoint:       "/api/user/orders/export",
+			inputLogin:          "dimma2login",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv; charset=utf-8",
+			expectedFilename:    "orders.csv",
+			expectedRows:        1,
+		},
+		{
+			name:               "Negative test order export - unsupported format and time zone",
+			inputEndpoint:      "/api/user/orders/export?format=pdf&tz=Mars/Olympus",
+			inputLogin:         "dimma",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test order export - server error",
+			inputEndpoint:      "/api/user/orders/export",
+			inputLogin:         "dimma8",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Export,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+		{
+			name:                "Positive test for withdrawals export - XLSX",
+			inputEndpoint:       "/api/user/withdrawals/export?format=xlsx&tz=Europe/Moscow",
+			inputLogin:          "dimma",
+			handler:             handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsExport,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			expectedFilename:    "withdrawals.xlsx",
+			expectedRows:        3,
+		},
+		{
+			name:               "Negative test withdrawals export - unsupported status filter",
+			inputEndpoint:      "/api/user/withdrawals/export?status=NEW",
+			inputLogin:         "dimma",
+			handler:            handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsExport,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, tCase.inputEndpoint, nil)
+			request.Header.Set("Accept", tCase.inputAccept)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			tCase.handler(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			if tCase.expectedStatusCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tCase.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, `attachment; filename="`+tCase.expectedFilename+`"`, w.Header().Get("Content-Disposition"))
+			if strings.HasSuffix(tCase.expectedFilename, ".csv") {
+				records, err := csv.NewReader(w.Body).ReadAll()
+				assert.NoError(t, err)
+				assert.Len(t, records, tCase.expectedRows)
+				if tCase.expectedTimeSuffix != "" {
+					assert.True(t, strings.HasSuffix(records[1][3], tCase.expectedTimeSuffix))
+				}
+				return
+			}
+			// книга XLSX - архив zip с листом данных
+			body := w.Body.Bytes()
+			zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			assert.NoError(t, err)
+			var sheet bytes.Buffer
+			for _, f := range zr.File {
+				if f.Name == "xl/worksheets/sheet1.xml" {
+					rc, err := f.Open()
+					assert.NoError(t, err)
+					sheet.ReadFrom(rc)
+					rc.Close()
+				}
+			}
+			assert.Equal(t, tCase.expectedRows, strings.Count(sheet.String(), "<row "))
+		})
+	}
+}
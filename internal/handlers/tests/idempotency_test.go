@@ -0,0 +1,140 @@
+package handlers__test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_IdempotencyWrap(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат, тесты выполняются последовательно на одном хранилище ключей
+	tests := []struct {
+		name                 string
+		inputLogin           string
+		inputKey             string
+		inputBody            string
+		expectedStatusCode   int
+		expectedCalls        int
+		expectedReplayHeader string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test - first request is executed",
+			inputLogin:         "dimma",
+			inputKey:           "key-1",
+			inputBody:          "1235489802",
+			expectedStatusCode: http.StatusAccepted,
+			expectedCalls:      1,
+		},
+		{
+			name:                 "Positive test - duplicate request is replayed",
+			inputLogin:           "dimma",
+			inputKey:             "key-1",
+			inputBody:            "1235489802",
+			expectedStatusCode:   http.StatusAccepted,
+			expectedCalls:        1,
+			expectedReplayHeader: "true",
+		},
+		{
+			name:               "Negative test - key reused with another body",
+			inputLogin:         "dimma",
+			inputKey:           "key-1",
+			inputBody:          "2377225624",
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedCalls:      1,
+		},
+		{
+			name:               "Positive test - the same key of another login is executed",
+			inputLogin:         "dimma3",
+			inputKey:           "key-1",
+			inputBody:          "1235489802",
+			expectedStatusCode: http.StatusAccepted,
+			expectedCalls:      2,
+		},
+		{
+			name:               "Positive test - request without key is executed",
+			inputLogin:         "dimma",
+			inputBody:          "1235489802",
+			expectedStatusCode: http.StatusAccepted,
+			expectedCalls:      3,
+		},
+		{
+			name:               "Negative test - InternalServerError",
+			inputLogin:         "dimma2",
+			inputKey:           "key-1",
+			inputBody:          "1235489802",
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedCalls:      3,
+		},
+	}
+
+	s := &servicemock.IdempotencyServiceMock{}
+	h := handlers.NewIdempotencyHandler(s)
+	// обработчик, подсчитывающий количество выполнений
+	var calls int
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/user/orders", bytes.NewBufferString(tCase.inputBody))
+			if tCase.inputKey != "" {
+				request.Header.Set("Idempotency-Key", tCase.inputKey)
+			}
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			next(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Equal(t, tCase.expectedCalls, calls)
+			assert.Equal(t, tCase.expectedReplayHeader, w.Header().Get("Idempotent-Replayed"))
+		})
+	}
+}
+
+func TestHandler_IdempotencyWrapPanic(t *testing.T) {
+	s := &servicemock.IdempotencyServiceMock{}
+	h := handlers.NewIdempotencyHandler(s)
+	// обработчик, завершающийся паникой при первом выполнении
+	var calls int
+	next := h.Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failure")
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	// конфигурирование запроса с ключом и контекстом логина
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/api/user/orders", bytes.NewBufferString("1235489802"))
+		request.Header.Set("Idempotency-Key", "key-1")
+		tkn := jwt.New()
+		tkn.Set(`login`, "dimma")
+		return request.WithContext(jwtauth.NewContext(request.Context(), tkn, nil))
+	}
+	// паника передается дальше по цепочке обработчиков
+	assert.Panics(t, func() { next(httptest.NewRecorder(), newRequest()) })
+	// ключ освобожден, повторный запрос выполняется, а не получает 409
+	w := httptest.NewRecorder()
+	next(w, newRequest())
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 2, calls)
+}
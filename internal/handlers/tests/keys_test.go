@@ -0,0 +1,91 @@
+package handlers__test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/jwtkeys"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_JWKS(t *testing.T) {
+	keys, err := jwtkeys.NewHMAC([]byte("9e9e0b4e6de418b2f84fca35165571c5"))
+	assert.NoError(t, err)
+	h := handlers.NewKeysHandler(keys)
+	// конфигурирование запроса
+	request := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	// создание запроса
+	w := httptest.NewRecorder()
+	// запуск
+	h.JWKS(w, request)
+	// оценка результатов: секретный ключ не публикуется
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/jwk-set+json", w.Header().Get("Content-Type"))
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &jwks))
+	assert.Empty(t, jwks.Keys)
+}
+
+func TestHandler_Verifier(t *testing.T) {
+	keys, err := jwtkeys.NewHMAC([]byte("9e9e0b4e6de418b2f84fca35165571c5"))
+	assert.NoError(t, err)
+	other, err := jwtkeys.NewHMAC([]byte("another secret"))
+	assert.NoError(t, err)
+	_, validToken, err := keys.Encode(map[string]interface{}{"login": "dimma"})
+	assert.NoError(t, err)
+	_, foreignToken, err := other.Encode(map[string]interface{}{"login": "dimma"})
+	assert.NoError(t, err)
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputHeader        string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for token verification",
+			inputHeader:        "Bearer " + validToken,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test token verification - no token",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Negative test token verification - token signed by unknown key",
+			inputHeader:        "Bearer " + foreignToken,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+	h := handlers.NewKeysHandler(keys)
+	// защищенный обработчик
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, claims, _ := jwtauth.FromContext(r.Context())
+		assert.Equal(t, "dimma", claims["login"])
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			if tCase.inputHeader != "" {
+				request.Header.Set("Authorization", tCase.inputHeader)
+			}
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.Verifier(jwtauth.Authenticator(next)).ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/stretchr/testify/assert"
@@ -155,3 +156,61 @@ func TestHandler_List(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_Order(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputEndpoint      string
+		inputLogin         string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for order timeline",
+			inputEndpoint:      "/api/user/orders/9278923470",
+			inputLogin:         "dimma",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"history":[{"status":"NEW"`,
+		},
+		{
+			name:               "Negative test order timeline - order of another login",
+			inputEndpoint:      "/api/user/orders/9278923470",
+			inputLogin:         "dimma2login",
+			expectedStatusCode: http.StatusNotFound,
+		},
+		{
+			name:               "Negative test order timeline - any other internal error",
+			inputEndpoint:      "/api/user/orders/9278923470",
+			inputLogin:         "dimma8",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.OrderServiceMock{}
+	h := handlers.NewOrderHandler(s)
+	// маршрутизатор для разбора параметров пути
+	r := chi.NewRouter()
+	r.Get("/api/user/orders/{number}", h.Order)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, tCase.inputEndpoint, nil)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			r.ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), tCase.expectedBody)
+		})
+	}
+}
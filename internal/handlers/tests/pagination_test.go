@@ -0,0 +1,93 @@
+package handlers__test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ListPages(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputEndpoint      string
+		inputLogin         string
+		handler            http.HandlerFunc
+		expectedStatusCode int
+		expectedLink       string
+		expectedCursor     string
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for order list page - next page link keeps filters",
+			inputEndpoint:      "/api/user/orders?limit=1&status=PROCESSED&sort=desc",
+			inputLogin:         "dimma",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode: http.StatusOK,
+			expectedLink:       `</api/user/orders?after=cursor&limit=1&sort=desc&status=PROCESSED>; rel="next"`,
+			expectedCursor:     "cursor",
+		},
+		{
+			name:               "Positive test for order list - last page without link",
+			inputEndpoint:      "/api/user/orders?limit=10",
+			inputLogin:         "dimma",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test order list - invalid sort",
+			inputEndpoint:      "/api/user/orders?sort=random",
+			inputLogin:         "dimma",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `"rule":"oneof"`,
+		},
+		{
+			name:               "Positive test for withdrawals page - next page link",
+			inputEndpoint:      "/api/user/withdrawals?limit=1",
+			inputLogin:         "dimma",
+			handler:            handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsList,
+			expectedStatusCode: http.StatusOK,
+			expectedLink:       `</api/user/withdrawals?after=cursor&limit=1>; rel="next"`,
+			expectedCursor:     "cursor",
+		},
+		{
+			name:               "Negative test withdrawals - status filter not supported",
+			inputEndpoint:      "/api/user/withdrawals?status=NEW",
+			inputLogin:         "dimma",
+			handler:            handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsList,
+			expectedStatusCode: http.StatusBadRequest,
+			expectedBody:       `"rule":"unsupported"`,
+		},
+	}
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, tCase.inputEndpoint, nil)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			tCase.handler(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Equal(t, tCase.expectedLink, w.Header().Get("Link"))
+			assert.Equal(t, tCase.expectedCursor, w.Header().Get("X-Next-Cursor"))
+			assert.Contains(t, w.Body.String(), tCase.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package handlers__test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_Problem(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputMethod        string
+		inputEndpoint      string
+		inputLogin         string
+		inputBody          string
+		route              string
+		handler            http.Handler
+		expectedStatusCode int
+		expectedType       string
+		expectedDetail     string
+	}{
+		// определяем все тесты
+		{
+			name:               "Negative test order load - not digits, no Go error text in response",
+			inputMethod:        http.MethodPost,
+			inputEndpoint:      "/api/user/orders",
+			inputLogin:         "dimma",
+			inputBody:          "12a45",
+			route:              "/api/user/orders",
+			handler:            http.HandlerFunc(handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).Load),
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedType:       "/problems/invalid-order-number",
+			expectedDetail:     "order number must contain only digits",
+		},
+		{
+			name:               "Negative test new withdrawal - insufficient funds",
+			inputMethod:        http.MethodPost,
+			inputEndpoint:      "/api/user/balance/withdraw",
+			inputLogin:         "dimma",
+			inputBody:          `{"order": "2377225624", "sum": 1000}`,
+			route:              "/api/user/balance/withdraw",
+			handler:            http.HandlerFunc(handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).NewWithdrawal),
+			expectedStatusCode: http.StatusPaymentRequired,
+			expectedType:       "/problems/insufficient-funds",
+			expectedDetail:     "insufficient funds",
+		},
+		{
+			name:               "Negative test admin user - login not found",
+			inputMethod:        http.MethodGet,
+			inputEndpoint:      "/api/admin/users/nobody",
+			inputLogin:         "admin",
+			route:              "/api/admin/users/{login}",
+			handler:            http.HandlerFunc(handlers.NewAdminHandler(&servicemock.AdminServiceMock{}).User),
+			expectedStatusCode: http.StatusNotFound,
+			expectedType:       "/problems/login-not-found",
+			expectedDetail:     "login not exist",
+		},
+		{
+			name:               "Negative test admin user - internal error text is not returned",
+			inputMethod:        http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimmaServErr",
+			inputLogin:         "admin",
+			route:              "/api/admin/users/{login}",
+			handler:            http.HandlerFunc(handlers.NewAdminHandler(&servicemock.AdminServiceMock{}).User),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedType:       "/problems/internal-error",
+		},
+		{
+			name:               "Negative test admin route - user role",
+			inputMethod:        http.MethodGet,
+			inputEndpoint:      "/api/admin/users/dimma",
+			inputLogin:         "dimma",
+			route:              "/api/admin/users/{login}",
+			handler:            handlers.RequireRole(models.RoleAdmin)(http.HandlerFunc(handlers.NewAdminHandler(&servicemock.AdminServiceMock{}).User)),
+			expectedStatusCode: http.StatusForbidden,
+			expectedType:       "/problems/forbidden",
+			expectedDetail:     "role admin required",
+		},
+		{
+			name:               "Negative test authenticator - no token",
+			inputMethod:        http.MethodGet,
+			inputEndpoint:      "/api/user/balance",
+			route:              "/api/user/balance",
+			handler:            handlers.Authenticator(http.HandlerFunc(handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).Status)),
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedType:       "/problems/unauthorized",
+			expectedDetail:     "valid access token required",
+		},
+		{
+			name:          "Negative test handler panic - no panic text in response",
+			inputMethod:   http.MethodGet,
+			inputEndpoint: "/api/user/balance",
+			route:         "/api/user/balance",
+			handler: handlers.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				panic("something wrong with handler")
+			})),
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedType:       "/problems/internal-error",
+			expectedDetail:     "",
+		},
+		{
+			name:               "Negative test unknown path",
+			inputMethod:        http.MethodGet,
+			inputEndpoint:      "/api/user/unknown",
+			route:              "/api/user/balance",
+			handler:            http.HandlerFunc(handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).Status),
+			expectedStatusCode: http.StatusNotFound,
+			expectedType:       "/problems/not-found",
+			expectedDetail:     "no handler for this path",
+		},
+	}
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// маршрутизатор с идентификатором запроса и ответами 404 и 405 в формате RFC 7807
+			rout := chi.NewRouter()
+			rout.Use(middleware.RequestID)
+			rout.NotFound(handlers.NotFound)
+			rout.MethodNotAllowed(handlers.MethodNotAllowed)
+			// контекст логина, запрос без логина идет без токена
+			rout.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if tCase.inputLogin != "" {
+						tkn := jwt.New()
+						tkn.Set(`login`, tCase.inputLogin)
+						// роль совпадает с логином: admin - администратор
+						tkn.Set(`role`, tCase.inputLogin)
+						r = r.WithContext(jwtauth.NewContext(r.Context(), tkn, nil))
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+			rout.Method(tCase.inputMethod, tCase.route, tCase.handler)
+			// конфигурирование запроса
+			request := httptest.NewRequest(tCase.inputMethod, tCase.inputEndpoint, strings.NewReader(tCase.inputBody))
+			request.Header.Set("X-Request-Id", "req-42")
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			rout.ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+			assert.Equal(t, "req-42", w.Header().Get("X-Request-Id"))
+			var p models.Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			assert.Equal(t, tCase.expectedType, p.Type)
+			assert.NotEmpty(t, p.Title)
+			assert.Equal(t, tCase.expectedStatusCode, p.Status)
+			assert.Equal(t, tCase.expectedDetail, p.Detail)
+			assert.Equal(t, "req-42", p.RequestID)
+			assert.Equal(t, request.URL.Path, p.Instance)
+		})
+	}
+}
+
+func TestHandler_RecovererAbort(t *testing.T) {
+	// прерывание ответа передается серверу http без ответа 500
+	h := handlers.Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	request := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+	w := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { h.ServeHTTP(w, request) })
+	assert.Empty(t, w.Body.String())
+}
@@ -0,0 +1,137 @@
+package handlers__test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler_ListStreaming(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name                string
+		inputEndpoint       string
+		inputLogin          string
+		inputAccept         string
+		handler             http.HandlerFunc
+		expectedStatusCode  int
+		expectedContentType string
+		expectedItems       int
+		expectedTruncated   bool
+	}{
+		// определяем все тесты
+		{
+			name:                "Positive test for order list - JSON array",
+			inputEndpoint:       "/api/user/orders",
+			inputLogin:          "dimma",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json",
+			expectedItems:       3,
+		},
+		{
+			name:                "Positive test for order list - NDJSON",
+			inputEndpoint:       "/api/user/orders",
+			inputLogin:          "dimma",
+			inputAccept:         "application/x-ndjson",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedItems:       3,
+		},
+		{
+			name:                "Negative test order list - error after first order leaves array unterminated",
+			inputEndpoint:       "/api/user/orders",
+			inputLogin:          "dimmaStreamErr",
+			handler:             handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/json",
+			expectedTruncated:   true,
+		},
+		{
+			name:               "Positive test for order list - empty stream",
+			inputEndpoint:      "/api/user/orders",
+			inputLogin:         "dimma2login",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:               "Positive test for order list - empty NDJSON stream",
+			inputEndpoint:      "/api/user/orders",
+			inputLogin:         "dimma2login",
+			inputAccept:        "application/x-ndjson",
+			handler:            handlers.NewOrderHandler(&servicemock.OrderServiceMock{}).List,
+			expectedStatusCode: http.StatusNoContent,
+		},
+		{
+			name:                "Positive test for withdrawals - NDJSON",
+			inputEndpoint:       "/api/user/withdrawals",
+			inputLogin:          "dimma",
+			inputAccept:         "application/x-ndjson",
+			handler:             handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsList,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedItems:       2,
+		},
+		{
+			name:                "Positive test for withdrawals - NDJSON page",
+			inputEndpoint:       "/api/user/withdrawals?limit=1",
+			inputLogin:          "dimma",
+			inputAccept:         "application/x-ndjson",
+			handler:             handlers.NewBalanceHandler(&servicemock.BalanceServiceProvider{}).WithdrawalsList,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedItems:       1,
+		},
+	}
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, tCase.inputEndpoint, nil)
+			request.Header.Set("Accept", tCase.inputAccept)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			tCase.handler(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			assert.Equal(t, tCase.expectedContentType, w.Header().Get("Content-Type"))
+			body := w.Body.String()
+			switch {
+			case tCase.expectedTruncated:
+				// незавершенный массив не разбирается как JSON
+				assert.True(t, strings.HasPrefix(body, "[{"))
+				assert.False(t, json.Valid([]byte(body)))
+			case tCase.expectedStatusCode == http.StatusNoContent:
+				assert.Empty(t, body)
+			case tCase.expectedContentType == "application/x-ndjson":
+				// каждая строка - отдельный объект JSON
+				lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+				assert.Len(t, lines, tCase.expectedItems)
+				for _, line := range lines {
+					assert.True(t, json.Valid([]byte(line)))
+				}
+			default:
+				var items []map[string]interface{}
+				assert.NoError(t, json.Unmarshal([]byte(body), &items))
+				assert.Len(t, items, tCase.expectedItems)
+			}
+		})
+	}
+}
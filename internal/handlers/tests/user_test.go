@@ -6,9 +6,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers/servicemock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -42,6 +46,14 @@ func TestHandler_Create(t *testing.T) {
 			inputBody:          `{ "login": "dimma, "password": "12345" }`,
 			expectedStatusCode: http.StatusBadRequest,
 		},
+		{
+			name:                 "Negative test user registration - validation failed",
+			inputMetod:           "POST",
+			inputEndpoint:        "/api/user/register",
+			inputBody:            `{ "login": "", "password": "123" }`,
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedResponseBody: `{"type":"/problems/validation-failed","title":"Request validation failed","status":400,"detail":"validation failed: login.required, password.length","instance":"/api/user/register","violations":[{"field":"login","rule":"required","message":"login is required"},{"field":"password","rule":"length","message":"password must be at least 8 characters"}]}`,
+		},
 		{
 			name:               "Negative test user registration - login exist",
 			inputMetod:         "POST",
@@ -74,6 +86,10 @@ func TestHandler_Create(t *testing.T) {
 			assert.Equal(t, tCase.expectedStatusCode, w.Code)
 			if w.Code == http.StatusOK {
 				assert.Contains(t, w.Header().Get(tCase.expectedHeader2), tCase.expectedHeaderContent2)
+				assert.Contains(t, w.Body.String(), `"refresh_token"`)
+			}
+			if tCase.expectedResponseBody != "" {
+				assert.JSONEq(t, tCase.expectedResponseBody, w.Body.String())
 			}
 
 		})
@@ -117,6 +133,15 @@ func TestHandler_CheckAuthorization(t *testing.T) {
 			inputBody:          `{ "login": "dimma3login", "password": "12345" }`,
 			expectedStatusCode: http.StatusUnauthorized,
 		},
+		{
+			name:                   "Negative test user login - too many failed attempts",
+			inputMetod:             http.MethodPost,
+			inputEndpoint:          "/api/user/login",
+			inputBody:              `{ "login": "dimma5login", "password": "12345" }`,
+			expectedStatusCode:     http.StatusTooManyRequests,
+			expectedHeader2:        "Retry-After",
+			expectedHeaderContent2: "91",
+		},
 		{
 			name:               "Negative test user registration - server error",
 			inputMetod:         http.MethodPost,
@@ -139,10 +164,370 @@ func TestHandler_CheckAuthorization(t *testing.T) {
 			h.CheckAuthorization(w, request)
 			// оценка результатов
 			assert.Equal(t, tCase.expectedStatusCode, w.Code)
-			if w.Code == http.StatusOK {
+			if w.Code == http.StatusOK || w.Code == http.StatusTooManyRequests {
 				assert.Contains(t, w.Header().Get(tCase.expectedHeader2), tCase.expectedHeaderContent2)
 			}
 
 		})
 	}
 }
+
+func TestHandler_Refresh(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputBody          string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for token refresh",
+			inputBody:          `{ "refresh_token": "refresh-dimma" }`,
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `"refresh_token":"refresh-dimma-2"`,
+		},
+		{
+			name:               "Negative test token refresh - wrong JSON",
+			inputBody:          `{ "refresh_token": "refresh-dimma }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test token refresh - empty token",
+			inputBody:          `{ "refresh_token": "" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test token refresh - token revoked",
+			inputBody:          `{ "refresh_token": "refresh-revoked" }`,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Negative test token refresh - server error",
+			inputBody:          `{ "refresh_token": "refresh-err" }`,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/user/token/refresh", bytes.NewBufferString(tCase.inputBody))
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.Refresh(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			if w.Code == http.StatusOK {
+				assert.Equal(t, "Bearer access-dimma", w.Header().Get("Authorization"))
+				assert.Contains(t, w.Body.String(), tCase.expectedBody)
+			}
+		})
+	}
+}
+
+func TestHandler_Logout(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputLogin         string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for logout",
+			inputLogin:         "dimma",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test logout - server error",
+			inputLogin:         "dimma8",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			tkn.Set(jwt.JwtIDKey, "jti-"+tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.Logout(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_RevocationCheck(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputJTI           string
+		inputIssuedAt      time.Time
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for revocation check - token is active",
+			inputJTI:           "active",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Positive test for revocation check - token without jti",
+			inputJTI:           "",
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test revocation check - token revoked",
+			inputJTI:           "revoked",
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Negative test revocation check - token issued earlier in the same second as password change",
+			inputJTI:           "changed",
+			inputIssuedAt:      time.Date(2020, time.May, 15, 17, 45, 12, 400000000, time.UTC),
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+		{
+			name:               "Positive test for revocation check - token issued later in the same second as password change",
+			inputJTI:           "changed",
+			inputIssuedAt:      time.Date(2020, time.May, 15, 17, 45, 12, 600000000, time.UTC),
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test revocation check - server error",
+			inputJTI:           "dberror",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+	// защищенный обработчик
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodGet, "/api/user/orders", nil)
+			// контекст токена
+			tkn := jwt.New()
+			tkn.Set(`login`, "dimma")
+			if tCase.inputJTI != "" {
+				tkn.Set(jwt.JwtIDKey, tCase.inputJTI)
+			}
+			// время выдачи в секундах и в микросекундах, как в токенах сервиса
+			if !tCase.inputIssuedAt.IsZero() {
+				tkn.Set(jwt.IssuedAtKey, tCase.inputIssuedAt.Unix())
+				tkn.Set(models.ClaimIssuedAtMicro, tCase.inputIssuedAt.UnixMicro())
+			}
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.RevocationCheck(next).ServeHTTP(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_ChangePassword(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputLogin         string
+		inputBody          string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for password change",
+			inputLogin:         "dimma",
+			inputBody:          `{ "old_password": "Secret123", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test password change - wrong JSON",
+			inputLogin:         "dimma",
+			inputBody:          `{ "old_password": "Secret123", "new_password": "Secret456 }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password change - validation failed",
+			inputLogin:         "dimma",
+			inputBody:          `{ "old_password": "Secret123", "new_password": "short" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password change - wrong old password",
+			inputLogin:         "dimma",
+			inputBody:          `{ "old_password": "wrong", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusForbidden,
+		},
+		{
+			name:               "Negative test password change - locked",
+			inputLogin:         "dimma",
+			inputBody:          `{ "old_password": "locked", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "Negative test password change - server error",
+			inputLogin:         "dimma8",
+			inputBody:          `{ "old_password": "Secret123", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPut, "/api/user/password", bytes.NewBufferString(tCase.inputBody))
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, tCase.inputLogin)
+			rctx := jwtauth.NewContext(request.Context(), tkn, nil)
+			request = request.WithContext(rctx)
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.ChangePassword(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+			if w.Code == http.StatusOK {
+				assert.Equal(t, "Bearer access-dimma", w.Header().Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestHandler_RequestPasswordReset(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputBody          string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for password reset request",
+			inputBody:          `{ "login": "dimma" }`,
+			expectedStatusCode: http.StatusAccepted,
+		},
+		{
+			name:               "Negative test password reset request - wrong JSON",
+			inputBody:          `{ "login": "dimma }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password reset request - empty login",
+			inputBody:          `{ "login": "" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password reset request - too many requests",
+			inputBody:          `{ "login": "dimmaLocked" }`,
+			expectedStatusCode: http.StatusTooManyRequests,
+		},
+		{
+			name:               "Negative test password reset request - server error",
+			inputBody:          `{ "login": "dimmaServErr" }`,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/user/password/reset", bytes.NewBufferString(tCase.inputBody))
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.RequestPasswordReset(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
+
+func TestHandler_ConfirmPasswordReset(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		inputBody          string
+		expectedStatusCode int
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for password reset confirmation",
+			inputBody:          `{ "token": "reset-dimma", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "Negative test password reset confirmation - wrong JSON",
+			inputBody:          `{ "token": "reset-dimma", "new_password": "Secret456 }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password reset confirmation - validation failed",
+			inputBody:          `{ "token": "reset-dimma", "new_password": "Secretabc" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password reset confirmation - token used",
+			inputBody:          `{ "token": "reset-used", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			name:               "Negative test password reset confirmation - server error",
+			inputBody:          `{ "token": "reset-err", "new_password": "Secret456" }`,
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+	s := &servicemock.UserServiceMock{}
+	h := handlers.NewUserHandler(s)
+
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			// конфигурирование запроса
+			request := httptest.NewRequest(http.MethodPost, "/api/user/password/reset/confirm", bytes.NewBufferString(tCase.inputBody))
+			// создание запроса
+			w := httptest.NewRecorder()
+			// запуск
+			h.ConfirmPasswordReset(w, request)
+			// оценка результатов
+			assert.Equal(t, tCase.expectedStatusCode, w.Code)
+		})
+	}
+}
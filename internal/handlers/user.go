@@ -4,20 +4,30 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 
 	"github.com/rs/zerolog/log"
 )
 
 // интерфейс методов бизнес логики User
 type UserServiceProvider interface {
-	Create(ctx context.Context, dc models.DecodeLoginPair) (err error)
-	CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair) (err error)
+	Create(ctx context.Context, dc models.DecodeLoginPair) (login string, err error)
+	CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair, ip string) (login string, err error)
+	IssueTokens(ctx context.Context, login string) (pair models.TokenPair, err error)
+	RefreshTokens(ctx context.Context, refreshToken string) (pair models.TokenPair, err error)
+	Logout(ctx context.Context, login string, jti string, expiresAt time.Time) (err error)
+	IsRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error)
+	ChangePassword(ctx context.Context, login string, oldPassword string, newPassword string, ip string) (err error)
+	RequestPasswordReset(ctx context.Context, login string, ip string) (err error)
+	ConfirmPasswordReset(ctx context.Context, token string, newPassword string) (err error)
 }
 
 // структура для конструктура обработчика User
@@ -43,32 +53,19 @@ func (handler UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 	err := json.NewDecoder(r.Body).Decode(&dc)
 	if err != nil {
 		log.Printf("Unmarshal error: %s", err)
-		http.Error(w, "invalid JSON structure received", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
 		return
 	}
 	// пишем пару логин:пароль в хранилище
-	err = handler.service.Create(ctx, dc)
+	login, err := handler.service.Create(ctx, dc)
+	// если логин или пароль не соответствуют правилам - 400 со списком нарушенных правил
 	// если логин существует возвращаем статус 409, если иная ошибка - 500, если без ошибок - 200
 	switch {
-	case err != nil && strings.Contains(err.Error(), "login exist"):
-		w.WriteHeader(http.StatusConflict)
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
-		// создаем токен
-		claims := map[string]interface{}{"login": dc.Login}
-		// устанавливаем время жизни токена
-		jwtauth.SetExpiryIn(claims, settings.TokenTTL)
-		_, tokenString, err := settings.TokenAuth.Encode(map[string]interface{}{"login": dc.Login})
-		if err != nil {
-			log.Printf("tokenAuth.Encode error HandlerCreate: %s", err)
-			http.Error(w, "login handling error", http.StatusInternalServerError)
-			return
-		}
-		// помещаем токен в заголовок
-		w.Header().Set("Authorization", "Bearer "+tokenString)
-		// возвращаем пользователю
-		w.WriteHeader(http.StatusOK)
+		// выдаем пару токенов
+		handler.writeTokens(ctx, w, r, login)
 	}
 }
 
@@ -83,31 +80,244 @@ func (handler UserHandler) CheckAuthorization(w http.ResponseWriter, r *http.Req
 	err := json.NewDecoder(r.Body).Decode(&dc)
 	if err != nil {
 		log.Printf("unmarshal error HandlerCheckAuthorization: %s", err)
-		http.Error(w, "invalid JSON structure received", http.StatusBadRequest)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
 		return
 	}
 	// проверяем пару логин/пароль в хранилище
-	err = handler.service.CheckAuthorization(ctx, dc)
+	login, err := handler.service.CheckAuthorization(ctx, dc, clientIP(r))
 	// если логин существует и пароль ок возвращаем статус 200, если иная ошибка - 500, если пара неверна - 401
+	// если вход временно заблокирован - 429 с заголовком Retry-After
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		// выдаем пару токенов
+		handler.writeTokens(ctx, w, r, login)
+	}
+}
+
+// обновление пары токенов по токену обновления
+func (handler UserHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// десериализация тела запроса с токеном обновления
+	dc := models.DecodeRefreshToken{}
+	err := json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil || dc.RefreshToken == "" {
+		log.Printf("unmarshal error HandlerRefresh: %v", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	// заменяем токен обновления на новую пару токенов
+	pair, err := handler.service.RefreshTokens(ctx, dc.RefreshToken)
+	// если токен не найден, отозван или истек - 401, если иная ошибка - 500, если без ошибок - 200
 	switch {
-	case err != nil && strings.Contains(err.Error(), "login or password not exist"):
-		w.WriteHeader(http.StatusUnauthorized)
 	case err != nil:
-		w.WriteHeader(http.StatusInternalServerError)
+		writeError(w, r, err)
 	default:
-		// создаем токен
-		claims := map[string]interface{}{"login": dc.Login}
-		// устанавливаем время жизни токена
-		jwtauth.SetExpiryIn(claims, settings.TokenTTL)
-		_, tokenString, err := settings.TokenAuth.Encode(claims)
-		if err != nil {
-			log.Printf("tokenAuth.Encode error HandlerCheckAuthorization: %s", err)
-			http.Error(w, "login handling error", http.StatusInternalServerError)
+		writeTokenPair(w, pair)
+	}
+}
+
+// выход пользователя: отзыв токенов обновления и текущего токена доступа
+func (handler UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем токен и значение claims из контекста запроса
+	token, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil || token == nil {
+		log.Printf("FromContext error HandlerLogout: %v", err)
+		writeProblem(w, r, problemInternal, "logout handling error")
+		return
+	}
+	// получаем значение login из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerLogout: %v", err)
+		writeProblem(w, r, problemInternal, "logout handling error")
+		return
+	}
+	// отзываем токены пользователя
+	err = handler.service.Logout(ctx, login, token.JwtID(), token.Expiration())
+	if err != nil {
+		writeProblem(w, r, problemInternal, "")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RevocationCheck - middleware проверки токена доступа по списку отозванных токенов и времени смены пароля и роли
+// подключается после jwtauth.Verifier, запросы с отозванным токеном получают 401
+func (handler UserHandler) RevocationCheck(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, claims, err := jwtauth.FromContext(r.Context())
+		// невалидный токен обрабатывается Authenticator
+		if err != nil || token == nil {
+			next.ServeHTTP(w, r)
 			return
 		}
-		// поещаем токен в заголовок
-		w.Header().Set("Authorization", "Bearer "+tokenString)
-		// возвращаем ответ
+		login, _ := claims["login"].(string)
+		// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+		ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+		// освобождаем ресурс
+		defer cancel()
+		revoked, err := handler.service.IsRevoked(ctx, login, token.JwtID(), issuedAt(token))
+		switch {
+		case err != nil:
+			writeProblem(w, r, problemInternal, "")
+		case revoked:
+			writeProblem(w, r, problemUnauthorized, "")
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// смена пароля пользователя: ранее выданные токены становятся недействительными, в ответе новая пара токенов
+func (handler UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// получаем значение claims из контекста запроса
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		log.Printf("FromContext error HandlerChangePassword: %s", err)
+		writeProblem(w, r, problemInternal, "password handling error")
+		return
+	}
+	// получаем значение login из интерфейса
+	login, ok := claims["login"].(string)
+	if !ok {
+		log.Printf("interface assertion error HandlerChangePassword: %v", err)
+		writeProblem(w, r, problemInternal, "password handling error")
+		return
+	}
+	// десериализация тела запроса
+	dc := models.DecodePasswordChange{}
+	err = json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil {
+		log.Printf("unmarshal error HandlerChangePassword: %s", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	// меняем пароль
+	err = handler.service.ChangePassword(ctx, login, dc.OldPassword, dc.NewPassword, clientIP(r))
+	// если новый пароль не соответствует правилам - 400, если неверный текущий пароль - 403,
+	// если превышено количество неверных попыток - 429, если иная ошибка - 500
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
+		// выдаем новую пару токенов
+		handler.writeTokens(ctx, w, r, login)
+	}
+}
+
+// запрос сброса пароля: токен сброса отправляется пользователю, ответ не зависит от наличия логина
+func (handler UserHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// десериализация тела запроса
+	dc := models.DecodePasswordReset{}
+	err := json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil || dc.Login == "" {
+		log.Printf("unmarshal error HandlerRequestPasswordReset: %v", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	err = handler.service.RequestPasswordReset(ctx, dc.Login, clientIP(r))
+	// если превышено количество запросов сброса - 429, если иная ошибка - 500
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// установка нового пароля по токену сброса
+func (handler UserHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	// наследуем контекcт запроса r *http.Request, оснащая его Timeout
+	ctx, cancel := context.WithTimeout(r.Context(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// десериализация тела запроса
+	dc := models.DecodePasswordResetConfirm{}
+	err := json.NewDecoder(r.Body).Decode(&dc)
+	if err != nil || dc.Token == "" {
+		log.Printf("unmarshal error HandlerConfirmPasswordReset: %v", err)
+		writeProblem(w, r, problemInvalidJSON, "invalid JSON structure received")
+		return
+	}
+	err = handler.service.ConfirmPasswordReset(ctx, dc.Token, dc.NewPassword)
+	// если токен не найден, использован или истек - 400, если пароль не соответствует правилам - 400 со списком правил
+	switch {
+	case err != nil:
+		writeError(w, r, err)
+	default:
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+// выдача пары токенов пользователю: токен доступа в заголовке Authorization и пара токенов в теле ответа
+func (handler UserHandler) writeTokens(ctx context.Context, w http.ResponseWriter, r *http.Request, login string) {
+	pair, err := handler.service.IssueTokens(ctx, login)
+	if err != nil {
+		log.Printf("IssueTokens error HandlerWriteTokens: %s", err)
+		writeProblem(w, r, problemInternal, "login handling error")
+		return
+	}
+	writeTokenPair(w, pair)
+}
+
+// запись пары токенов в ответ со статусом 200
+func writeTokenPair(w http.ResponseWriter, pair models.TokenPair) {
+	// помещаем токен в заголовок
+	w.Header().Set("Authorization", "Bearer "+pair.AccessToken)
+	// устанавливаем заголовок
+	w.Header().Set("Content-Type", "application/json")
+	// возвращаем пользователю
+	w.WriteHeader(http.StatusOK)
+	// сериализуем и пишем тело ответа
+	json.NewEncoder(w).Encode(pair)
+}
+
+// IP адрес клиента для учета попыток входа
+// заголовки X-Forwarded-For и X-Real-IP учитываются только при работе за доверенным прокси
+func clientIP(r *http.Request) string {
+	if settings.TrustProxyHeaders {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			return strings.TrimSpace(strings.Split(xff, ",")[0])
+		}
+		if xrip := r.Header.Get("X-Real-IP"); xrip != "" {
+			return strings.TrimSpace(xrip)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// время выдачи токена с точностью до микросекунды по claim models.ClaimIssuedAtMicro,
+// для токенов без этого claim - время выдачи iat с точностью до секунды
+func issuedAt(token jwt.Token) time.Time {
+	switch v, _ := token.Get(models.ClaimIssuedAtMicro); us := v.(type) {
+	case int64:
+		return time.UnixMicro(us).UTC()
+	case float64:
+		return time.UnixMicro(int64(us)).UTC()
+	case json.Number:
+		if n, err := us.Int64(); err == nil {
+			return time.UnixMicro(n).UTC()
+		}
+	}
+	return token.IssuedAt()
+}
@@ -0,0 +1,106 @@
+package httprequest
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// состояния автоматического выключателя
+const (
+	StateClosed = iota
+	StateOpen
+	StateHalfOpen
+)
+
+// ошибка отказа в запросе при разомкнутом выключателе
+var ErrCircuitOpen = errors.New("accrual system circuit breaker is open")
+
+// CircuitBreaker - автоматический выключатель запросов к внешнему сервису
+// closed - запросы выполняются, после failureThreshold ошибок подряд выключатель размыкается
+// open - запросы отклоняются без обращения к сервису до истечения coolDown
+// half-open - выполняется одна пробная попытка, успех замыкает выключатель, ошибка снова размыкает
+type CircuitBreaker struct {
+	mu               sync.Mutex
+	state            int
+	failures         int
+	failureThreshold int
+	coolDown         time.Duration
+	openedAt         time.Time
+	trial            bool
+	now              func() time.Time
+}
+
+// NewCircuitBreaker - конструктор автоматического выключателя
+func NewCircuitBreaker(failureThreshold int, coolDown time.Duration) *CircuitBreaker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &CircuitBreaker{
+		state:            StateClosed,
+		failureThreshold: failureThreshold,
+		coolDown:         coolDown,
+		now:              time.Now,
+	}
+}
+
+// WithClock задает источник текущего времени для отсчета coolDown, используется в тестах
+func (cb *CircuitBreaker) WithClock(now func() time.Time) *CircuitBreaker {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.now = now
+	return cb
+}
+
+// Allow проверяет, можно ли выполнить запрос, при разомкнутом выключателе возвращает ErrCircuitOpen
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case StateOpen:
+		// по истечении времени ожидания переходим в полуоткрытое состояние
+		if cb.now().Sub(cb.openedAt) < cb.coolDown {
+			return ErrCircuitOpen
+		}
+		cb.state = StateHalfOpen
+		cb.trial = true
+		return nil
+	case StateHalfOpen:
+		// пропускаем только одну пробную попытку
+		if cb.trial {
+			return ErrCircuitOpen
+		}
+		cb.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Success фиксирует успешный запрос и замыкает выключатель
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.state = StateClosed
+	cb.failures = 0
+	cb.trial = false
+}
+
+// Failure фиксирует ошибку запроса и размыкает выключатель при превышении порога ошибок или неудачной пробной попытке
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	if cb.state == StateHalfOpen || cb.failures >= cb.failureThreshold {
+		cb.state = StateOpen
+		cb.openedAt = cb.now()
+		cb.trial = false
+	}
+}
+
+// State возвращает текущее состояние выключателя
+func (cb *CircuitBreaker) State() int {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
@@ -1,3 +1,4 @@
+// пакет запросов к внешней системе расчета начислений баллов лояльности
 package httprequest
 
 import (
@@ -5,7 +6,11 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 )
 
 type Request interface {
@@ -13,10 +18,11 @@ type Request interface {
 	RequestPost(orderNum string) (err error)
 }
 
-func NewHTTPRequst(BaseURL *url.URL) *HTTPRequest { //, httpClient *http.Client, CalcSys string) {
+func NewHTTPRequst(BaseURL *url.URL) *HTTPRequest {
 	return &HTTPRequest{
 		BaseURL:    BaseURL,
-		httpClient: http.DefaultClient,
+		httpClient: &http.Client{Timeout: settings.AccrualClientTimeout},
+		breaker:    NewCircuitBreaker(settings.BreakerFailureThreshold, settings.BreakerCoolDown),
 	}
 }
 
@@ -24,32 +30,75 @@ func NewHTTPRequst(BaseURL *url.URL) *HTTPRequest { //, httpClient *http.Client,
 type HTTPRequest struct {
 	BaseURL    *url.URL
 	httpClient *http.Client
+	breaker    *CircuitBreaker
 }
 
 func (cl *HTTPRequest) RequestPost(orderNum string) (err error) {
+	// проверяем автоматический выключатель
+	if err = cl.breaker.Allow(); err != nil {
+		return err
+	}
 	// создание JSON для запроса в систему начисления баллов
 	bodyJSON := fmt.Sprintf("{\"order\":\"%s\"}", orderNum)
 	// запрос регистрации заказа в системе расчета баллов
 	rPost, err := cl.httpClient.Post(cl.BaseURL.String(), "application/json", strings.NewReader(bodyJSON))
 	if err != nil {
 		log.Printf("http Post request in ServiceNewOrderLoad error:%s", err)
-	}
-	// проверяем статус ответа внешнего сервиса
-	if rPost.StatusCode != http.StatusAccepted {
-		log.Printf("http POST request has wromg status: %s", rPost.Status)
+		cl.breaker.Failure()
+		return err
 	}
 	// освобождаем ресурс
 	defer rPost.Body.Close()
-	return err
+	// ошибки сервера внешнего сервиса учитываем в выключателе
+	if rPost.StatusCode >= http.StatusInternalServerError {
+		cl.breaker.Failure()
+		return fmt.Errorf("http POST request has wrong status: %s", rPost.Status)
+	}
+	cl.breaker.Success()
+	// проверяем статус ответа внешнего сервиса: 202 - заказ принят к расчету, 409 - заказ уже зарегистрирован
+	switch rPost.StatusCode {
+	case http.StatusAccepted, http.StatusConflict:
+		return nil
+	// превышена частота запросов, время ожидания передается воркеру для паузы всех запросов к сервису
+	case http.StatusTooManyRequests:
+		retryAfter, _ := strconv.Atoi(rPost.Header.Get("Retry-After"))
+		return &RetryAfterError{RetryAfter: time.Duration(retryAfter) * time.Second}
+	// заказ не зарегистрирован, задача остается в очереди для повторной регистрации
+	default:
+		log.Printf("http POST request has wrong status: %s", rPost.Status)
+		return fmt.Errorf("http POST request has wrong status: %s", rPost.Status)
+	}
+}
+
+// RetryAfterError - внешний сервис ограничил частоту запросов, RetryAfter - время ожидания из заголовка
+// Retry-After, 0 - заголовок отсутствует или не содержит число секунд
+type RetryAfterError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("accrual system rate limit exceeded, retry after %s", e.RetryAfter)
 }
 
 func (cl *HTTPRequest) RequestGet(orderNum string) (rsp *http.Response, err error) {
+	// проверяем автоматический выключатель
+	if err = cl.breaker.Allow(); err != nil {
+		return nil, err
+	}
 	// создаем линк обновления статуса заказов в внешнем сервисе
 	linkUpd := fmt.Sprintf("%s/%s", cl.BaseURL.String(), orderNum)
 	// делаем запрос во внешний сервис
 	rsp, err = cl.httpClient.Get(linkUpd)
 	if err != nil {
 		log.Printf("remoute service error: %s", err)
+		cl.breaker.Failure()
+		return rsp, err
+	}
+	// ошибки сервера внешнего сервиса учитываем в выключателе, ответ возвращаем для обработки статуса
+	if rsp.StatusCode >= http.StatusInternalServerError {
+		cl.breaker.Failure()
+	} else {
+		cl.breaker.Success()
 	}
 	return rsp, err
 }
@@ -0,0 +1,162 @@
+package httprequest__test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+// шаг сценария автоматического выключателя
+type breakerStep struct {
+	// сдвиг часов перед шагом
+	advance time.Duration
+	// результат запроса, если запрос разрешен: true - успех, false - ошибка
+	success bool
+	// ожидаемый результат Allow и состояние после шага
+	wantErr   error
+	wantState int
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и сценарий
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		// определяем все тесты
+		{
+			name: "closed to open after threshold failures in a row",
+			steps: []breakerStep{
+				{success: false, wantState: httprequest.StateClosed},
+				{success: false, wantState: httprequest.StateClosed},
+				{success: false, wantState: httprequest.StateOpen},
+				{wantErr: httprequest.ErrCircuitOpen, wantState: httprequest.StateOpen},
+			},
+		},
+		{
+			name: "success resets failures counter in closed state",
+			steps: []breakerStep{
+				{success: false, wantState: httprequest.StateClosed},
+				{success: false, wantState: httprequest.StateClosed},
+				{success: true, wantState: httprequest.StateClosed},
+				{success: false, wantState: httprequest.StateClosed},
+				{success: false, wantState: httprequest.StateClosed},
+			},
+		},
+		{
+			name: "open rejects requests until cool-down expires",
+			steps: []breakerStep{
+				{success: false}, {success: false}, {success: false, wantState: httprequest.StateOpen},
+				{advance: 29 * time.Second, wantErr: httprequest.ErrCircuitOpen, wantState: httprequest.StateOpen},
+			},
+		},
+		{
+			name: "half-open trial success closes breaker",
+			steps: []breakerStep{
+				{success: false}, {success: false}, {success: false, wantState: httprequest.StateOpen},
+				{advance: 30 * time.Second, success: true, wantState: httprequest.StateClosed},
+				{success: true, wantState: httprequest.StateClosed},
+			},
+		},
+		{
+			name: "half-open trial failure opens breaker for another cool-down",
+			steps: []breakerStep{
+				{success: false}, {success: false}, {success: false, wantState: httprequest.StateOpen},
+				{advance: 30 * time.Second, success: false, wantState: httprequest.StateOpen},
+				{advance: 29 * time.Second, wantErr: httprequest.ErrCircuitOpen, wantState: httprequest.StateOpen},
+				{advance: time.Second, success: true, wantState: httprequest.StateClosed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+			cb := httprequest.NewCircuitBreaker(3, 30*time.Second).WithClock(func() time.Time { return now })
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				err := cb.Allow()
+				assert.Equal(t, step.wantErr, err, "step %d", i)
+				if err == nil {
+					if step.success {
+						cb.Success()
+					} else {
+						cb.Failure()
+					}
+				}
+				assert.Equal(t, step.wantState, cb.State(), "step %d", i)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_HalfOpenSingleTrial(t *testing.T) {
+	now := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	cb := httprequest.NewCircuitBreaker(1, time.Minute).WithClock(func() time.Time { return now })
+	cb.Failure()
+	now = now.Add(time.Minute)
+	// пробная попытка разрешена, остальные запросы отклоняются до ее результата
+	assert.NoError(t, cb.Allow())
+	assert.Equal(t, httprequest.StateHalfOpen, cb.State())
+	assert.Equal(t, httprequest.ErrCircuitOpen, cb.Allow())
+}
+
+func TestHTTPRequest_CircuitOpen(t *testing.T) {
+	// порог ошибок для выключателя, создаваемого NewHTTPRequst
+	threshold := settings.BreakerFailureThreshold
+	settings.BreakerFailureThreshold = 2
+	defer func() { settings.BreakerFailureThreshold = threshold }()
+	// определяем структуру теста
+	// создаём массив тестов: имя и запрос к внешнему сервису
+	tests := []struct {
+		name    string
+		request func(cl *httprequest.HTTPRequest) error
+	}{
+		{
+			name: "RequestPost returns ErrCircuitOpen without calling service",
+			request: func(cl *httprequest.HTTPRequest) error {
+				return cl.RequestPost("2377225624")
+			},
+		},
+		{
+			name: "RequestGet returns ErrCircuitOpen without calling service",
+			request: func(cl *httprequest.HTTPRequest) error {
+				rsp, err := cl.RequestGet("2377225624")
+				if err == nil {
+					rsp.Body.Close()
+				}
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer srv.Close()
+			baseURL, _ := url.Parse(srv.URL)
+			cl := httprequest.NewHTTPRequst(baseURL)
+			// ответы 500 до порога выключателя, запросы выполняются
+			for i := 0; i < settings.BreakerFailureThreshold; i++ {
+				err := tt.request(cl)
+				assert.False(t, errors.Is(err, httprequest.ErrCircuitOpen))
+			}
+			// выключатель разомкнут, сервис не вызывается
+			err := tt.request(cl)
+			assert.True(t, errors.Is(err, httprequest.ErrCircuitOpen))
+			assert.Equal(t, settings.BreakerFailureThreshold, calls)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// тесты запросов к системе расчета баллов лояльности
+package httprequest__test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPRequest_RequestPost(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		statusCode         int
+		retryAfter         string
+		expectedError      bool
+		expectedRetryAfter time.Duration
+	}{
+		// определяем все тесты
+		{
+			name:       "Positive test for order registration - 202 accepted",
+			statusCode: http.StatusAccepted,
+		},
+		{
+			name:       "Positive test for order registration - 409 already registered",
+			statusCode: http.StatusConflict,
+		},
+		{
+			name:               "Negative test for order registration - 429 with Retry-After",
+			statusCode:         http.StatusTooManyRequests,
+			retryAfter:         "60",
+			expectedError:      true,
+			expectedRetryAfter: 60 * time.Second,
+		},
+		{
+			name:          "Negative test for order registration - 429 without Retry-After",
+			statusCode:    http.StatusTooManyRequests,
+			expectedError: true,
+		},
+		{
+			name:          "Negative test for order registration - 400 bad request",
+			statusCode:    http.StatusBadRequest,
+			expectedError: true,
+		},
+		{
+			name:          "Negative test for order registration - 500 internal error",
+			statusCode:    http.StatusInternalServerError,
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer srv.Close()
+			baseURL, _ := url.Parse(srv.URL)
+			err := httprequest.NewHTTPRequst(baseURL).RequestPost("2377225624")
+			assert.Equal(t, tt.expectedError, err != nil)
+			// ошибка 429 передает время ожидания из Retry-After
+			var retryAfterErr *httprequest.RetryAfterError
+			if errors.As(err, &retryAfterErr) {
+				assert.Equal(t, tt.expectedRetryAfter, retryAfterErr.RetryAfter)
+			} else {
+				assert.Zero(t, tt.expectedRetryAfter)
+			}
+		})
+	}
+}
@@ -3,39 +3,74 @@ package httprouter
 
 import (
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
-	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/jwtauth/v5"
 )
 
 // маршрутизатор запросов
-func NewRouter(userHandler *handlers.UserHandler, orderHandler *handlers.OrderHandler, balanceHandler *handlers.BalanceHandler) chi.Router {
+func NewRouter(userHandler *handlers.UserHandler, orderHandler *handlers.OrderHandler, balanceHandler *handlers.BalanceHandler, idempotencyHandler *handlers.IdempotencyHandler, keysHandler *handlers.KeysHandler, adminHandler *handlers.AdminHandler) chi.Router {
 	// chi роутер
 	rout := chi.NewRouter()
 
 	// зададим встроенные middleware, чтобы улучшить стабильность приложения
+	// идентификатор запроса из заголовка X-Request-Id или новый, передается в описании ошибок и в журнал
+	rout.Use(middleware.RequestID)
 	rout.Use(middleware.Logger)
-	rout.Use(middleware.Recoverer)
+	// перехват паники обработчиков с ответом 500 в формате RFC 7807
+	rout.Use(handlers.Recoverer)
 	// дополнительный middleware gzip
 	rout.Use(middlewareGzip)
 
 	// защищенные пути
 	rout.Group(func(r chi.Router) {
 		// поиск, верифицирование, валидация JWT токенов
-		r.Use(jwtauth.Verifier(settings.TokenAuth))
+		r.Use(keysHandler.Verifier)
+		// проверка токена по списку отозванных токенов
+		r.Use(userHandler.RevocationCheck)
 		// обрабочик валидный / не валидный токен
-		r.Use(jwtauth.Authenticator)
-		// загрузка пользователем номера заказа для расчёта
-		r.Post("/api/user/orders", orderHandler.Load)
+		r.Use(handlers.Authenticator)
+		// выход пользователя с отзывом токенов
+		r.Post("/api/user/logout", userHandler.Logout)
+		// смена пароля пользователя
+		r.Put("/api/user/password", userHandler.ChangePassword)
+		// загрузка пользователем номера заказа для расчёта с поддержкой заголовка Idempotency-Key
+		r.Post("/api/user/orders", idempotencyHandler.Wrap(orderHandler.Load))
 		// получение списка загруженных пользователем номеров заказов, статусов их обработки и информации о начислениях
 		r.Get("/api/user/orders", orderHandler.List)
+		// выгрузка списка заказов в файл CSV или XLSX
+		r.Get("/api/user/orders/export", orderHandler.Export)
+		// получение заказа с историей изменения статуса
+		r.Get("/api/user/orders/{number}", orderHandler.Order)
 		// получение текущего баланса счёта баллов лояльности пользователя
 		r.Get("/api/user/balance", balanceHandler.Status)
-		// запрос на списание баллов с накопительного счёта в счёт оплаты нового заказа
-		r.Post("/api/user/balance/withdraw", balanceHandler.NewWithdrawal)
+		// запрос на списание баллов с накопительного счёта в счёт оплаты нового заказа с поддержкой заголовка Idempotency-Key
+		r.Post("/api/user/balance/withdraw", idempotencyHandler.Wrap(balanceHandler.NewWithdrawal))
 		// получение информации о выводе средств с накопительного счёта пользователем
 		r.Get("/api/user/withdrawals", balanceHandler.WithdrawalsList)
+		// выгрузка списка выводов средств в файл CSV или XLSX
+		r.Get("/api/user/withdrawals/export", balanceHandler.WithdrawalsExport)
+		// получение истории операций по счёту пользователя
+		r.Get("/api/user/history", balanceHandler.History)
+		// административные пути, доступные только пользователям с ролью admin
+		r.Route("/api/admin", func(r chi.Router) {
+			r.Use(handlers.RequireRole(models.RoleAdmin))
+			// карточка, заказы, баланс и списания пользователя
+			r.Get("/users/{login}", adminHandler.User)
+			r.Get("/users/{login}/orders", adminHandler.Orders)
+			r.Get("/users/{login}/balance", adminHandler.Balance)
+			r.Get("/users/{login}/withdrawals", adminHandler.Withdrawals)
+			r.Get("/users/{login}/history", adminHandler.History)
+			// ручная корректировка баланса с поддержкой заголовка Idempotency-Key
+			r.Post("/users/{login}/adjustments", idempotencyHandler.Wrap(adminHandler.NewAdjustment))
+			// смена роли пользователя
+			r.Put("/users/{login}/role", adminHandler.SetRole)
+			// заказ по номеру
+			r.Get("/orders/{number}", adminHandler.Order)
+			// диагностика обработки заказа и немедленный повторный опрос внешнего сервиса
+			r.Get("/orders/{number}/diagnostics", adminHandler.OrderDiagnostics)
+			r.Post("/orders/{number}/repoll", adminHandler.Repoll)
+		})
 
 	})
 
@@ -45,10 +80,19 @@ func NewRouter(userHandler *handlers.UserHandler, orderHandler *handlers.OrderHa
 		r.Post("/api/user/register", userHandler.Create)
 		// аутентификация пользователя: HTTPзаголовок Authorization
 		r.Post("/api/user/login", userHandler.CheckAuthorization)
+		// обновление пары токенов по токену обновления
+		r.Post("/api/user/token/refresh", userHandler.Refresh)
+		// запрос и подтверждение сброса пароля
+		r.Post("/api/user/password/reset", userHandler.RequestPasswordReset)
+		r.Post("/api/user/password/reset/confirm", userHandler.ConfirmPasswordReset)
+		// открытые ключи проверки подписи токенов для сторонних сервисов
+		r.Get("/.well-known/jwks.json", keysHandler.JWKS)
 	})
 
-	// возврат ошибки 401 для неавторизованных запросов - jwtauth.Authenticator
-	// возврат ошибки 404 для всех остальных запросов - роутер chi
+	// возврат ошибки 401 для неавторизованных запросов - handlers.Authenticator
+	// возврат ошибок 404 и 405 для всех остальных запросов в формате RFC 7807
+	rout.NotFound(handlers.NotFound)
+	rout.MethodNotAllowed(handlers.MethodNotAllowed)
 
 	
 	return rout
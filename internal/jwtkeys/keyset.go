@@ -0,0 +1,201 @@
+// пакет набора ключей подписи JWT токенов с поддержкой ротации ключей
+package jwtkeys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// KeySet - набор ключей: токены подписываются активным ключом, проверяются любым ключом набора
+// заголовок kid токена содержит идентификатор ключа подписи, поэтому после смены активного ключа
+// ранее выданные токены проверяются, пока старый ключ остается в наборе
+type KeySet struct {
+	// активный ключ подписи и его алгоритм
+	signKey jwk.Key
+	alg     jwa.SignatureAlgorithm
+	// ключи проверки подписи: открытые для асимметричных алгоритмов, секретные для HMAC
+	verifySet jwk.Set
+	// открытые ключи асимметричных алгоритмов для публикации в JWKS
+	publicSet jwk.Set
+}
+
+// New - конструктор набора ключей из JWK Set, activeKID - идентификатор ключа подписи,
+// при пустом значении используется первый ключ набора
+// ключам без kid присваивается отпечаток ключа (RFC 7638), ключам без alg - алгоритм по типу ключа
+func New(keys jwk.Set, activeKID string) (*KeySet, error) {
+	if keys.Len() == 0 {
+		return nil, errors.New("jwt key set is empty")
+	}
+	ks := &KeySet{
+		verifySet: jwk.NewSet(),
+		publicSet: jwk.NewSet(),
+	}
+	for i := 0; i < keys.Len(); i++ {
+		key, _ := keys.Key(i)
+		if key.KeyID() == "" {
+			if err := jwk.AssignKeyID(key); err != nil {
+				return nil, fmt.Errorf("jwt key %d kid assignment error: %w", i, err)
+			}
+		}
+		if key.Algorithm().String() == "" {
+			alg, err := defaultAlgorithm(key)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %s: %w", key.KeyID(), err)
+			}
+			if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+				return nil, err
+			}
+		}
+		// выбираем активный ключ подписи
+		if ks.signKey == nil && (activeKID == "" || activeKID == key.KeyID()) {
+			if !isPrivate(key) {
+				return nil, fmt.Errorf("jwt key %s is not a private key and can't be used for signing", key.KeyID())
+			}
+			ks.signKey = key
+			if err := ks.alg.Accept(key.Algorithm()); err != nil {
+				return nil, fmt.Errorf("jwt key %s: %w", key.KeyID(), err)
+			}
+		}
+		// секретный ключ HMAC используется для проверки как есть и не публикуется
+		if key.KeyType() == jwa.OctetSeq {
+			if err := ks.verifySet.AddKey(key); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		pub, err := jwk.PublicKeyOf(key)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s public key error: %w", key.KeyID(), err)
+		}
+		if err := ks.verifySet.AddKey(pub); err != nil {
+			return nil, err
+		}
+		if err := ks.publicSet.AddKey(pub); err != nil {
+			return nil, err
+		}
+	}
+	if ks.signKey == nil {
+		return nil, fmt.Errorf("jwt signing key %s not found in key set", activeKID)
+	}
+	return ks, nil
+}
+
+// Load - конструктор набора ключей из файла в формате JWK Set (JSON) или PEM
+func Load(path string, activeKID string) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := jwk.Parse(data)
+	if err != nil {
+		// файл не в формате JWK Set, читаем как PEM
+		var errPEM error
+		keys, errPEM = jwk.Parse(data, jwk.WithPEM(true))
+		if errPEM != nil {
+			return nil, fmt.Errorf("jwt keys file %s parsing error: %w", path, err)
+		}
+	}
+	return New(keys, activeKID)
+}
+
+// NewHMAC - конструктор набора из одного секретного ключа HS256
+func NewHMAC(secret []byte) (*KeySet, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("jwt secret is empty")
+	}
+	key, err := jwk.FromRaw(secret)
+	if err != nil {
+		return nil, err
+	}
+	keys := jwk.NewSet()
+	if err := keys.AddKey(key); err != nil {
+		return nil, err
+	}
+	return New(keys, "")
+}
+
+// Generate - конструктор набора из одного случайного секретного ключа HS256
+// токены, подписанные таким ключом, перестают проверяться после перезапуска приложения
+func Generate() (*KeySet, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return NewHMAC(secret)
+}
+
+// Encode создает токен с переданными claims и подписывает его активным ключом
+func (ks *KeySet) Encode(claims map[string]interface{}) (t jwt.Token, tokenString string, err error) {
+	t = jwt.New()
+	for k, v := range claims {
+		if err = t.Set(k, v); err != nil {
+			return nil, "", err
+		}
+	}
+	// заголовок kid устанавливается по идентификатору ключа
+	payload, err := jwt.Sign(t, jwt.WithKey(ks.alg, ks.signKey))
+	if err != nil {
+		return nil, "", err
+	}
+	return t, string(payload), nil
+}
+
+// Decode проверяет подпись токена ключами набора и валидирует claims
+// токены без заголовка kid, выданные до введения набора ключей, проверяются всеми ключами набора
+func (ks *KeySet) Decode(tokenString string) (jwt.Token, error) {
+	return jwt.Parse([]byte(tokenString), jwt.WithKeySet(ks.verifySet, jws.WithRequireKid(false)))
+}
+
+// PublicSet возвращает открытые ключи набора для публикации в JWKS
+func (ks *KeySet) PublicSet() jwk.Set {
+	return ks.publicSet
+}
+
+// KeyID возвращает идентификатор активного ключа подписи
+func (ks *KeySet) KeyID() string {
+	return ks.signKey.KeyID()
+}
+
+// алгоритм подписи по умолчанию для типа ключа
+func defaultAlgorithm(key jwk.Key) (jwa.SignatureAlgorithm, error) {
+	switch key.KeyType() {
+	case jwa.OctetSeq:
+		return jwa.HS256, nil
+	case jwa.RSA:
+		return jwa.RS256, nil
+	case jwa.OKP:
+		return jwa.EdDSA, nil
+	case jwa.EC:
+		var raw ecdsa.PublicKey
+		if pub, err := jwk.PublicKeyOf(key); err != nil || pub.Raw(&raw) != nil {
+			return "", errors.New("unsupported EC key")
+		}
+		switch raw.Curve {
+		case elliptic.P256():
+			return jwa.ES256, nil
+		case elliptic.P384():
+			return jwa.ES384, nil
+		case elliptic.P521():
+			return jwa.ES512, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported key type %s", key.KeyType())
+}
+
+// проверка наличия закрытой части ключа
+func isPrivate(key jwk.Key) bool {
+	switch key.(type) {
+	case jwk.SymmetricKey, jwk.RSAPrivateKey, jwk.ECDSAPrivateKey, jwk.OKPPrivateKey:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,184 @@
+// тесты набора ключей подписи токенов
+package jwtkeys__test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/jwtkeys"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// создание JWK ключа с идентификатором kid
+func newKey(t *testing.T, raw interface{}, kid string) jwk.Key {
+	key, err := jwk.FromRaw(raw)
+	require.NoError(t, err)
+	require.NoError(t, key.Set(jwk.KeyIDKey, kid))
+	return key
+}
+
+// набор ключей: RSA, Ed25519 и секретный ключ HMAC
+func newSet(t *testing.T) jwk.Set {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	set := jwk.NewSet()
+	require.NoError(t, set.AddKey(newKey(t, rsaKey, "rsa-2022")))
+	require.NoError(t, set.AddKey(newKey(t, edKey, "ed-2023")))
+	require.NoError(t, set.AddKey(newKey(t, []byte("9e9e0b4e6de418b2f84fca35165571c5"), "hs-legacy")))
+	return set
+}
+
+// заголовок kid токена
+func tokenKID(t *testing.T, tokenString string) string {
+	msg, err := jws.Parse([]byte(tokenString))
+	require.NoError(t, err)
+	return msg.Signatures()[0].ProtectedHeaders().KeyID()
+}
+
+func TestKeySet_Encode(t *testing.T) {
+	// определяем структуру теста
+	tests := []struct {
+		name        string
+		activeKID   string
+		expectedAlg jwa.SignatureAlgorithm
+	}{
+		{name: "Positive test - RS256 key", activeKID: "rsa-2022", expectedAlg: jwa.RS256},
+		{name: "Positive test - EdDSA key", activeKID: "ed-2023", expectedAlg: jwa.EdDSA},
+		{name: "Positive test - HS256 key", activeKID: "hs-legacy", expectedAlg: jwa.HS256},
+	}
+	set := newSet(t)
+	for _, tCase := range tests {
+		// запускаем каждый тест
+		t.Run(tCase.name, func(t *testing.T) {
+			ks, err := jwtkeys.New(set, tCase.activeKID)
+			require.NoError(t, err)
+			_, tokenString, err := ks.Encode(map[string]interface{}{"login": "dimma"})
+			require.NoError(t, err)
+			// заголовки токена содержат идентификатор и алгоритм ключа
+			msg, err := jws.Parse([]byte(tokenString))
+			require.NoError(t, err)
+			assert.Equal(t, tCase.activeKID, msg.Signatures()[0].ProtectedHeaders().KeyID())
+			assert.Equal(t, tCase.expectedAlg, msg.Signatures()[0].ProtectedHeaders().Algorithm())
+			// токен проверяется набором ключей
+			token, err := ks.Decode(tokenString)
+			require.NoError(t, err)
+			login, _ := token.Get("login")
+			assert.Equal(t, "dimma", login)
+		})
+	}
+}
+
+func TestKeySet_Rotation(t *testing.T) {
+	set := newSet(t)
+	// токен подписан ключом до ротации
+	before, err := jwtkeys.New(set, "rsa-2022")
+	require.NoError(t, err)
+	_, oldToken, err := before.Encode(map[string]interface{}{"login": "dimma"})
+	require.NoError(t, err)
+	// после ротации токены подписываются новым ключом, старые токены проверяются
+	after, err := jwtkeys.New(set, "ed-2023")
+	require.NoError(t, err)
+	_, newToken, err := after.Encode(map[string]interface{}{"login": "dimma"})
+	require.NoError(t, err)
+	assert.Equal(t, "ed-2023", tokenKID(t, newToken))
+	_, err = after.Decode(oldToken)
+	assert.NoError(t, err)
+	// после удаления старого ключа из набора старые токены не проверяются
+	rsaKey, _ := set.LookupKeyID("rsa-2022")
+	require.NoError(t, set.RemoveKey(rsaKey))
+	removed, err := jwtkeys.New(set, "ed-2023")
+	require.NoError(t, err)
+	_, err = removed.Decode(oldToken)
+	assert.Error(t, err)
+	_, err = removed.Decode(newToken)
+	assert.NoError(t, err)
+}
+
+func TestKeySet_LegacyToken(t *testing.T) {
+	// токен без заголовка kid, подписанный прежним ключом приложения
+	legacy := jwtauth.New(string(jwa.HS256), []byte("9e9e0b4e6de418b2f84fca35165571c5"), nil)
+	_, tokenString, err := legacy.Encode(map[string]interface{}{"login": "dimma"})
+	require.NoError(t, err)
+	ks, err := jwtkeys.New(newSet(t), "ed-2023")
+	require.NoError(t, err)
+	_, err = ks.Decode(tokenString)
+	assert.NoError(t, err)
+	// токен, подписанный другим секретным ключом, не проверяется
+	other := jwtauth.New(string(jwa.HS256), []byte("another secret"), nil)
+	_, tokenString, err = other.Encode(map[string]interface{}{"login": "dimma"})
+	require.NoError(t, err)
+	_, err = ks.Decode(tokenString)
+	assert.Error(t, err)
+}
+
+func TestKeySet_PublicSet(t *testing.T) {
+	ks, err := jwtkeys.New(newSet(t), "")
+	require.NoError(t, err)
+	// публикуются только открытые ключи асимметричных алгоритмов
+	body, err := json.Marshal(ks.PublicSet())
+	require.NoError(t, err)
+	var jwks struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(body, &jwks))
+	require.Len(t, jwks.Keys, 2)
+	for _, key := range jwks.Keys {
+		assert.NotContains(t, key, "d")
+		assert.NotContains(t, key, "k")
+		assert.NotEmpty(t, key["kid"])
+		assert.NotEmpty(t, key["alg"])
+	}
+}
+
+func TestKeySet_Load(t *testing.T) {
+	dir := t.TempDir()
+	// файл в формате JWK Set
+	set := newSet(t)
+	body, err := json.Marshal(set)
+	require.NoError(t, err)
+	jwksFile := filepath.Join(dir, "keys.json")
+	require.NoError(t, os.WriteFile(jwksFile, body, 0600))
+	ks, err := jwtkeys.Load(jwksFile, "ed-2023")
+	require.NoError(t, err)
+	assert.Equal(t, "ed-2023", ks.KeyID())
+	// файл в формате PEM, идентификатор ключа - отпечаток ключа
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pem, err := jwk.EncodePEM(rsaKey)
+	require.NoError(t, err)
+	pemFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(pemFile, pem, 0600))
+	ks, err = jwtkeys.Load(pemFile, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, ks.KeyID())
+	_, tokenString, err := ks.Encode(map[string]interface{}{"login": "dimma"})
+	require.NoError(t, err)
+	assert.Equal(t, ks.KeyID(), tokenKID(t, tokenString))
+	// активный ключ отсутствует в наборе
+	_, err = jwtkeys.Load(jwksFile, "unknown")
+	assert.Error(t, err)
+	// файл отсутствует
+	_, err = jwtkeys.Load(filepath.Join(dir, "missing.json"), "")
+	assert.Error(t, err)
+}
+
+func TestKeySet_PublicKeyCantSign(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	set := jwk.NewSet()
+	require.NoError(t, set.AddKey(newKey(t, edKey.Public(), "ed-public")))
+	_, err = jwtkeys.New(set, "ed-public")
+	assert.Error(t, err)
+}
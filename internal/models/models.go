@@ -2,6 +2,8 @@
 package models
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -40,6 +42,41 @@ type WithdrawalsList struct {
 	ProcessedAt time.Time       `json:"processed_at"`
 }
 
+// статусы заказа в системе начисления баллов лояльности
+var OrderStatuses = []string{
+	"NEW",
+	"PROCESSING",
+	"INVALID",
+	"PROCESSED",
+}
+
+// параметры запроса списка из строки запроса: limit, after, status, from, to, sort
+type DecodeListQuery struct {
+	Limit  string
+	After  string
+	Status []string
+	From   string
+	To     string
+	Sort   string
+}
+
+// курсор постраничной выдачи: время и ключ последней выданной записи
+type Cursor struct {
+	Time time.Time
+	Key  string
+}
+
+// параметры выборки списка: размер страницы, курсор, фильтры по статусу и периоду и направление сортировки
+// нулевые значения - без ограничений, сортировка по времени и ключу записи
+type ListQuery struct {
+	Limit    int
+	After    *Cursor
+	Statuses []string
+	From     time.Time
+	To       time.Time
+	Desc     bool
+}
+
 // статус ордера из истемы начислений баллов лояльности
 type OrderSatus struct {
 	Order   string          `json:"order"`
@@ -49,6 +86,254 @@ type OrderSatus struct {
 
 // задача для воркера работающего с внешним сервиом начислений баллов лояльности
 type Task struct {
-	OrderNum string
-	Login    string
+	OrderNum    string
+	Login       string
+	Registered  bool
+	Attempts    int
+	NextAttempt time.Time
+	CreatedAt   time.Time
+	Policy      RetryPolicy
+	// время окончания аренды задачи воркером, по нему продлевается только собственная аренда
+	LockedUntil time.Time
+}
+
+// политика повторных опросов внешнего сервиса начислений баллов лояльности
+type RetryPolicy struct {
+	InitialDelay time.Duration
+	Multiplier   float64
+	MaxDelay     time.Duration
+	Jitter       float64
+	MaxAttempts  int
+	MaxAge       time.Duration
+}
+
+// типы записей журнала операций по счетам баллов лояльности
+const (
+	EntryAccrual    = "ACCRUAL"
+	EntryWithdrawal = "WITHDRAWAL"
+	EntryAdjustment = "ADJUSTMENT"
+	EntryReversal   = "REVERSAL"
+	// ручная корректировка баланса администратором с кодом причины
+	EntryManualAdjustment = "MANUAL_ADJUSTMENT"
+)
+
+// системные счета журнала операций, счет пользователя - "user:<login>"
+const (
+	AccountAccruals    = "system:accruals"
+	AccountWithdrawals = "system:withdrawals"
+	AccountAdjustments = "system:adjustments"
+)
+
+// запись журнала операций: сумма списывается со счета DebitAccount и зачисляется на счет CreditAccount
+// IdempotencyKey исключает повторное проведение одной и той же операции
+type LedgerEntry struct {
+	IdempotencyKey string          `json:"-"`
+	Login          string          `json:"-"`
+	EntryType      string          `json:"type"`
+	DebitAccount   string          `json:"debit_account"`
+	CreditAccount  string          `json:"credit_account"`
+	Amount         decimal.Decimal `json:"amount"`
+	CreatedAt      time.Time       `json:"created_at"`
+	// код причины, комментарий и автор ручной корректировки баланса
+	Reason    string `json:"reason,omitempty"`
+	Comment   string `json:"comment,omitempty"`
+	CreatedBy string `json:"created_by,omitempty"`
+}
+
+// направление ручной корректировки баланса
+const (
+	AdjustmentCredit = "credit"
+	AdjustmentDebit  = "debit"
+)
+
+// коды причин ручной корректировки баланса
+var AdjustmentReasons = []string{
+	"LOST_ACCRUAL",
+	"DUPLICATE_ACCRUAL",
+	"GOODWILL",
+	"FRAUD",
+	"CORRECTION",
+}
+
+// структура для десериализации запроса ручной корректировки баланса
+type NewAdjustment struct {
+	Operation string          `json:"operation"`
+	Amount    decimal.Decimal `json:"amount"`
+	Reason    string          `json:"reason"`
+	Comment   string          `json:"comment"`
+	// логин администратора, выполнившего корректировку
+	CreatedBy string `json:"-"`
+}
+
+// результат сверки сохраненного баланса с балансом, рассчитанным по журналу операций
+type BalanceCheck struct {
+	Login      string       `json:"login"`
+	Stored     LoginBalance `json:"stored"`
+	Ledger     LoginBalance `json:"ledger"`
+	Consistent bool         `json:"consistent"`
+}
+
+// расхождение сохраненного баланса с балансом, рассчитанным по начислениям заказов и списаниям
+type BalanceDrift struct {
+	Login    string       `json:"login"`
+	Stored   LoginBalance `json:"stored"`
+	Expected LoginBalance `json:"expected"`
+}
+
+// сохраненный результат запроса с заголовком Idempotency-Key
+// StatusCode == 0 - запрос с этим ключом еще выполняется
+type IdempotencyRecord struct {
+	Endpoint    string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// пара токенов, выдаваемая при регистрации, аутентификации и обновлении
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// структура для десериализации запроса смены пароля
+type DecodePasswordChange struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+// структура для десериализации запроса сброса пароля
+type DecodePasswordReset struct {
+	Login string `json:"login"`
+}
+
+// структура для десериализации подтверждения сброса пароля
+type DecodePasswordResetConfirm struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// claim времени выдачи токена доступа в микросекундах: стандартный claim iat содержит только секунды,
+// по нему токен, выданный в ту же секунду до смены пароля или роли, отличается от выданного после смены
+const ClaimIssuedAtMicro = "iat_us"
+
+// роли пользователей
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// структура для десериализации запроса смены роли пользователя
+type DecodeRole struct {
+	Role string `json:"role"`
+}
+
+// карточка пользователя для администратора
+type AdminUser struct {
+	Login             string       `json:"login"`
+	Role              string       `json:"role"`
+	Balance           LoginBalance `json:"balance"`
+	Orders            int          `json:"orders"`
+	PasswordChangedAt *time.Time   `json:"password_changed_at,omitempty"`
+}
+
+// заказ для администратора с логином владельца
+type AdminOrder struct {
+	Login string `json:"login"`
+	OrdersList
+}
+
+// изменение статуса заказа
+type OrderStatusChange struct {
+	Status    string          `json:"status"`
+	Accrual   decimal.Decimal `json:"accrual,omitempty"`
+	ChangedAt time.Time       `json:"changed_at"`
+}
+
+// заказ пользователя с историей изменения статуса
+type OrderTimeline struct {
+	OrdersList
+	History []OrderStatusChange `json:"history"`
+}
+
+// результат последнего опроса внешнего сервиса начислений баллов лояльности по заказу
+// StatusCode == 0 - запрос завершился ошибкой без ответа сервиса
+type AccrualPoll struct {
+	OrderNum   string    `json:"-"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	PolledAt   time.Time `json:"polled_at"`
+}
+
+// диагностика обработки заказа для администратора: история статусов, последний опрос внешнего сервиса
+// и состояние задачи в очереди, Queued == false - задачи по заказу нет в очереди
+type OrderDiagnostics struct {
+	AdminOrder
+	History     []OrderStatusChange `json:"history"`
+	Queued      bool                `json:"queued"`
+	Exhausted   bool                `json:"exhausted"`
+	Registered  bool                `json:"registered"`
+	Attempts    int                 `json:"attempts"`
+	NextAttempt *time.Time          `json:"next_attempt,omitempty"`
+	LastPoll    *AccrualPoll        `json:"last_poll,omitempty"`
+}
+
+// структура для десериализации запроса обновления токенов
+type DecodeRefreshToken struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// вход временно заблокирован после превышения количества неудачных попыток
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("login temporarily locked, retry after %s", e.RetryAfter.Round(time.Second))
+}
+
+// нарушенное правило проверки поля запроса
+type Violation struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// данные запроса не прошли проверку, Violations - все нарушенные правила
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Field + "." + v.Rule
+	}
+	return "validation failed: " + strings.Join(rules, ", ")
+}
+
+// Contains - проверка наличия значения в списке допустимых значений
+func Contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// описание ошибки запроса в формате RFC 7807 (application/problem+json)
+// Type - стабильный идентификатор вида ошибки, по нему клиент выбирает локализованное сообщение
+type Problem struct {
+	Type       string      `json:"type"`
+	Title      string      `json:"title"`
+	Status     int         `json:"status"`
+	Detail     string      `json:"detail,omitempty"`
+	Instance   string      `json:"instance,omitempty"`
+	RequestID  string      `json:"request_id,omitempty"`
+	Violations []Violation `json:"violations,omitempty"`
 }
@@ -0,0 +1,25 @@
+// пакет уведомлений пользователей
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/rs/zerolog/log"
+)
+
+// LogNotifier - уведомления пользователей в лог приложения, для локальной разработки и тестирования
+type LogNotifier struct {
+}
+
+// конструктор уведомлений в лог
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// отправка токена сброса пароля пользователю
+func (n *LogNotifier) PasswordReset(ctx context.Context, login string, token string, expiresAt time.Time) (err error) {
+	log.Printf("password reset for login %s: token %s%s%s valid until %s", login, settings.ColorYellow, token, settings.ColorReset, expiresAt.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// интерфейс методов хранилища для Admin
+type AdminStorageProvider interface {
+	AdminUser(ctx context.Context, login string) (ec models.AdminUser, err error)
+	AdminOrder(ctx context.Context, orderNum string) (ec models.AdminOrder, err error)
+	List(ctx context.Context, login string, q models.ListQuery) (ec []models.OrdersList, err error)
+	VerifyBalance(ctx context.Context, login string) (ec models.BalanceCheck, err error)
+	WithdrawalsList(ctx context.Context, login string, q models.ListQuery) (ec []models.WithdrawalsList, err error)
+	SetUserRole(ctx context.Context, login string, role string) (err error)
+	NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error)
+	History(ctx context.Context, login string) (ec []models.LedgerEntry, err error)
+	OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error)
+}
+
+// интерфейс постановки задачи в очередь пула воркеров для немедленного опроса внешнего сервиса
+type RequeueProvider interface {
+	Requeue(ctx context.Context, login, orderNum string) (err error)
+}
+
+// структура конструктора бизнес логики Admin
+type AdminService struct {
+	storage AdminStorageProvider
+	pool    RequeueProvider
+}
+
+// конструктор бизнес логики Admin
+func NewAdminService(aStorage AdminStorageProvider, pool RequeueProvider) *AdminService {
+	return &AdminService{
+		aStorage,
+		pool,
+	}
+}
+
+// карточка пользователя: роль, баланс, количество заказов
+func (svc *AdminService) User(ctx context.Context, login string) (ec models.AdminUser, err error) {
+	return svc.adminUser(ctx, login)
+}
+
+// заказ по номеру с логином владельца
+func (svc *AdminService) Order(ctx context.Context, orderNum string) (ec models.AdminOrder, err error) {
+	return svc.storage.AdminOrder(ctx, orderNum)
+}
+
+// диагностика обработки заказа: история статусов, последний опрос внешнего сервиса, попытки и время следующей попытки
+func (svc *AdminService) OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error) {
+	return svc.storage.OrderDiagnostics(ctx, orderNum)
+}
+
+// немедленный повторный опрос внешнего сервиса по заказу с нефинальным статусом
+func (svc *AdminService) Repoll(ctx context.Context, orderNum string) (err error) {
+	order, err := svc.storage.AdminOrder(ctx, orderNum)
+	if err != nil {
+		return err
+	}
+	// финальный статус заказа больше не обновляется внешним сервисом
+	if order.Status == "INVALID" || order.Status == "PROCESSED" {
+		return domainerrors.ErrOrderFinal
+	}
+	return svc.pool.Requeue(ctx, order.Login, order.Number)
+}
+
+// список заказов пользователя
+func (svc *AdminService) Orders(ctx context.Context, login string) (ec []models.OrdersList, err error) {
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	return svc.storage.List(ctx, login, models.ListQuery{})
+}
+
+// баланс пользователя со сверкой по журналу операций
+func (svc *AdminService) Balance(ctx context.Context, login string) (ec models.BalanceCheck, err error) {
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	return svc.storage.VerifyBalance(ctx, login)
+}
+
+// список списаний пользователя
+func (svc *AdminService) Withdrawals(ctx context.Context, login string) (ec []models.WithdrawalsList, err error) {
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	return svc.storage.WithdrawalsList(ctx, login, models.ListQuery{})
+}
+
+// смена роли пользователя, ранее выданные пользователю токены отзываются, новая роль действует со следующего входа
+func (svc *AdminService) SetRole(ctx context.Context, login string, role string) (err error) {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return &models.ValidationError{Violations: []models.Violation{
+			{Field: "role", Rule: "oneof", Message: "role must be one of: " + models.RoleUser + ", " + models.RoleAdmin},
+		}}
+	}
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	return svc.storage.SetUserRole(ctx, login, role)
+}
+
+// ручная корректировка баланса пользователя с кодом причины и комментарием, возвращает баланс после корректировки
+func (svc *AdminService) NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error) {
+	dc.Comment = strings.TrimSpace(dc.Comment)
+	if err = ValidateAdjustment(dc); err != nil {
+		return ec, err
+	}
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	return svc.storage.NewAdjustment(ctx, login, dc)
+}
+
+// история операций пользователя с авторами ручных корректировок
+func (svc *AdminService) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	login, err = svc.userLogin(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	return svc.storage.History(ctx, login)
+}
+
+// приведение логина к каноническому виду и проверка наличия пользователя,
+// чтобы отличать неизвестный логин от пользователя без заказов и списаний
+func (svc *AdminService) userLogin(ctx context.Context, login string) (string, error) {
+	user, err := svc.adminUser(ctx, login)
+	return user.Login, err
+}
+
+// карточка пользователя по логину в каноническом виде, логины, зарегистрированные до приведения
+// к нижнему регистру, ищутся в исходном написании, как при входе пользователя
+func (svc *AdminService) adminUser(ctx context.Context, login string) (ec models.AdminUser, err error) {
+	ec, err = svc.storage.AdminUser(ctx, NormalizeLogin(login))
+	legacy := strings.TrimSpace(login)
+	if errors.Is(err, domainerrors.ErrLoginNotFound) && legacy != NormalizeLogin(login) {
+		return svc.storage.AdminUser(ctx, legacy)
+	}
+	return ec, err
+}
@@ -9,8 +9,10 @@ import (
 // интерфейс методов хранилища для Balance
 type BalanceStorageProvider interface {
 	NewWithdrawal(ctx context.Context, login string, dc models.NewWithdrawal) (err error)
-	WithdrawalsList(ctx context.Context, login string) (ec []models.WithdrawalsList, err error)
+	WithdrawalsList(ctx context.Context, login string, q models.ListQuery) (ec []models.WithdrawalsList, err error)
+	WithdrawalsEach(ctx context.Context, login string, q models.ListQuery, fn func(models.WithdrawalsList) error) (err error)
 	Status(ctx context.Context, login string) (ec models.LoginBalance, err error)
+	History(ctx context.Context, login string) (ec []models.LedgerEntry, err error)
 }
 
 // структура конструктора бизнес логики Balance
@@ -39,9 +41,44 @@ func (svc *BalanceService) NewWithdrawal(ctx context.Context, login string, dc m
 	return err
 }
 
-// сервис информации о всех выводах средств с накопительного счёта пользователем
-func (svc *BalanceService) WithdrawalsList(ctx context.Context, login string) (ec []models.WithdrawalsList, err error) {
-	ec, err = svc.storage.WithdrawalsList(ctx, login)
+// сервис информации о выводах средств с накопительного счёта пользователем с фильтром по периоду,
+// сортировка выдачи по времени списания, next - курсор следующей страницы, пустой для последней страницы
+func (svc *BalanceService) WithdrawalsList(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.WithdrawalsList, next string, err error) {
+	q, err := ParseListQuery(dc, nil)
+	if err != nil {
+		return ec, "", err
+	}
+	ec, err = svc.storage.WithdrawalsList(ctx, login, pageQuery(q))
+	if err != nil {
+		return ec, "", err
+	}
+	// отбрасываем запись следующей страницы и выдаем курсор последней записи страницы
+	n, more := pageSize(q, len(ec))
+	ec = ec[:n]
+	if more {
+		next = EncodeCursor(models.Cursor{Time: ec[n-1].ProcessedAt, Key: ec[n-1].Order})
+	}
+	// возвращаем структуру и ошибку
+	return ec, next, nil
+}
+
+// сервис потоковой выдачи списка выводов средств пользователем с фильтром по периоду:
+// fn вызывается для каждого списания по мере чтения из хранилища без сборки списка в памяти
+func (svc *BalanceService) WithdrawalsEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.WithdrawalsList) error) (err error) {
+	q, err := ParseListQuery(dc, nil)
+	if err != nil {
+		return err
+	}
+	return svc.storage.WithdrawalsEach(ctx, login, q, fn)
+}
+
+// сервис истории операций по счёту пользователя, включая ручные корректировки с кодом причины и комментарием
+// автор корректировки пользователю не показывается
+func (svc *BalanceService) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	ec, err = svc.storage.History(ctx, login)
+	for i := range ec {
+		ec[i].CreatedBy = ""
+	}
 	// возвращаем структуру и ошибку
 	return ec, err
 }
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+)
+
+// интерфейс методов хранилища для Idempotency
+type IdempotencyStorageProvider interface {
+	IdempotencyReserve(ctx context.Context, login string, key string, rec models.IdempotencyRecord, window time.Duration, lease time.Duration) (ec models.IdempotencyRecord, reserved bool, err error)
+	IdempotencySave(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error)
+	IdempotencyRelease(ctx context.Context, login string, key string) (err error)
+}
+
+// структура конструктора бизнес логики Idempotency
+type IdempotencyService struct {
+	storage IdempotencyStorageProvider
+}
+
+// конструктор бизнес логики Idempotency
+func NewIdempotencyService(iStorage IdempotencyStorageProvider) *IdempotencyService {
+	return &IdempotencyService{
+		iStorage,
+	}
+}
+
+// сервис резервирования ключа идемпотентности на время окна хранения результата
+func (svc *IdempotencyService) Begin(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (ec models.IdempotencyRecord, reserved bool, err error) {
+	return svc.storage.IdempotencyReserve(ctx, login, key, rec, settings.IdempotencyWindow, idempotencyLease())
+}
+
+// время резервирования ключа, меньшее таймаута обработчика, увеличивается до минимального:
+// иначе резервирование истекает до завершения исходного запроса и повтор с тем же ключом выполняет операцию второй раз
+func idempotencyLease() time.Duration {
+	if settings.IdempotencyLease < settings.IdempotencyLeaseMin {
+		return settings.IdempotencyLeaseMin
+	}
+	return settings.IdempotencyLease
+}
+
+// сервис сохранения результата запроса, при ошибке сервера ключ освобождается для повторного выполнения
+func (svc *IdempotencyService) Complete(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error) {
+	if rec.StatusCode >= http.StatusInternalServerError {
+		return svc.storage.IdempotencyRelease(ctx, login, key)
+	}
+	return svc.storage.IdempotencySave(ctx, login, key, rec)
+}
+
+// сервис освобождения ключа идемпотентности без сохранения результата для повторного выполнения запроса
+func (svc *IdempotencyService) Release(ctx context.Context, login string, key string) (err error) {
+	return svc.storage.IdempotencyRelease(ctx, login, key)
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// интерфейс методов хранилища для учета неудачных попыток входа
+type LoginAttemptStorageProvider interface {
+	LoginAttemptsLocked(ctx context.Context, keys []string) (lockedUntil time.Time, err error)
+	LoginAttemptFailed(ctx context.Context, key string, window time.Duration) (failures int, err error)
+	LoginAttemptLock(ctx context.Context, key string, until time.Time) (err error)
+	LoginAttemptsReset(ctx context.Context, key string) (err error)
+}
+
+// области учета попыток: вход и смена пароля используют общие счетчики неудачных попыток,
+// запросы сброса пароля учитываются отдельно, чтобы частые запросы сброса не блокировали вход пользователя
+const (
+	scopeLogin = ""
+	scopeReset = "reset:"
+)
+
+// ключи учета попыток в области scope: по логину и по IP адресу клиента
+func attemptKeys(scope string, login string, ip string) (keys []string) {
+	keys = append(keys, scope+"login:"+login)
+	if ip != "" {
+		keys = append(keys, scope+"ip:"+ip)
+	}
+	return keys
+}
+
+// проверка блокировки входа по логину и IP адресу
+func (svc *UserService) checkLockout(ctx context.Context, login string, ip string) (err error) {
+	return svc.checkScopeLockout(ctx, scopeLogin, login, ip)
+}
+
+// проверка блокировки попыток в области scope по логину и IP адресу
+func (svc *UserService) checkScopeLockout(ctx context.Context, scope string, login string, ip string) (err error) {
+	lockedUntil, err := svc.storage.LoginAttemptsLocked(ctx, attemptKeys(scope, login, ip))
+	if err != nil {
+		return err
+	}
+	if retryAfter := time.Until(lockedUntil); retryAfter > 0 {
+		log.Printf("login attempt for login %s from %s rejected, locked for %s", login, ip, retryAfter)
+		return &models.LockoutError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// учет неудачной попытки входа, при превышении количества попыток вход блокируется
+// возвращает models.LockoutError, если попытка привела к блокировке
+func (svc *UserService) registerFailure(ctx context.Context, login string, ip string) (err error) {
+	return svc.registerAttempt(ctx, scopeLogin, login, ip)
+}
+
+// учет попытки в области scope: до порога следующая попытка задерживается, при превышении количества попыток
+// попытки блокируются, возвращает models.LockoutError, если попытка привела к блокировке
+func (svc *UserService) registerAttempt(ctx context.Context, scope string, login string, ip string) (err error) {
+	var lockout time.Duration
+	for _, key := range attemptKeys(scope, login, ip) {
+		// для IP адреса допускается больше попыток: с одного адреса могут входить разные пользователи
+		threshold := settings.LoginMaxFailures
+		if key != scope+"login:"+login {
+			threshold = settings.LoginIPMaxFailures
+		}
+		failures, err := svc.storage.LoginAttemptFailed(ctx, key, settings.LoginFailureWindow)
+		if err != nil {
+			return err
+		}
+		// до порога следующая попытка отклоняется с Retry-After до истечения задержки
+		if d := failureDelay(failures, threshold); d > 0 {
+			if err := svc.storage.LoginAttemptLock(ctx, key, time.Now().Add(d)); err != nil {
+				return err
+			}
+			log.Printf("login attempts key %s delayed for %s after %d failures", key, d, failures)
+			continue
+		}
+		d := lockoutDuration(failures, threshold)
+		if d <= 0 {
+			continue
+		}
+		if err := svc.storage.LoginAttemptLock(ctx, key, time.Now().Add(d)); err != nil {
+			return err
+		}
+		log.Printf("login attempts key %s locked for %s after %d failures", key, d, failures)
+		if d > lockout {
+			lockout = d
+		}
+	}
+	if lockout > 0 {
+		return &models.LockoutError{RetryAfter: lockout}
+	}
+	return nil
+}
+
+// задержка следующей попытки после failures неудачных попыток подряд до порога threshold:
+// settings.LoginFailureDelay, удваивается с каждой следующей попыткой, но не превышает settings.LoginLockout
+func failureDelay(failures int, threshold int) time.Duration {
+	if threshold <= 0 || failures < 1 || failures >= threshold {
+		return 0
+	}
+	d := settings.LoginFailureDelay
+	for i := 1; i < failures && d < settings.LoginLockout; i++ {
+		d *= 2
+	}
+	if d > settings.LoginLockout {
+		d = settings.LoginLockout
+	}
+	return d
+}
+
+// время блокировки после failures неудачных попыток подряд: до порога threshold блокировки нет,
+// затем settings.LoginLockout, удваивается с каждой следующей попыткой до settings.LoginLockoutMax
+func lockoutDuration(failures int, threshold int) time.Duration {
+	if threshold <= 0 || failures < threshold {
+		return 0
+	}
+	d := settings.LoginLockout
+	for i := threshold; i < failures && d < settings.LoginLockoutMax; i++ {
+		d *= 2
+	}
+	if d > settings.LoginLockoutMax {
+		d = settings.LoginLockoutMax
+	}
+	return d
+}
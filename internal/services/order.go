@@ -2,72 +2,82 @@ package services
 
 import (
 	"context"
-	"net/http"
 
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
-
-	"github.com/rs/zerolog/log"
 )
 
 // интерфейс методов хранилища для Order
 type OrderStorageProvider interface {
 	Load(ctx context.Context, login string, orderNum string) (err error)
-	List(ctx context.Context, login string) (ec []models.OrdersList, err error)
+	List(ctx context.Context, login string, q models.ListQuery) (ec []models.OrdersList, err error)
+	ListEach(ctx context.Context, login string, q models.ListQuery, fn func(models.OrdersList) error) (err error)
+	Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error)
 }
 
 type PoolProvider interface {
 	AppendTask(login, orderNum string)
 }
 
-type RequestProvider interface {
-	RequestGet(url string) (rsp *http.Response, err error)
-	RequestPost(orderNum string) (err error)
-}
-
 // структура конструктора бизнес логики Order
 type OrderService struct {
-	storage     OrderStorageProvider
-	pool        PoolProvider
-	httprequest RequestProvider
+	storage OrderStorageProvider
+	pool    PoolProvider
 }
 
 // конструктор бизнес логики Order
-func NewOrderService(orderStorage OrderStorageProvider, pool PoolProvider, httprequest RequestProvider) *OrderService {
+func NewOrderService(orderStorage OrderStorageProvider, pool PoolProvider) *OrderService {
 	return &OrderService{
 		orderStorage,
 		pool,
-		httprequest,
 	}
 }
 
 // сервис загрузки пользователем в систему начисления баллов номера нового заказа для расчёта
+// заказ принимается независимо от доступности системы расчета баллов: регистрация заказа
+// в системе и опрос статуса выполняются пулом воркеров, когда система доступна
 func (svc *OrderService) Load(ctx context.Context, login string, orderNum string) (err error) {
-	// проверка up and running внешнего сервиса
-	r, err := svc.httprequest.RequestGet("")
-	if err != nil {
-		log.Printf("remote service request error (from OrderService Load): %s", err)
-		return err
-	}
-	r.Body.Close()
 	// запись нового заказа в хранилище
 	err = svc.storage.Load(ctx, login, orderNum)
 	if err != nil {
 		return err
 	}
-	// запрос регистрации заказа в системе расчета баллов
-	err = svc.httprequest.RequestPost(orderNum)
-	if err != nil {
-		log.Printf("http Post request in ServiceNewOrderLoad error:%s", err)
-		return err
-	}
-	// отпарвляем запрос в пул воркеров для обработки
+	// отпарвляем заказ, ожидающий регистрации, в пул воркеров для обработки
 	svc.pool.AppendTask(login, orderNum)
 	return err
 }
 
-// сервис получения списка размещенных пользователем заказов, сортировка выдачи по времени загрузки
-func (svc *OrderService) List(ctx context.Context, login string) (ec []models.OrdersList, err error) {
-	ec, err = svc.storage.List(ctx, login)
+// сервис получения списка размещенных пользователем заказов с фильтрами по статусу и периоду загрузки,
+// сортировка выдачи по времени загрузки, next - курсор следующей страницы, пустой для последней страницы
+func (svc *OrderService) List(ctx context.Context, login string, dc models.DecodeListQuery) (ec []models.OrdersList, next string, err error) {
+	q, err := ParseListQuery(dc, models.OrderStatuses)
+	if err != nil {
+		return ec, "", err
+	}
+	ec, err = svc.storage.List(ctx, login, pageQuery(q))
+	if err != nil {
+		return ec, "", err
+	}
+	// отбрасываем запись следующей страницы и выдаем курсор последней записи страницы
+	n, more := pageSize(q, len(ec))
+	ec = ec[:n]
+	if more {
+		next = EncodeCursor(models.Cursor{Time: ec[n-1].UploadedAt, Key: ec[n-1].Number})
+	}
 	// возвращаем структуру и ошибку
-	return ec, err
+	return ec, next, nil
+}
+
+// сервис потоковой выдачи списка размещенных пользователем заказов с фильтрами по статусу и периоду загрузки:
+// fn вызывается для каждого заказа по мере чтения из хранилища без сборки списка в памяти
+func (svc *OrderService) ListEach(ctx context.Context, login string, dc models.DecodeListQuery, fn func(models.OrdersList) error) (err error) {
+	q, err := ParseListQuery(dc, models.OrderStatuses)
+	if err != nil {
+		return err
+	}
+	return svc.storage.ListEach(ctx, login, q, fn)
+}
+
+// сервис получения заказа пользователя с историей изменения статуса и начислением
+func (svc *OrderService) Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error) {
+	return svc.storage.Order(ctx, login, orderNum)
 }
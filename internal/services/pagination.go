@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+)
+
+// ParseListQuery проверяет параметры запроса списка и приводит их к параметрам выборки
+// statuses - допустимые значения фильтра status, nil - список не поддерживает фильтр по статусу
+// возвращает *models.ValidationError со списком всех нарушенных правил
+func ParseListQuery(dc models.DecodeListQuery, statuses []string) (q models.ListQuery, err error) {
+	var violations []models.Violation
+	add := func(field string, rule string, message string) {
+		violations = append(violations, models.Violation{Field: field, Rule: rule, Message: message})
+	}
+	// размер страницы, без limit выдается весь список
+	if dc.Limit != "" {
+		limit, err := strconv.Atoi(dc.Limit)
+		switch {
+		case err != nil:
+			add("limit", "integer", "limit must be an integer")
+		case limit < 1 || limit > settings.ListMaxLimit:
+			add("limit", "range", fmt.Sprintf("limit must be from 1 to %d", settings.ListMaxLimit))
+		default:
+			q.Limit = limit
+		}
+	}
+	// курсор последней выданной записи предыдущей страницы
+	if dc.After != "" {
+		cursor, err := DecodeCursor(dc.After)
+		if err != nil {
+			add("after", "cursor", "after must be a cursor returned with the previous page")
+		} else {
+			q.After = &cursor
+		}
+	}
+	// фильтр по статусу: несколько параметров status или значения через запятую
+	for _, value := range dc.Status {
+		for _, status := range strings.Split(value, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+			switch {
+			case status == "":
+			case statuses == nil:
+				add("status", "unsupported", "status filter is not supported for this list")
+			case !models.Contains(statuses, status):
+				add("status", "oneof", "status must be one of: "+strings.Join(statuses, ", "))
+			default:
+				q.Statuses = append(q.Statuses, status)
+			}
+		}
+	}
+	// период: from включительно, to не включительно, дата без времени в to включает весь день
+	var ok bool
+	if q.From, ok = parseListTime(dc.From, false); !ok {
+		add("from", "format", "from must be a date YYYY-MM-DD or a RFC 3339 timestamp")
+	}
+	if q.To, ok = parseListTime(dc.To, true); !ok {
+		add("to", "format", "to must be a date YYYY-MM-DD or a RFC 3339 timestamp")
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.To.After(q.From) {
+		add("to", "range", "to must be later than from")
+	}
+	// направление сортировки, по умолчанию от старых записей к новым
+	switch strings.ToLower(dc.Sort) {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		add("sort", "oneof", "sort must be one of: asc, desc")
+	}
+	if len(violations) > 0 {
+		return q, &models.ValidationError{Violations: violations}
+	}
+	return q, nil
+}
+
+// разбор даты YYYY-MM-DD или метки времени RFC 3339, пустое значение - без ограничения
+// endOfDay - дата без времени означает конец дня
+func parseListTime(value string, endOfDay bool) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, true
+}
+
+// EncodeCursor кодирует курсор постраничной выдачи в непрозрачную строку для параметра after
+func EncodeCursor(cursor models.Cursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursor.Time.Format(time.RFC3339Nano) + " " + cursor.Key))
+}
+
+// DecodeCursor декодирует курсор постраничной выдачи из параметра after
+func DecodeCursor(s string) (cursor models.Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	t, key, ok := strings.Cut(string(b), " ")
+	if !ok || key == "" {
+		return cursor, errors.New("invalid cursor")
+	}
+	cursor.Time, err = time.Parse(time.RFC3339Nano, t)
+	cursor.Key = key
+	return cursor, err
+}
+
+// увеличивает размер выборки на одну запись, чтобы определить наличие следующей страницы
+func pageQuery(q models.ListQuery) models.ListQuery {
+	if q.Limit > 0 {
+		q.Limit++
+	}
+	return q
+}
+
+// количество записей страницы и признак наличия следующей страницы для выборки из n записей
+func pageSize(q models.ListQuery, n int) (int, bool) {
+	if q.Limit > 0 && n > q.Limit {
+		return q.Limit, true
+	}
+	return n, false
+}
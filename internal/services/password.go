@@ -0,0 +1,93 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// параметры хеширования паролей argon2id
+const (
+	argonTime    uint32 = 1
+	argonMemory  uint32 = 64 * 1024
+	argonThreads uint8  = 4
+	argonKeyLen  uint32 = 32
+	argonSaltLen        = 16
+)
+
+// допустимые параметры сохраненного хеша, хеш с параметрами вне границ считается поврежденным:
+// argon2 паникует при нулевых параметрах, а завышенные параметры нагружают сервер при каждой проверке пароля
+const (
+	argonMaxTime    uint32 = 16
+	argonMaxMemory  uint32 = 1024 * 1024
+	argonMinSaltLen        = 8
+	argonMaxSaltLen        = 64
+	argonMinKeyLen         = 16
+	argonMaxKeyLen         = 64
+)
+
+// ошибка разбора сохраненного хеша пароля
+var errInvalidHash = errors.New("invalid password hash format")
+
+// HashPassword хеширует пароль алгоритмом argon2id со случайной солью
+// и кодирует результат в формате PHC: $argon2id$v=19$m=65536,t=1,p=4$<соль>$<хеш>
+func HashPassword(password string) (encoded string, err error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err = rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	encoded = fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argonMemory, argonTime, argonThreads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key))
+	return encoded, err
+}
+
+// VerifyPassword проверяет пароль по сохраненному хешу в формате PHC argon2id или по устаревшему хешу SHA-256
+// rehash == true, если пароль верный, но хеш необходимо пересчитать с текущими параметрами
+func VerifyPassword(password string, encoded string) (ok bool, rehash bool, err error) {
+	// устаревший несоленый хеш SHA-256 в hex
+	if !strings.HasPrefix(encoded, "$") {
+		passwHex, err := ToHex(password)
+		if err != nil {
+			return false, false, err
+		}
+		ok = subtle.ConstantTimeCompare([]byte(passwHex), []byte(encoded)) == 1
+		return ok, ok, nil
+	}
+	// разбираем хеш в формате PHC
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, false, errInvalidHash
+	}
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false, errInvalidHash
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, errInvalidHash
+	}
+	if time == 0 || time > argonMaxTime || memory == 0 || memory > argonMaxMemory || threads == 0 {
+		return false, false, errInvalidHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < argonMinSaltLen || len(salt) > argonMaxSaltLen {
+		return false, false, errInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < argonMinKeyLen || len(key) > argonMaxKeyLen {
+		return false, false, errInvalidHash
+	}
+	// хешируем пароль с параметрами и солью из сохраненного хеша и сравниваем
+	other := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	ok = subtle.ConstantTimeCompare(key, other) == 1
+	// хеш с устаревшими параметрами пересчитываем
+	rehash = ok && (memory != argonMemory || time != argonTime || threads != argonThreads || uint32(len(key)) != argonKeyLen)
+	return ok, rehash, nil
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// интерфейс методов хранилища для смены и сброса пароля
+type PasswordStorageProvider interface {
+	ChangePassword(ctx context.Context, login string, passwHash string) (err error)
+	PasswordResetCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error)
+	PasswordResetLogin(ctx context.Context, tokenHash string) (login string, err error)
+	PasswordReset(ctx context.Context, tokenHash string, passwHash string) (login string, err error)
+}
+
+// интерфейс отправки уведомлений пользователю
+type Notifier interface {
+	PasswordReset(ctx context.Context, login string, token string, expiresAt time.Time) (err error)
+}
+
+// смена пароля пользователя по текущему паролю, ранее выданные токены становятся недействительными
+// неверный текущий пароль учитывается как неудачная попытка входа по логину и IP адресу
+func (svc *UserService) ChangePassword(ctx context.Context, login string, oldPassword string, newPassword string, ip string) (err error) {
+	// проверка временной блокировки по логину и IP адресу
+	if err = svc.checkLockout(ctx, NormalizeLogin(login), ip); err != nil {
+		return err
+	}
+	// получение хеша текущего пароля из хранилища
+	passwHash, err := svc.storage.PasswordHash(ctx, login)
+	if err != nil {
+		return err
+	}
+	// проверка текущего пароля
+	ok, _, err := VerifyPassword(oldPassword, passwHash)
+	if err != nil {
+		log.Printf("password verification in ServiceChangePassword error :%s", err)
+		return err
+	}
+	if !ok {
+		err = domainerrors.ErrWrongPassword
+		log.Printf("ServiceChangePassword for login %s: %s", login, err)
+		return svc.authorizationFailed(ctx, NormalizeLogin(login), ip, err)
+	}
+	// сбрасываем счетчик неудачных попыток по логину
+	if err := svc.storage.LoginAttemptsReset(ctx, "login:"+NormalizeLogin(login)); err != nil {
+		log.Printf("login attempts reset in ServiceChangePassword error :%s", err)
+	}
+	// проверка нового пароля на соответствие правилам
+	if err = ValidatePassword(login, newPassword); err != nil {
+		log.Printf("ServiceChangePassword validation: %s", err)
+		return err
+	}
+	passwHash, err = HashPassword(newPassword)
+	if err != nil {
+		log.Printf("password hashing in ServiceChangePassword error :%s", err)
+		return err
+	}
+	err = svc.storage.ChangePassword(ctx, login, passwHash)
+	if err != nil {
+		return err
+	}
+	log.Printf("password changed for login %s", login)
+	return nil
+}
+
+// запрос сброса пароля: создание одноразового токена и отправка его пользователю
+// для несуществующего логина ошибка не возвращается, чтобы не раскрывать наличие логина
+// количество запросов по логину и IP адресу ограничивается так же, как неудачные попытки входа
+func (svc *UserService) RequestPasswordReset(ctx context.Context, rawLogin string, ip string) (err error) {
+	// проверка ограничения запросов сброса по логину и IP адресу
+	if err = svc.checkScopeLockout(ctx, scopeReset, NormalizeLogin(rawLogin), ip); err != nil {
+		return err
+	}
+	// учитываем запрос независимо от наличия логина, блокировка применяется к следующим запросам
+	if err := svc.registerAttempt(ctx, scopeReset, NormalizeLogin(rawLogin), ip); err != nil {
+		var lockoutErr *models.LockoutError
+		if !errors.As(err, &lockoutErr) {
+			log.Printf("reset attempt registration in ServiceRequestPasswordReset error :%s", err)
+		}
+	}
+	// находим логин в том виде, в котором он сохранен в хранилище
+	_, login, err := svc.passwordHash(ctx, NormalizeLogin(rawLogin), rawLogin)
+	if errors.Is(err, domainerrors.ErrInvalidCredentials) {
+		log.Printf("ServiceRequestPasswordReset for unknown login %s", rawLogin)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// создаем токен сброса, в хранилище сохраняется только его хеш
+	token, err := randomToken(32)
+	if err != nil {
+		log.Printf("reset token generation in ServiceRequestPasswordReset error :%s", err)
+		return err
+	}
+	expiresAt := time.Now().Add(settings.PasswordResetTTL)
+	err = svc.storage.PasswordResetCreate(ctx, login, tokenHash(token), expiresAt)
+	if err != nil {
+		return err
+	}
+	return svc.notifier.PasswordReset(ctx, login, token, expiresAt)
+}
+
+// установка нового пароля по токену сброса, токен действует однократно
+func (svc *UserService) ConfirmPasswordReset(ctx context.Context, token string, newPassword string) (err error) {
+	// проверяем новый пароль до использования токена, чтобы токен не сгорал при неподходящем пароле
+	login, err := svc.storage.PasswordResetLogin(ctx, tokenHash(token))
+	if err != nil {
+		return err
+	}
+	if err = ValidatePassword(login, newPassword); err != nil {
+		log.Printf("ServiceConfirmPasswordReset validation: %s", err)
+		return err
+	}
+	passwHash, err := HashPassword(newPassword)
+	if err != nil {
+		log.Printf("password hashing in ServiceConfirmPasswordReset error :%s", err)
+		return err
+	}
+	login, err = svc.storage.PasswordReset(ctx, tokenHash(token), passwHash)
+	if err != nil {
+		return err
+	}
+	// сбрасываем блокировку входа по логину
+	if err := svc.storage.LoginAttemptsReset(ctx, "login:"+NormalizeLogin(login)); err != nil {
+		log.Printf("login attempts reset in ServiceConfirmPasswordReset error :%s", err)
+	}
+	log.Printf("password reset for login %s", login)
+	return nil
+}
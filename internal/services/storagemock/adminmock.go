@@ -0,0 +1,126 @@
+package storagemock
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/shopspring/decimal"
+)
+
+// имплементация интерфейса AdminStorageProvider
+type Admin struct {
+	// роли, установленные по логинам
+	Roles map[string]string
+}
+
+func (mst *Admin) AdminUser(ctx context.Context, login string) (ec models.AdminUser, err error) {
+	switch login {
+	// LegacyUser - логин, зарегистрированный до приведения логинов к нижнему регистру
+	case "dimma", "dimma2", "LegacyUser":
+		ec = models.AdminUser{
+			Login: login,
+			Role:  models.RoleUser,
+			Balance: models.LoginBalance{
+				Current:   decimal.NewFromFloatWithExponent(500.505, -2),
+				Withdrawn: decimal.NewFromFloatWithExponent(42, -2),
+			},
+			Orders: 1,
+		}
+		if role, ok := mst.Roles[login]; ok {
+			ec.Role = role
+		}
+		return ec, nil
+	default:
+		return ec, domainerrors.ErrLoginNotFound
+	}
+}
+
+func (mst *Admin) AdminOrder(ctx context.Context, orderNum string) (ec models.AdminOrder, err error) {
+	if orderNum == "2377225624" {
+		ec.Login = "dimma"
+		ec.OrdersList = models.OrdersList{
+			Number:     orderNum,
+			Status:     "PROCESSED",
+			Accrual:    decimal.NewFromFloatWithExponent(500, -2),
+			UploadedAt: time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC),
+		}
+		return ec, nil
+	}
+	if orderNum == "12345678903" {
+		ec.Login = "dimma2"
+		ec.OrdersList = models.OrdersList{
+			Number:     orderNum,
+			Status:     "PROCESSING",
+			UploadedAt: time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC),
+		}
+		return ec, nil
+	}
+	return ec, domainerrors.ErrOrderNotFound
+}
+
+func (mst *Admin) List(ctx context.Context, login string, q models.ListQuery) (ec []models.OrdersList, err error) {
+	if login == "dimma" {
+		return []models.OrdersList{{Number: "2377225624", Status: "PROCESSED"}}, nil
+	}
+	return ec, domainerrors.ErrNoOrders
+}
+
+func (mst *Admin) VerifyBalance(ctx context.Context, login string) (ec models.BalanceCheck, err error) {
+	ec = models.BalanceCheck{Login: login, Consistent: true}
+	return ec, nil
+}
+
+func (mst *Admin) WithdrawalsList(ctx context.Context, login string, q models.ListQuery) (ec []models.WithdrawalsList, err error) {
+	if login == "dimma" {
+		return []models.WithdrawalsList{{Order: "2377225624", Sum: decimal.NewFromFloatWithExponent(42, -2)}}, nil
+	}
+	return ec, domainerrors.ErrNoRecords
+}
+
+func (mst *Admin) SetUserRole(ctx context.Context, login string, role string) (err error) {
+	if _, err = mst.AdminUser(ctx, login); err != nil {
+		return err
+	}
+	if mst.Roles == nil {
+		mst.Roles = make(map[string]string)
+	}
+	mst.Roles[login] = role
+	return nil
+}
+
+func (mst *Admin) NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error) {
+	user, err := mst.AdminUser(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	ec = user.Balance
+	if dc.Operation == models.AdjustmentDebit {
+		if dc.Amount.GreaterThan(ec.Current) {
+			return ec, domainerrors.ErrInsufficientFunds
+		}
+		ec.Current = ec.Current.Sub(dc.Amount)
+		return ec, nil
+	}
+	ec.Current = ec.Current.Add(dc.Amount)
+	return ec, nil
+}
+
+func (mst *Admin) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	if login == "dimma" {
+		return []models.LedgerEntry{{Login: login, EntryType: models.EntryManualAdjustment, Reason: "GOODWILL", CreatedBy: "admin"}}, nil
+	}
+	return ec, domainerrors.ErrNoRecords
+}
+
+func (mst *Admin) OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error) {
+	ec.AdminOrder, err = mst.AdminOrder(ctx, orderNum)
+	if err != nil {
+		return ec, err
+	}
+	ec.History = []models.OrderStatusChange{{Status: "NEW"}, {Status: ec.Status, Accrual: ec.Accrual}}
+	ec.LastPoll = &models.AccrualPoll{OrderNum: orderNum, Attempt: 1, StatusCode: 200}
+	ec.Attempts = 1
+	return ec, nil
+}
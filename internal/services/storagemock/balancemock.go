@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/shopspring/decimal"
 )
@@ -33,7 +34,7 @@ func (mst *Balance) NewWithdrawal(ctx context.Context, login string, dc models.N
 	return err
 }
 
-func (mst *Balance) WithdrawalsList(ctx context.Context, login string) (ec []models.WithdrawalsList, err error) {
+func (mst *Balance) WithdrawalsList(ctx context.Context, login string, q models.ListQuery) (ec []models.WithdrawalsList, err error) {
 	if login == "dimma" {
 
 		ec = []models.WithdrawalsList{
@@ -47,8 +48,43 @@ func (mst *Balance) WithdrawalsList(ctx context.Context, login string) (ec []mod
 				Sum:         decimal.NewFromFloatWithExponent(800.5555, -2),
 				ProcessedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
 			}}
+		// ограничение выборки
+		if q.Limit > 0 && q.Limit < len(ec) {
+			ec = ec[:q.Limit]
+		}
 		return ec, nil
 	}
 	err = errors.New("something wrong woth server")
 	return nil, err
 }
+
+func (mst *Balance) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	if login == "dimma" {
+		ec = []models.LedgerEntry{
+			{
+				Login:         login,
+				EntryType:     models.EntryManualAdjustment,
+				DebitAccount:  models.AccountAdjustments,
+				CreditAccount: "user:" + login,
+				Amount:        decimal.NewFromFloatWithExponent(100, -2),
+				Reason:        "GOODWILL",
+				Comment:       "compensation for delayed accrual",
+				CreatedBy:     "admin",
+			}}
+		return ec, nil
+	}
+	return nil, domainerrors.ErrNoRecords
+}
+
+func (mst *Balance) WithdrawalsEach(ctx context.Context, login string, q models.ListQuery, fn func(models.WithdrawalsList) error) (err error) {
+	ec, err := mst.WithdrawalsList(ctx, login, q)
+	if err != nil {
+		return err
+	}
+	for _, v := range ec {
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
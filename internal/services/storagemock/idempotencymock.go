@@ -0,0 +1,27 @@
+package storagemock
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// имплементация интерфейса IdempotencyStorageProvider
+type Idempotency struct {
+	// время резервирования ключа, переданное в хранилище
+	Lease time.Duration
+}
+
+func (mst *Idempotency) IdempotencyReserve(ctx context.Context, login string, key string, rec models.IdempotencyRecord, window time.Duration, lease time.Duration) (ec models.IdempotencyRecord, reserved bool, err error) {
+	mst.Lease = lease
+	return rec, true, nil
+}
+
+func (mst *Idempotency) IdempotencySave(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error) {
+	return nil
+}
+
+func (mst *Idempotency) IdempotencyRelease(ctx context.Context, login string, key string) (err error) {
+	return nil
+}
@@ -0,0 +1,20 @@
+package storagemock
+
+import (
+	"context"
+	"time"
+)
+
+// имплементация интерфейса Notifier с сохранением отправленных токенов сброса пароля
+type Notifier struct {
+	// последний отправленный токен по логину
+	Tokens map[string]string
+}
+
+func (mst *Notifier) PasswordReset(ctx context.Context, login string, token string, expiresAt time.Time) (err error) {
+	if mst.Tokens == nil {
+		mst.Tokens = make(map[string]string)
+	}
+	mst.Tokens[login] = token
+	return nil
+}
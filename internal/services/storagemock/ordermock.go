@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/shopspring/decimal"
 )
@@ -21,7 +22,7 @@ func (mst *Order) Load(ctx context.Context, login string, orderNum string) (err
 	return err
 }
 
-func (mst *Order) List(ctx context.Context, login string) (ec []models.OrdersList, err error) {
+func (mst *Order) List(ctx context.Context, login string, q models.ListQuery) (ec []models.OrdersList, err error) {
 	if login == "dimma" {
 
 		ec = []models.OrdersList{
@@ -42,8 +43,43 @@ func (mst *Order) List(ctx context.Context, login string) (ec []models.OrdersLis
 				UploadedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
 			},
 		}
+		// ограничение выборки
+		if q.Limit > 0 && q.Limit < len(ec) {
+			ec = ec[:q.Limit]
+		}
 		return ec, nil
 	}
 	err = errors.New("something wrong with server")
 	return nil, err
 }
+
+func (mst *Order) Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error) {
+	if login == "dimma" && orderNum == "9278923470" {
+		ec.OrdersList = models.OrdersList{
+			Number:     orderNum,
+			Status:     "PROCESSED",
+			Accrual:    decimal.NewFromFloatWithExponent(500, -2),
+			UploadedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local),
+		}
+		ec.History = []models.OrderStatusChange{
+			{Status: "NEW", ChangedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.Local)},
+			{Status: "PROCESSING", ChangedAt: time.Date(2020, time.May, 15, 17, 46, 0, 0, time.Local)},
+			{Status: "PROCESSED", Accrual: decimal.NewFromFloatWithExponent(500, -2), ChangedAt: time.Date(2020, time.May, 15, 17, 47, 0, 0, time.Local)},
+		}
+		return ec, nil
+	}
+	return ec, domainerrors.ErrOrderNotFound
+}
+
+func (mst *Order) ListEach(ctx context.Context, login string, q models.ListQuery, fn func(models.OrdersList) error) (err error) {
+	ec, err := mst.List(ctx, login, q)
+	if err != nil {
+		return err
+	}
+	for _, v := range ec {
+		if err = fn(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
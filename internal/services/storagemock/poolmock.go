@@ -0,0 +1,19 @@
+package storagemock
+
+import "context"
+
+// имплементация интерфейсов PoolProvider и RequeueProvider
+type Pool struct {
+	Tasks []string
+	// заказы, поставленные в очередь для немедленного опроса
+	Requeued []string
+}
+
+func (mp *Pool) AppendTask(login, orderNum string) {
+	mp.Tasks = append(mp.Tasks, orderNum)
+}
+
+func (mp *Pool) Requeue(ctx context.Context, login, orderNum string) (err error) {
+	mp.Requeued = append(mp.Requeued, orderNum)
+	return nil
+}
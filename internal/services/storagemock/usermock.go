@@ -4,9 +4,41 @@ package storagemock
 import (
 	"context"
 	"errors"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 )
 
 type User struct {
+	// обновленные хеши паролей по логинам
+	Updated map[string]string
+	// хеши токенов обновления: логин владельца и отметка об отзыве
+	Refresh map[string]RefreshToken
+	// идентификаторы отозванных токенов доступа
+	Revoked map[string]time.Time
+	// неудачные попытки входа по ключам
+	Attempts map[string]LoginAttempts
+	// хеши паролей, установленные сменой или сбросом пароля, и время смены
+	Changed   map[string]string
+	ChangedAt map[string]time.Time
+	// хеши токенов сброса пароля: логин владельца и отметка об использовании
+	Resets map[string]RefreshToken
+}
+
+// администратор в заглушке хранилища
+const AdminLogin = "dimmaadmin"
+
+// учет неудачных попыток входа по ключу
+type LoginAttempts struct {
+	Failures    int
+	LockedUntil time.Time
+}
+
+// сохраненный токен обновления
+type RefreshToken struct {
+	Login   string
+	Revoked bool
 }
 
 func (mst *User) Create(ctx context.Context, login string, passwH string) (err error) {
@@ -18,11 +50,163 @@ func (mst *User) Create(ctx context.Context, login string, passwH string) (err e
 
 }
 
-func (mst *User) CheckAuthorization(ctx context.Context, login string, passwHex string) (err error) {
-	if login == "dimma" {
-		return nil
+func (mst *User) PasswordHash(ctx context.Context, login string) (passwHash string, err error) {
+	if passwHash, ok := mst.Changed[login]; ok {
+		return passwHash, nil
+	}
+	switch login {
+	// устаревший хеш SHA-256 пароля "12345"
+	case "dimma":
+		return "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5", nil
+	// логин, зарегистрированный до приведения логинов к нижнему регистру
+	case "dimma4":
+		return "", domainerrors.ErrInvalidCredentials
+	// хеш argon2id пароля "Secret123"
+	case "dimma3", "Dimma4":
+		return "$argon2id$v=19$m=65536,t=1,p=4$h3XVtg4x84dDwhpjtiI4Ug$EfUcoABkerT6C1UFc//ZuY5xZObgwDM7IirOKK17Kbs", nil
 	}
 	err = errors.New("something wrong with server")
-	return err
-	
+	return "", err
+}
+
+func (mst *User) UpdatePasswordHash(ctx context.Context, login string, passwHash string) (err error) {
+	if mst.Updated == nil {
+		mst.Updated = make(map[string]string)
+	}
+	mst.Updated[login] = passwHash
+	return nil
+}
+
+func (mst *User) RefreshTokenCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error) {
+	if login == "dimmaServErr" {
+		return errors.New("something wrong with server")
+	}
+	if mst.Refresh == nil {
+		mst.Refresh = make(map[string]RefreshToken)
+	}
+	mst.Refresh[tokenHash] = RefreshToken{Login: login}
+	return nil
+}
+
+func (mst *User) RefreshTokenRotate(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (login string, err error) {
+	token, ok := mst.Refresh[tokenHash]
+	if !ok {
+		return "", domainerrors.ErrRefreshTokenNotFound
+	}
+	// повторное предъявление замененного токена отзывает все токены пользователя
+	if token.Revoked {
+		mst.RefreshTokensRevoke(ctx, token.Login)
+		return "", domainerrors.ErrRefreshTokenNotFound
+	}
+	mst.Refresh[tokenHash] = RefreshToken{Login: token.Login, Revoked: true}
+	mst.Refresh[newHash] = RefreshToken{Login: token.Login}
+	return token.Login, nil
+}
+
+func (mst *User) RefreshTokensRevoke(ctx context.Context, login string) (err error) {
+	for hash, token := range mst.Refresh {
+		if token.Login == login {
+			mst.Refresh[hash] = RefreshToken{Login: login, Revoked: true}
+		}
+	}
+	return nil
+}
+
+func (mst *User) AccessTokenRevoke(ctx context.Context, jti string, expiresAt time.Time) (err error) {
+	if mst.Revoked == nil {
+		mst.Revoked = make(map[string]time.Time)
+	}
+	mst.Revoked[jti] = expiresAt
+	return nil
+}
+
+func (mst *User) AccessTokenRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error) {
+	_, revoked = mst.Revoked[jti]
+	if changedAt, ok := mst.ChangedAt[login]; ok && !changedAt.Before(issuedAt) {
+		revoked = true
+	}
+	return revoked, nil
+}
+
+func (mst *User) LoginAttemptsLocked(ctx context.Context, keys []string) (lockedUntil time.Time, err error) {
+	for _, key := range keys {
+		if key == "login:dimmaServErr" {
+			return lockedUntil, errors.New("something wrong with server")
+		}
+		if a := mst.Attempts[key]; a.LockedUntil.After(lockedUntil) && a.LockedUntil.After(time.Now()) {
+			lockedUntil = a.LockedUntil
+		}
+	}
+	return lockedUntil, nil
+}
+
+func (mst *User) LoginAttemptFailed(ctx context.Context, key string, window time.Duration) (failures int, err error) {
+	if mst.Attempts == nil {
+		mst.Attempts = make(map[string]LoginAttempts)
+	}
+	a := mst.Attempts[key]
+	a.Failures++
+	mst.Attempts[key] = a
+	return a.Failures, nil
+}
+
+func (mst *User) LoginAttemptLock(ctx context.Context, key string, until time.Time) (err error) {
+	a := mst.Attempts[key]
+	if until.After(a.LockedUntil) {
+		a.LockedUntil = until
+	}
+	mst.Attempts[key] = a
+	return nil
+}
+
+func (mst *User) LoginAttemptsReset(ctx context.Context, key string) (err error) {
+	delete(mst.Attempts, key)
+	return nil
+}
+
+func (mst *User) ChangePassword(ctx context.Context, login string, passwHash string) (err error) {
+	if mst.Changed == nil {
+		mst.Changed = make(map[string]string)
+		mst.ChangedAt = make(map[string]time.Time)
+	}
+	mst.Changed[login] = passwHash
+	mst.ChangedAt[login] = time.Now()
+	mst.RefreshTokensRevoke(ctx, login)
+	for hash, reset := range mst.Resets {
+		if reset.Login == login {
+			mst.Resets[hash] = RefreshToken{Login: login, Revoked: true}
+		}
+	}
+	return nil
+}
+
+func (mst *User) PasswordResetCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error) {
+	if mst.Resets == nil {
+		mst.Resets = make(map[string]RefreshToken)
+	}
+	mst.Resets[tokenHash] = RefreshToken{Login: login}
+	return nil
+}
+
+func (mst *User) PasswordResetLogin(ctx context.Context, tokenHash string) (login string, err error) {
+	reset, ok := mst.Resets[tokenHash]
+	if !ok || reset.Revoked {
+		return "", domainerrors.ErrResetTokenNotFound
+	}
+	return reset.Login, nil
+}
+
+func (mst *User) PasswordReset(ctx context.Context, tokenHash string, passwHash string) (login string, err error) {
+	login, err = mst.PasswordResetLogin(ctx, tokenHash)
+	if err != nil {
+		return "", err
+	}
+	return login, mst.ChangePassword(ctx, login, passwHash)
+}
+
+func (mst *User) UserRole(ctx context.Context, login string) (role string, err error) {
+	if login == AdminLogin {
+		return models.RoleAdmin, nil
+	}
+	return models.RoleUser, nil
 }
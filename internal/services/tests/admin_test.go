@@ -0,0 +1,203 @@
+package service__test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services/storagemock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Admin(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test for user lookup - login is normalized", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		ec, err := svc.User(ctx, " Dimma ")
+		assert.NoError(t, err)
+		assert.Equal(t, "dimma", ec.Login)
+		assert.Equal(t, models.RoleUser, ec.Role)
+	})
+
+	t.Run("Negative test for user orders - unknown login", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		_, err := svc.Orders(ctx, "dimma4")
+		assert.Equal(t, domainerrors.ErrLoginNotFound, err)
+	})
+
+	t.Run("Negative test for user orders - no orders", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		_, err := svc.Orders(ctx, "dimma2")
+		assert.Equal(t, domainerrors.ErrNoOrders, err)
+	})
+
+	t.Run("Positive test for user balance", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		ec, err := svc.Balance(ctx, "DIMMA")
+		assert.NoError(t, err)
+		assert.Equal(t, "dimma", ec.Login)
+	})
+
+	t.Run("Positive test for role change", func(t *testing.T) {
+		s := &storagemock.Admin{}
+		svc := services.NewAdminService(s, &storagemock.Pool{})
+		err := svc.SetRole(ctx, "Dimma", models.RoleAdmin)
+		assert.NoError(t, err)
+		ec, err := svc.User(ctx, "dimma")
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAdmin, ec.Role)
+	})
+
+	t.Run("Positive test for role change - legacy mixed-case login", func(t *testing.T) {
+		s := &storagemock.Admin{}
+		svc := services.NewAdminService(s, &storagemock.Pool{})
+		err := svc.SetRole(ctx, " LegacyUser ", models.RoleAdmin)
+		assert.NoError(t, err)
+		assert.Equal(t, models.RoleAdmin, s.Roles["LegacyUser"])
+		ec, err := svc.User(ctx, "LegacyUser")
+		assert.NoError(t, err)
+		assert.Equal(t, "LegacyUser", ec.Login)
+	})
+
+	t.Run("Negative test for role change - unknown login", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		err := svc.SetRole(ctx, "Dimma4", models.RoleAdmin)
+		assert.Equal(t, domainerrors.ErrLoginNotFound, err)
+	})
+
+	t.Run("Negative test for role change - unknown role", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		err := svc.SetRole(ctx, "dimma", "root")
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+	})
+}
+
+func TestService_OrderRepoll(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test for order diagnostics", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		ec, err := svc.OrderDiagnostics(ctx, "12345678903")
+		assert.NoError(t, err)
+		assert.Equal(t, "dimma2", ec.Login)
+		assert.Len(t, ec.History, 2)
+		assert.Equal(t, 200, ec.LastPoll.StatusCode)
+	})
+
+	t.Run("Positive test for repoll - order requeued with owner login", func(t *testing.T) {
+		pool := &storagemock.Pool{}
+		svc := services.NewAdminService(&storagemock.Admin{}, pool)
+		err := svc.Repoll(ctx, "12345678903")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"12345678903"}, pool.Requeued)
+	})
+
+	t.Run("Negative test for repoll - final status", func(t *testing.T) {
+		pool := &storagemock.Pool{}
+		svc := services.NewAdminService(&storagemock.Admin{}, pool)
+		err := svc.Repoll(ctx, "2377225624")
+		assert.Equal(t, domainerrors.ErrOrderFinal, err)
+		assert.Empty(t, pool.Requeued)
+	})
+
+	t.Run("Negative test for repoll - unknown order", func(t *testing.T) {
+		svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+		err := svc.Repoll(ctx, "79927398713")
+		assert.Equal(t, domainerrors.ErrOrderNotFound, err)
+	})
+}
+
+func TestService_Adjustment(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	svc := services.NewAdminService(&storagemock.Admin{}, &storagemock.Pool{})
+
+	t.Run("Positive test for balance adjustment - credit", func(t *testing.T) {
+		ec, err := svc.NewAdjustment(ctx, "Dimma", models.NewAdjustment{
+			Operation: models.AdjustmentCredit,
+			Amount:    decimal.RequireFromString("100.25"),
+			Reason:    "GOODWILL",
+			Comment:   "  compensation for delayed accrual ",
+			CreatedBy: "admin",
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "600.75", ec.Current.StringFixed(2))
+	})
+
+	t.Run("Negative test for balance adjustment - debit over balance", func(t *testing.T) {
+		_, err := svc.NewAdjustment(ctx, "dimma", models.NewAdjustment{
+			Operation: models.AdjustmentDebit,
+			Amount:    decimal.RequireFromString("1000"),
+			Reason:    "DUPLICATE_ACCRUAL",
+			Comment:   "order accrued twice",
+		})
+		assert.Equal(t, domainerrors.ErrInsufficientFunds, err)
+	})
+
+	t.Run("Negative test for balance adjustment - all violations", func(t *testing.T) {
+		_, err := svc.NewAdjustment(ctx, "dimma", models.NewAdjustment{
+			Operation: "refund",
+			Amount:    decimal.RequireFromString("1.005"),
+			Reason:    "BECAUSE",
+			Comment:   "   ",
+		})
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "validation failed: operation.oneof, amount.precision, reason.oneof, comment.required", err.Error())
+	})
+
+	t.Run("Negative test for balance adjustment - zero amount", func(t *testing.T) {
+		_, err := svc.NewAdjustment(ctx, "dimma", models.NewAdjustment{
+			Operation: models.AdjustmentCredit,
+			Reason:    "GOODWILL",
+			Comment:   "compensation",
+		})
+		assert.Equal(t, "validation failed: amount.positive", err.Error())
+	})
+
+	t.Run("Positive test for user history - adjustment author hidden", func(t *testing.T) {
+		ec, err := services.NewBalanceService(&storagemock.Balance{}).History(ctx, "dimma")
+		assert.NoError(t, err)
+		assert.Equal(t, "GOODWILL", ec[0].Reason)
+		assert.Empty(t, ec[0].CreatedBy)
+	})
+
+	t.Run("Positive test for admin history - adjustment author shown", func(t *testing.T) {
+		ec, err := svc.History(ctx, "dimma")
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", ec[0].CreatedBy)
+	})
+}
+
+func TestService_TokenRole(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	svc := services.NewUserService(&storagemock.User{}, keys, &storagemock.Notifier{})
+	// роль пользователя передается в claims токена доступа
+	for login, role := range map[string]string{"dimma": models.RoleUser, storagemock.AdminLogin: models.RoleAdmin} {
+		pair, err := svc.IssueTokens(ctx, login)
+		assert.NoError(t, err)
+		token, err := keys.Decode(pair.AccessToken)
+		assert.NoError(t, err)
+		claim, _ := token.Get("role")
+		assert.Equal(t, role, claim)
+	}
+}
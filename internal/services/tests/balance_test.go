@@ -161,7 +161,7 @@ func TestHandler_WithdrawalsList(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
 			// освобождаем ресурс
 			defer cancel()
-			ec, err := svc.WithdrawalsList(ctx, tCase.inputLogin)
+			ec, _, err := svc.WithdrawalsList(ctx, tCase.inputLogin, models.DecodeListQuery{})
 			// оценка результатов
 			assert.Equal(t, tCase.expectedError, err)
 			if tCase.inputLogin == "dimma" {
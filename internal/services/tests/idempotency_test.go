@@ -0,0 +1,59 @@
+package service__test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services/storagemock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_IdempotencyLease(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// восстанавливаем настройку после теста
+	lease := settings.IdempotencyLease
+	defer func() { settings.IdempotencyLease = lease }()
+	// табличный тест
+	tests := []struct {
+		name  string
+		lease time.Duration
+		want  time.Duration
+	}{
+		{
+			name:  "Positive test - default lease outlives request timeout",
+			lease: lease,
+			want:  settings.IdempotencyLeaseMin,
+		},
+		{
+			name:  "Positive test - lease shorter than request timeout is raised",
+			lease: time.Minute,
+			want:  settings.IdempotencyLeaseMin,
+		},
+		{
+			name:  "Positive test - longer lease is kept",
+			lease: time.Hour,
+			want:  time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings.IdempotencyLease = tt.lease
+			st := &storagemock.Idempotency{}
+			svc := services.NewIdempotencyService(st)
+			_, reserved, err := svc.Begin(ctx, "dimma", "key-1", models.IdempotencyRecord{})
+			assert.NoError(t, err)
+			assert.True(t, reserved)
+			assert.Equal(t, tt.want, st.Lease)
+			assert.Greater(t, st.Lease, settings.StorageTimeout)
+		})
+	}
+}
@@ -3,11 +3,10 @@ package service__test
 import (
 	"context"
 	"errors"
-	"net/url"
 	"testing"
 	"time"
 
-	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
@@ -33,11 +32,17 @@ func TestHandler_Load(t *testing.T) {
 			inputOrderNum: "2377225624",
 			expectedError: nil,
 		},
+		{
+			name:          "Negative test for order load - storage error, task not queued",
+			inputLogin:    "dimma2",
+			inputOrderNum: "12345678903",
+			expectedError: errors.New("something wrong with server"),
+		},
 	}
 
 	s := &storagemock.Order{}
-	BaseURL, _ := url.Parse(settings.DefCalcSysURL)
-	svc := services.NewOrderService(s, nil, httprequest.NewHTTPRequst(BaseURL))
+	p := &storagemock.Pool{}
+	svc := services.NewOrderService(s, p)
 
 	for _, tCase := range tests {
 		// запускаем каждый тест
@@ -48,7 +53,12 @@ func TestHandler_Load(t *testing.T) {
 			defer cancel()
 			err := svc.Load(ctx, tCase.inputLogin, tCase.inputOrderNum)
 			// оценка результатов
-			assert.Equal(t, err, err)
+			assert.Equal(t, tCase.expectedError, err)
+			if tCase.expectedError == nil {
+				assert.Contains(t, p.Tasks, tCase.inputOrderNum)
+			} else {
+				assert.NotContains(t, p.Tasks, tCase.inputOrderNum)
+			}
 		})
 	}
 }
@@ -113,7 +123,7 @@ func TestHandler_List(t *testing.T) {
 	}
 
 	s := &storagemock.Order{}
-	svc := services.NewOrderService(s, nil, nil)
+	svc := services.NewOrderService(s, nil)
 
 	for _, tCase := range tests {
 		// запускаем каждый тест
@@ -122,7 +132,7 @@ func TestHandler_List(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
 			// освобождаем ресурс
 			defer cancel()
-			ec, err := svc.List(ctx, tCase.inputLogin)
+			ec, _, err := svc.List(ctx, tCase.inputLogin, models.DecodeListQuery{})
 			// оценка результатов
 			assert.Equal(t, tCase.expectedError, err)
 			if tCase.inputLogin == "dimma" {
@@ -131,3 +141,27 @@ func TestHandler_List(t *testing.T) {
 		})
 	}
 }
+
+func TestService_Order(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	svc := services.NewOrderService(&storagemock.Order{}, nil)
+
+	t.Run("Positive test for order timeline", func(t *testing.T) {
+		ec, err := svc.Order(ctx, "dimma", "9278923470")
+		assert.NoError(t, err)
+		assert.Equal(t, "PROCESSED", ec.Status)
+		statuses := []string{}
+		for _, change := range ec.History {
+			statuses = append(statuses, change.Status)
+		}
+		assert.Equal(t, []string{"NEW", "PROCESSING", "PROCESSED"}, statuses)
+	})
+
+	t.Run("Negative test for order timeline - order of another login", func(t *testing.T) {
+		_, err := svc.Order(ctx, "dimma2", "9278923470")
+		assert.Equal(t, domainerrors.ErrOrderNotFound, err)
+	})
+}
@@ -0,0 +1,116 @@
+package service__test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services/storagemock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_ParseListQuery(t *testing.T) {
+	cursor := models.Cursor{Time: time.Date(2020, time.May, 15, 17, 45, 12, 123456000, time.UTC), Key: "12345678903"}
+
+	t.Run("Positive test for list query - all parameters", func(t *testing.T) {
+		q, err := services.ParseListQuery(models.DecodeListQuery{
+			Limit:  "20",
+			After:  services.EncodeCursor(cursor),
+			Status: []string{"new,processing", "PROCESSED"},
+			From:   "2020-05-01",
+			To:     "2020-05-15",
+			Sort:   "desc",
+		}, models.OrderStatuses)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ListQuery{
+			Limit:    20,
+			After:    &cursor,
+			Statuses: []string{"NEW", "PROCESSING", "PROCESSED"},
+			From:     time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC),
+			To:       time.Date(2020, time.May, 16, 0, 0, 0, 0, time.UTC),
+			Desc:     true,
+		}, q)
+	})
+
+	t.Run("Positive test for list query - no parameters", func(t *testing.T) {
+		q, err := services.ParseListQuery(models.DecodeListQuery{}, models.OrderStatuses)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ListQuery{}, q)
+	})
+
+	t.Run("Negative test for list query - all violations", func(t *testing.T) {
+		_, err := services.ParseListQuery(models.DecodeListQuery{
+			Limit:  "0",
+			After:  "not a cursor",
+			Status: []string{"DONE"},
+			From:   "yesterday",
+			To:     "2020-13-01",
+			Sort:   "random",
+		}, models.OrderStatuses)
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		assert.Equal(t, "validation failed: limit.range, after.cursor, status.oneof, from.format, to.format, sort.oneof", err.Error())
+	})
+
+	t.Run("Negative test for list query - empty period and unsupported status", func(t *testing.T) {
+		_, err := services.ParseListQuery(models.DecodeListQuery{
+			Limit:  "ten",
+			Status: []string{"NEW"},
+			From:   "2020-05-15T00:00:00Z",
+			To:     "2020-05-01T00:00:00Z",
+		}, nil)
+		assert.Equal(t, "validation failed: limit.integer, status.unsupported, to.range", err.Error())
+	})
+
+	t.Run("Negative test for list query - limit over maximum", func(t *testing.T) {
+		_, err := services.ParseListQuery(models.DecodeListQuery{Limit: "1001"}, models.OrderStatuses)
+		assert.Equal(t, "validation failed: limit.range", err.Error())
+	})
+}
+
+func TestService_ListPages(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test for order list page - next cursor of last order on page", func(t *testing.T) {
+		svc := services.NewOrderService(&storagemock.Order{}, nil)
+		ec, next, err := svc.List(ctx, "dimma", models.DecodeListQuery{Limit: "2"})
+		assert.NoError(t, err)
+		assert.Len(t, ec, 2)
+		cursor, err := services.DecodeCursor(next)
+		assert.NoError(t, err)
+		assert.Equal(t, ec[1].Number, cursor.Key)
+		assert.True(t, ec[1].UploadedAt.Equal(cursor.Time))
+	})
+
+	t.Run("Positive test for order list page - last page without cursor", func(t *testing.T) {
+		svc := services.NewOrderService(&storagemock.Order{}, nil)
+		ec, next, err := svc.List(ctx, "dimma", models.DecodeListQuery{Limit: "3"})
+		assert.NoError(t, err)
+		assert.Len(t, ec, 3)
+		assert.Empty(t, next)
+	})
+
+	t.Run("Positive test for withdrawals page", func(t *testing.T) {
+		svc := services.NewBalanceService(&storagemock.Balance{})
+		ec, next, err := svc.WithdrawalsList(ctx, "dimma", models.DecodeListQuery{Limit: "1"})
+		assert.NoError(t, err)
+		assert.Len(t, ec, 1)
+		cursor, err := services.DecodeCursor(next)
+		assert.NoError(t, err)
+		assert.Equal(t, "2377225624", cursor.Key)
+	})
+
+	t.Run("Negative test for withdrawals page - status filter", func(t *testing.T) {
+		svc := services.NewBalanceService(&storagemock.Balance{})
+		_, _, err := svc.WithdrawalsList(ctx, "dimma", models.DecodeListQuery{Status: []string{"NEW"}})
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+	})
+}
@@ -3,16 +3,25 @@ package service__test
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/jwtkeys"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services/storagemock"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/stretchr/testify/assert"
 )
 
+// набор ключей подписи токенов для тестов
+var keys, _ = jwtkeys.NewHMAC([]byte("9e9e0b4e6de418b2f84fca35165571c5"))
+
 func TestHandler_Create(t *testing.T) {
 	// определяем структуру теста
 	// создаём массив тестов: имя и желаемый результат
@@ -21,6 +30,7 @@ func TestHandler_Create(t *testing.T) {
 		inputLogin           string
 		inputStruct          models.DecodeLoginPair
 		expectedResponseBody string
+		expectedLogin        string
 		expectedError        error
 	}{
 		// определяем все тесты
@@ -29,14 +39,73 @@ func TestHandler_Create(t *testing.T) {
 			inputLogin: "dimma",
 			inputStruct: models.DecodeLoginPair{
 				Login:    "dimma",
-				Password: "12345",
+				Password: "Secret123",
+			},
+			expectedLogin: "dimma",
+			expectedError: nil,
+		},
+		{
+			name:       "Positive test for user login create - login is case-folded",
+			inputLogin: " Dimma ",
+			inputStruct: models.DecodeLoginPair{
+				Login:    " Dimma ",
+				Password: "Secret123",
 			},
+			expectedLogin: "dimma",
 			expectedError: nil,
 		},
+		{
+			name:       "Negative test for user login create - empty login and password",
+			inputLogin: "",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "",
+				Password: "",
+			},
+			expectedError: &models.ValidationError{Violations: []models.Violation{
+				{Field: "login", Rule: "required", Message: "login is required"},
+				{Field: "password", Rule: "required", Message: "password is required"},
+			}},
+		},
+		{
+			name:       "Negative test for user login create - weak password and wrong login charset",
+			inputLogin: "_dimma!",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "_dimma!",
+				Password: "dimma",
+			},
+			expectedError: &models.ValidationError{Violations: []models.Violation{
+				{Field: "login", Rule: "charset", Message: "login may contain only latin letters, digits, '.', '_' and '-'"},
+				{Field: "login", Rule: "start", Message: "login must start with a letter or a digit"},
+				{Field: "password", Rule: "length", Message: "password must be at least 8 characters"},
+				{Field: "password", Rule: "digit", Message: "password must contain a digit"},
+			}},
+		},
+		{
+			name:       "Negative test for user login create - password contains login",
+			inputLogin: "dimma",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "dimma",
+				Password: "DIMMA2022",
+			},
+			expectedError: &models.ValidationError{Violations: []models.Violation{
+				{Field: "password", Rule: "login", Message: "password must not contain the login"},
+			}},
+		},
+		{
+			name:       "Negative test for user login create - too short login",
+			inputLogin: "di",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "di",
+				Password: "Secret123",
+			},
+			expectedError: &models.ValidationError{Violations: []models.Violation{
+				{Field: "login", Rule: "length", Message: "login length must be from 3 to 64 characters"},
+			}},
+		},
 	}
 
 	s := &storagemock.User{}
-	svc := services.NewUserService(s)
+	svc := services.NewUserService(s, keys, &storagemock.Notifier{})
 
 	for _, tCase := range tests {
 		// запускаем каждый тест
@@ -45,9 +114,10 @@ func TestHandler_Create(t *testing.T) {
 			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
 			// освобождаем ресурс
 			defer cancel()
-			err := svc.Create(ctx, tCase.inputStruct)
+			login, err := svc.Create(ctx, tCase.inputStruct)
 			// оценка результатов
 			assert.Equal(t, tCase.expectedError, err)
+			assert.Equal(t, tCase.expectedLogin, login)
 		})
 	}
 }
@@ -61,32 +131,477 @@ func TestHandler_CheckAuthorization(t *testing.T) {
 		inputStruct          models.DecodeLoginPair
 		expectedResponseBody string
 		expectedError        error
+		expectedRehash       bool
 	}{
 		// определяем все тесты
 		{
-			name:       "Positive test for user CheckAuthorization(",
+			name:       "Positive test for user CheckAuthorization - legacy SHA-256 hash is upgraded",
 			inputLogin: "dimma",
 			inputStruct: models.DecodeLoginPair{
 				Login:    "dimma",
 				Password: "12345",
 			},
+			expectedError:  nil,
+			expectedRehash: true,
+		},
+		{
+			name:       "Positive test for user CheckAuthorization - argon2id hash",
+			inputLogin: "dimma3",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "dimma3",
+				Password: "Secret123",
+			},
+			expectedError: nil,
+		},
+		{
+			name:       "Positive test for user CheckAuthorization - login is case-folded",
+			inputLogin: "dimma3",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "DIMMA3",
+				Password: "Secret123",
+			},
 			expectedError: nil,
 		},
+		{
+			name:       "Positive test for user CheckAuthorization - legacy mixed-case login",
+			inputLogin: "Dimma4",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "Dimma4",
+				Password: "Secret123",
+			},
+			expectedError: nil,
+		},
+		{
+			name:       "Negative test for user CheckAuthorization - wrong password",
+			inputLogin: "dimma3",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "dimma3",
+				Password: "12345",
+			},
+			expectedError: domainerrors.ErrInvalidCredentials,
+		},
+		{
+			name:       "Negative test for user CheckAuthorization - storage error",
+			inputLogin: "dimma2",
+			inputStruct: models.DecodeLoginPair{
+				Login:    "dimma2",
+				Password: "12345",
+			},
+			expectedError: errors.New("something wrong with server"),
+		},
 	}
 
-	s := &storagemock.User{}
-	svc := services.NewUserService(s)
-
 	for _, tCase := range tests {
 		// запускаем каждый тест
 		t.Run(tCase.name, func(t *testing.T) {
+			s := &storagemock.User{}
+			svc := services.NewUserService(s, keys, &storagemock.Notifier{})
 			// переопередяляем контекст с таймаутом
 			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
 			// освобождаем ресурс
 			defer cancel()
-			err := svc.CheckAuthorization(ctx, tCase.inputStruct)
+			login, err := svc.CheckAuthorization(ctx, tCase.inputStruct, "192.0.2.1")
 			// оценка результатов
 			assert.Equal(t, tCase.expectedError, err)
+			if err == nil {
+				assert.Equal(t, tCase.inputLogin, login)
+			}
+			passwHash, rehashed := s.Updated[tCase.inputLogin]
+			assert.Equal(t, tCase.expectedRehash, rehashed)
+			if rehashed {
+				ok, rehash, err := services.VerifyPassword(tCase.inputStruct.Password, passwHash)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.False(t, rehash)
+			}
 		})
 	}
 }
+
+func TestService_VerifyPassword(t *testing.T) {
+	// соль и ключ сохраненного хеша
+	salt := base64.RawStdEncoding.EncodeToString(make([]byte, 16))
+	key := base64.RawStdEncoding.EncodeToString(make([]byte, 32))
+	// табличный тест: поврежденный хеш отклоняется ошибкой без вызова argon2
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{
+			name:    "Negative test - zero parallelism",
+			encoded: "$argon2id$v=19$m=65536,t=1,p=0$" + salt + "$" + key,
+		},
+		{
+			name:    "Negative test - zero iterations",
+			encoded: "$argon2id$v=19$m=65536,t=0,p=4$" + salt + "$" + key,
+		},
+		{
+			name:    "Negative test - zero memory",
+			encoded: "$argon2id$v=19$m=0,t=1,p=4$" + salt + "$" + key,
+		},
+		{
+			name:    "Negative test - memory out of bounds",
+			encoded: "$argon2id$v=19$m=4294967295,t=1,p=4$" + salt + "$" + key,
+		},
+		{
+			name:    "Negative test - iterations out of bounds",
+			encoded: "$argon2id$v=19$m=65536,t=4294967295,p=4$" + salt + "$" + key,
+		},
+		{
+			name:    "Negative test - empty salt",
+			encoded: "$argon2id$v=19$m=65536,t=1,p=4$$" + key,
+		},
+		{
+			name:    "Negative test - empty key",
+			encoded: "$argon2id$v=19$m=65536,t=1,p=4$" + salt + "$",
+		},
+		{
+			name:    "Negative test - key too long",
+			encoded: "$argon2id$v=19$m=65536,t=1,p=4$" + salt + "$" + base64.RawStdEncoding.EncodeToString(make([]byte, 1024)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ok bool
+			var err error
+			assert.NotPanics(t, func() {
+				ok, _, err = services.VerifyPassword("Secret123", tt.encoded)
+			})
+			assert.Error(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestService_Tokens(t *testing.T) {
+	s := &storagemock.User{}
+	svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test for tokens issue", func(t *testing.T) {
+		pair, err := svc.IssueTokens(ctx, "dimma")
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer", pair.TokenType)
+		assert.NotEmpty(t, pair.RefreshToken)
+		// токен доступа содержит логин и идентификатор
+		token, err := keys.Decode(pair.AccessToken)
+		assert.NoError(t, err)
+		login, _ := token.Get("login")
+		assert.Equal(t, "dimma", login)
+		assert.NotEmpty(t, token.JwtID())
+		// в хранилище сохраняется только хеш токена обновления
+		assert.Len(t, s.Refresh, 1)
+		assert.NotContains(t, s.Refresh, pair.RefreshToken)
+	})
+
+	t.Run("Positive test for tokens refresh - rotation and reuse detection", func(t *testing.T) {
+		first, err := svc.IssueTokens(ctx, "dimma3")
+		assert.NoError(t, err)
+		second, err := svc.RefreshTokens(ctx, first.RefreshToken)
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+		// повторное предъявление замененного токена отклоняется и отзывает все токены пользователя
+		_, err = svc.RefreshTokens(ctx, first.RefreshToken)
+		assert.Equal(t, domainerrors.ErrRefreshTokenNotFound, err)
+		_, err = svc.RefreshTokens(ctx, second.RefreshToken)
+		assert.Equal(t, domainerrors.ErrRefreshTokenNotFound, err)
+	})
+
+	t.Run("Negative test for tokens refresh - unknown token", func(t *testing.T) {
+		_, err := svc.RefreshTokens(ctx, "unknown")
+		assert.Equal(t, domainerrors.ErrRefreshTokenNotFound, err)
+	})
+
+	t.Run("Negative test for tokens issue - storage error", func(t *testing.T) {
+		_, err := svc.IssueTokens(ctx, "dimmaServErr")
+		assert.Equal(t, errors.New("something wrong with server"), err)
+	})
+
+	t.Run("Positive test for logout", func(t *testing.T) {
+		pair, err := svc.IssueTokens(ctx, "dimma4")
+		assert.NoError(t, err)
+		token, err := keys.Decode(pair.AccessToken)
+		assert.NoError(t, err)
+		err = svc.Logout(ctx, "dimma4", token.JwtID(), token.Expiration())
+		assert.NoError(t, err)
+		revoked, err := svc.IsRevoked(ctx, "dimma4", token.JwtID(), token.IssuedAt())
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		_, err = svc.RefreshTokens(ctx, pair.RefreshToken)
+		assert.Equal(t, domainerrors.ErrRefreshTokenNotFound, err)
+	})
+}
+
+func TestService_FailureDelay(t *testing.T) {
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	wrong := models.DecodeLoginPair{Login: "dimma3", Password: "wrong"}
+	right := models.DecodeLoginPair{Login: "dimma3", Password: "Secret123"}
+
+	t.Run("Positive test for failure delay - growing delay below threshold", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		var lockoutErr *models.LockoutError
+		for i := 1; i < settings.LoginMaxFailures; i++ {
+			// неудачная попытка до порога возвращает ошибку авторизации
+			_, err := svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+			assert.Equal(t, domainerrors.ErrInvalidCredentials, err)
+			// следующая попытка до истечения задержки отклоняется без проверки пароля
+			_, err = svc.CheckAuthorization(ctx, right, "192.0.2.2")
+			if assert.True(t, errors.As(err, &lockoutErr)) {
+				expected := settings.LoginFailureDelay << (i - 1)
+				if expected > settings.LoginLockout {
+					expected = settings.LoginLockout
+				}
+				assert.InDelta(t, expected.Seconds(), lockoutErr.RetryAfter.Seconds(), 1)
+			}
+			// задержка по логину и IP адресу истекла
+			for _, key := range []string{"login:dimma3", "ip:192.0.2.1"} {
+				a := s.Attempts[key]
+				a.LockedUntil = time.Time{}
+				s.Attempts[key] = a
+			}
+		}
+		// попытка, достигшая порога, блокирует вход
+		_, err := svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+		assert.True(t, errors.As(err, &lockoutErr))
+		assert.Equal(t, settings.LoginLockout, lockoutErr.RetryAfter)
+	})
+}
+
+// пороговая блокировка проверяется без задержек между попытками до порога
+func withoutFailureDelay(t *testing.T) {
+	d := settings.LoginFailureDelay
+	settings.LoginFailureDelay = 0
+	t.Cleanup(func() { settings.LoginFailureDelay = d })
+}
+
+func TestService_Lockout(t *testing.T) {
+	withoutFailureDelay(t)
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	wrong := models.DecodeLoginPair{Login: "dimma3", Password: "wrong"}
+	right := models.DecodeLoginPair{Login: "dimma3", Password: "Secret123"}
+
+	t.Run("Positive test for lockout - progressive lockout by login", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		// до порога неудачных попыток возвращается ошибка авторизации
+		for i := 1; i < settings.LoginMaxFailures; i++ {
+			_, err := svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+			assert.Equal(t, domainerrors.ErrInvalidCredentials, err)
+		}
+		// попытка, достигшая порога, блокирует вход
+		_, err := svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+		var lockoutErr *models.LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+		assert.Equal(t, settings.LoginLockout, lockoutErr.RetryAfter)
+		// во время блокировки верный пароль не проверяется
+		_, err = svc.CheckAuthorization(ctx, right, "192.0.2.2")
+		assert.True(t, errors.As(err, &lockoutErr))
+		assert.InDelta(t, settings.LoginLockout.Seconds(), lockoutErr.RetryAfter.Seconds(), 1)
+		// после окончания блокировки следующая неудачная попытка блокирует вход на удвоенное время
+		s.Attempts["login:dimma3"] = storagemock.LoginAttempts{Failures: s.Attempts["login:dimma3"].Failures}
+		_, err = svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+		assert.True(t, errors.As(err, &lockoutErr))
+		assert.Equal(t, 2*settings.LoginLockout, lockoutErr.RetryAfter)
+	})
+
+	t.Run("Positive test for lockout - successful login resets counter", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		for i := 1; i < settings.LoginMaxFailures; i++ {
+			svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+		}
+		_, err := svc.CheckAuthorization(ctx, right, "192.0.2.1")
+		assert.NoError(t, err)
+		assert.NotContains(t, s.Attempts, "login:dimma3")
+		_, err = svc.CheckAuthorization(ctx, wrong, "192.0.2.1")
+		assert.Equal(t, domainerrors.ErrInvalidCredentials, err)
+	})
+
+	t.Run("Positive test for lockout - lockout by IP for unknown logins", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		var err error
+		for i := 0; i < settings.LoginIPMaxFailures; i++ {
+			_, err = svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimma3", Password: "wrong"}, "192.0.2.9")
+			s.Attempts["login:dimma3"] = storagemock.LoginAttempts{}
+		}
+		var lockoutErr *models.LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+		// вход с другого адреса не заблокирован
+		_, err = svc.CheckAuthorization(ctx, right, "192.0.2.10")
+		assert.NoError(t, err)
+		_, err = svc.CheckAuthorization(ctx, right, "192.0.2.9")
+		assert.True(t, errors.As(err, &lockoutErr))
+	})
+
+	t.Run("Negative test for lockout - storage error", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		_, err := svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimmaServErr", Password: "12345"}, "192.0.2.1")
+		assert.Equal(t, errors.New("something wrong with server"), err)
+	})
+}
+
+func TestService_Password(t *testing.T) {
+	withoutFailureDelay(t)
+	// переопередяляем контекст с таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Negative test for password change - wrong old password", func(t *testing.T) {
+		svc := services.NewUserService(&storagemock.User{}, keys, &storagemock.Notifier{})
+		err := svc.ChangePassword(ctx, "dimma3", "wrong", "Secret456", "192.0.2.1")
+		assert.Equal(t, domainerrors.ErrWrongPassword, err)
+	})
+
+	t.Run("Negative test for password change - wrong old password locks login", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		for i := 1; i < settings.LoginMaxFailures; i++ {
+			err := svc.ChangePassword(ctx, "dimma3", "wrong", "Secret456", "192.0.2.1")
+			assert.Equal(t, domainerrors.ErrWrongPassword, err)
+		}
+		// неверные пароли при смене учитываются вместе с неудачными попытками входа
+		err := svc.ChangePassword(ctx, "dimma3", "wrong", "Secret456", "192.0.2.1")
+		var lockoutErr *models.LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+		err = svc.ChangePassword(ctx, "dimma3", "Secret123", "Secret456", "192.0.2.2")
+		assert.True(t, errors.As(err, &lockoutErr))
+		_, err = svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimma3", Password: "Secret123"}, "192.0.2.2")
+		assert.True(t, errors.As(err, &lockoutErr))
+	})
+
+	t.Run("Negative test for password change - weak new password", func(t *testing.T) {
+		svc := services.NewUserService(&storagemock.User{}, keys, &storagemock.Notifier{})
+		err := svc.ChangePassword(ctx, "dimma3", "Secret123", "short", "192.0.2.1")
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+	})
+
+	t.Run("Positive test for password change - sessions invalidated", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		pair, err := svc.IssueTokens(ctx, "dimma3")
+		assert.NoError(t, err)
+		token, err := keys.Decode(pair.AccessToken)
+		assert.NoError(t, err)
+		err = svc.ChangePassword(ctx, "dimma3", "Secret123", "Secret456", "192.0.2.1")
+		assert.NoError(t, err)
+		// токен доступа, выданный до смены пароля, отозван
+		revoked, err := svc.IsRevoked(ctx, "dimma3", token.JwtID(), issuedAtMicro(t, token))
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		// токен доступа, выданный после смены пароля, действует
+		newPair, err := svc.IssueTokens(ctx, "dimma3")
+		assert.NoError(t, err)
+		newToken, err := keys.Decode(newPair.AccessToken)
+		assert.NoError(t, err)
+		revoked, err = svc.IsRevoked(ctx, "dimma3", newToken.JwtID(), issuedAtMicro(t, newToken))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+		// токены обновления отозваны
+		_, err = svc.RefreshTokens(ctx, pair.RefreshToken)
+		assert.Equal(t, domainerrors.ErrRefreshTokenNotFound, err)
+		// вход выполняется с новым паролем
+		_, err = svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimma3", Password: "Secret456"}, "192.0.2.1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Positive test for password change - token issued earlier in the same second revoked", func(t *testing.T) {
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, &storagemock.Notifier{})
+		pair, err := svc.IssueTokens(ctx, "dimma3")
+		assert.NoError(t, err)
+		token, err := keys.Decode(pair.AccessToken)
+		assert.NoError(t, err)
+		issuedAt := issuedAtMicro(t, token)
+		// время выдачи в микросекундах совпадает со стандартным claim iat с точностью до секунды
+		assert.Equal(t, token.IssuedAt(), issuedAt.Truncate(time.Second))
+		// смена пароля через микросекунду после выдачи токена, в ту же секунду
+		s.ChangedAt = map[string]time.Time{"dimma3": issuedAt.Add(time.Microsecond)}
+		revoked, err := svc.IsRevoked(ctx, "dimma3", token.JwtID(), issuedAt)
+		assert.NoError(t, err)
+		assert.True(t, revoked)
+		// токен, выданный через микросекунду после смены, действует
+		revoked, err = svc.IsRevoked(ctx, "dimma3", token.JwtID(), issuedAt.Add(2*time.Microsecond))
+		assert.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("Positive test for password reset request - unknown login", func(t *testing.T) {
+		n := &storagemock.Notifier{}
+		svc := services.NewUserService(&storagemock.User{}, keys, n)
+		err := svc.RequestPasswordReset(ctx, "dimma4", "192.0.2.1")
+		assert.NoError(t, err)
+		assert.Empty(t, n.Tokens)
+	})
+
+	t.Run("Negative test for password reset request - rate limit by login", func(t *testing.T) {
+		n := &storagemock.Notifier{}
+		s := &storagemock.User{}
+		svc := services.NewUserService(s, keys, n)
+		for i := 0; i < settings.LoginMaxFailures; i++ {
+			err := svc.RequestPasswordReset(ctx, "dimma3", "192.0.2.1")
+			assert.NoError(t, err)
+		}
+		// после порога запросы сброса отклоняются, в том числе с другого адреса
+		err := svc.RequestPasswordReset(ctx, "dimma3", "192.0.2.2")
+		var lockoutErr *models.LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+		// запросы сброса не блокируют вход пользователя
+		_, err = svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimma3", Password: "Secret123"}, "192.0.2.1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Negative test for password reset request - rate limit for unknown login", func(t *testing.T) {
+		svc := services.NewUserService(&storagemock.User{}, keys, &storagemock.Notifier{})
+		for i := 0; i < settings.LoginMaxFailures; i++ {
+			svc.RequestPasswordReset(ctx, "dimma4", "192.0.2.1")
+		}
+		err := svc.RequestPasswordReset(ctx, "dimma4", "192.0.2.1")
+		var lockoutErr *models.LockoutError
+		assert.True(t, errors.As(err, &lockoutErr))
+	})
+
+	t.Run("Positive test for password reset - token is single use", func(t *testing.T) {
+		s := &storagemock.User{}
+		n := &storagemock.Notifier{}
+		svc := services.NewUserService(s, keys, n)
+		err := svc.RequestPasswordReset(ctx, " Dimma3 ", "192.0.2.1")
+		assert.NoError(t, err)
+		token := n.Tokens["dimma3"]
+		assert.NotEmpty(t, token)
+		// в хранилище сохраняется только хеш токена
+		assert.NotContains(t, s.Resets, token)
+		// слабый пароль не расходует токен
+		err = svc.ConfirmPasswordReset(ctx, token, "short")
+		var validationErr *models.ValidationError
+		assert.True(t, errors.As(err, &validationErr))
+		err = svc.ConfirmPasswordReset(ctx, token, "Secret456")
+		assert.NoError(t, err)
+		err = svc.ConfirmPasswordReset(ctx, token, "Secret789")
+		assert.Equal(t, domainerrors.ErrResetTokenNotFound, err)
+		_, err = svc.CheckAuthorization(ctx, models.DecodeLoginPair{Login: "dimma3", Password: "Secret456"}, "192.0.2.1")
+		assert.NoError(t, err)
+	})
+}
+
+// время выдачи токена доступа с точностью до микросекунды
+func issuedAtMicro(t *testing.T, token jwt.Token) time.Time {
+	v, ok := token.Get(models.ClaimIssuedAtMicro)
+	assert.True(t, ok)
+	us, _ := v.(float64)
+	return time.UnixMicro(int64(us)).UTC()
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// интерфейс методов хранилища для токенов обновления и отозванных токенов доступа
+type TokenStorageProvider interface {
+	RefreshTokenCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error)
+	RefreshTokenRotate(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (login string, err error)
+	RefreshTokensRevoke(ctx context.Context, login string) (err error)
+	AccessTokenRevoke(ctx context.Context, jti string, expiresAt time.Time) (err error)
+	AccessTokenRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error)
+	UserRole(ctx context.Context, login string) (role string, err error)
+}
+
+// интерфейс подписи токенов доступа
+type TokenEncoder interface {
+	Encode(claims map[string]interface{}) (t jwt.Token, tokenString string, err error)
+}
+
+// выдача пары токенов доступа и обновления пользователю
+func (svc *UserService) IssueTokens(ctx context.Context, login string) (pair models.TokenPair, err error) {
+	// создаем токен обновления, в хранилище сохраняется только его хеш
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		log.Printf("refresh token generation in ServiceIssueTokens error :%s", err)
+		return pair, err
+	}
+	err = svc.storage.RefreshTokenCreate(ctx, login, tokenHash(refreshToken), time.Now().Add(settings.RefreshTokenTTL))
+	if err != nil {
+		return pair, err
+	}
+	return svc.tokenPair(ctx, login, refreshToken)
+}
+
+// обмен токена обновления на новую пару токенов, предъявленный токен обновления становится недействительным
+func (svc *UserService) RefreshTokens(ctx context.Context, refreshToken string) (pair models.TokenPair, err error) {
+	// создаем новый токен обновления
+	newToken, err := randomToken(32)
+	if err != nil {
+		log.Printf("refresh token generation in ServiceRefreshTokens error :%s", err)
+		return pair, err
+	}
+	// заменяем токен обновления в хранилище и получаем логин владельца
+	login, err := svc.storage.RefreshTokenRotate(ctx, tokenHash(refreshToken), tokenHash(newToken), time.Now().Add(settings.RefreshTokenTTL))
+	if err != nil {
+		return pair, err
+	}
+	return svc.tokenPair(ctx, login, newToken)
+}
+
+// выход пользователя: отзыв всех токенов обновления и текущего токена доступа
+func (svc *UserService) Logout(ctx context.Context, login string, jti string, expiresAt time.Time) (err error) {
+	err = svc.storage.RefreshTokensRevoke(ctx, login)
+	if err != nil {
+		return err
+	}
+	// токены без идентификатора не могут быть отозваны и действуют до истечения срока
+	if jti == "" {
+		return nil
+	}
+	return svc.storage.AccessTokenRevoke(ctx, jti, expiresAt)
+}
+
+// проверка, отозван ли токен доступа с идентификатором jti или выдан до последней смены пароля пользователя
+func (svc *UserService) IsRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error) {
+	return svc.storage.AccessTokenRevoked(ctx, login, jti, issuedAt)
+}
+
+// создание токена доступа и формирование пары токенов
+// роль пользователя читается из хранилища, поэтому смена роли вступает в силу при следующем обновлении токенов
+func (svc *UserService) tokenPair(ctx context.Context, login string, refreshToken string) (pair models.TokenPair, err error) {
+	role, err := svc.storage.UserRole(ctx, login)
+	if err != nil {
+		return pair, err
+	}
+	// уникальный идентификатор токена доступа для списка отозванных токенов
+	jti, err := randomToken(16)
+	if err != nil {
+		log.Printf("jti generation in ServiceTokenPair error :%s", err)
+		return pair, err
+	}
+	// создаем токен
+	claims := map[string]interface{}{"login": login, "role": role, "jti": jti}
+	// устанавливаем время выдачи и время жизни токена
+	issuedAt := time.Now()
+	claims[jwt.IssuedAtKey] = issuedAt.Unix()
+	claims[models.ClaimIssuedAtMicro] = issuedAt.UnixMicro()
+	jwtauth.SetExpiryIn(claims, settings.TokenTTL)
+	_, accessToken, err := svc.tokenAuth.Encode(claims)
+	if err != nil {
+		log.Printf("tokenAuth.Encode error ServiceTokenPair: %s", err)
+		return pair, err
+	}
+	pair = models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(settings.TokenTTL.Seconds()),
+	}
+	return pair, nil
+}
+
+// создание случайной строки из n байт в кодировке base64url
+func randomToken(n int) (token string, err error) {
+	b := make([]byte, n)
+	if _, err = rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// хеш SHA-256 токена обновления для хранения
+func tokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -5,7 +5,10 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"strings"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 
 	"github.com/rs/zerolog/log"
@@ -14,46 +17,123 @@ import (
 // интерфейс методов хранилища для User
 type UserStorageProvider interface {
 	Create(ctx context.Context, login string, passwH string) (err error)
-	CheckAuthorization(ctx context.Context, login string, passwHex string) (err error)
+	PasswordHash(ctx context.Context, login string) (passwHash string, err error)
+	UpdatePasswordHash(ctx context.Context, login string, passwHash string) (err error)
+	TokenStorageProvider
+	LoginAttemptStorageProvider
+	PasswordStorageProvider
 }
 
 // структура конструктора бизнес логики User
 type UserService struct {
-	storage UserStorageProvider
+	storage   UserStorageProvider
+	tokenAuth TokenEncoder
+	notifier  Notifier
 }
 
 // конструктор бизнес логики User
-func NewUserService(uStorage UserStorageProvider) *UserService {
+func NewUserService(uStorage UserStorageProvider, tokenAuth TokenEncoder, notifier Notifier) *UserService {
 	return &UserService{
 		uStorage,
+		tokenAuth,
+		notifier,
 	}
 }
 
-func (svc *UserService) Create(ctx context.Context, dc models.DecodeLoginPair) (err error) {
-	// сощдание хеш пароля для передачи в хранилище
-	passwHex, err := ToHex(dc.Password)
+// регистрация пользователя, возвращает логин в каноническом виде
+func (svc *UserService) Create(ctx context.Context, dc models.DecodeLoginPair) (login string, err error) {
+	// приводим логин к каноническому виду и проверяем пару логин:пароль
+	login = NormalizeLogin(dc.Login)
+	if err = ValidateLoginPair(login, dc.Password); err != nil {
+		log.Printf("ServiceCreateNewUser validation: %s", err)
+		return "", err
+	}
+	// создание хеш пароля argon2id для передачи в хранилище
+	passwHash, err := HashPassword(dc.Password)
 	if err != nil {
-		log.Printf("hex conversion in ServiceCreateNewUser error :%s", err)
-		return err
+		log.Printf("password hashing in ServiceCreateNewUser error :%s", err)
+		return "", err
 	}
 	// передача пары логин:пароль в хранилище
-	err = svc.storage.Create(ctx, dc.Login, passwHex)
-	return err
+	err = svc.storage.Create(ctx, login, passwHash)
+	if err != nil {
+		return "", err
+	}
+	return login, nil
 }
 
-func (svc *UserService) CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair) (err error) {
-	// создание хеш пароля для передачи в хранилище
-	passwHex, err := ToHex(dc.Password)
+// аутентификация пользователя, возвращает логин в том виде, в котором он сохранен в хранилище
+func (svc *UserService) CheckAuthorization(ctx context.Context, dc models.DecodeLoginPair, ip string) (login string, err error) {
+	login = NormalizeLogin(dc.Login)
+	// проверка временной блокировки входа по логину и IP адресу
+	if err = svc.checkLockout(ctx, login, ip); err != nil {
+		return "", err
+	}
+	// получение хеша пароля из хранилища
+	passwHash, login, err := svc.passwordHash(ctx, login, dc.Login)
+	if errors.Is(err, domainerrors.ErrInvalidCredentials) {
+		return "", svc.authorizationFailed(ctx, login, ip, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	// проверка пароля
+	ok, rehash, err := VerifyPassword(dc.Password, passwHash)
 	if err != nil {
-		log.Printf("hex conversion in ServiceCreateNewUser error :%s", err)
+		log.Printf("password verification in ServiceCheckAuthorization error :%s", err)
+		return "", err
+	}
+	if !ok {
+		err = domainerrors.ErrInvalidCredentials
+		log.Printf("ServiceCheckAuthorization for login %s: %s", login, err)
+		return "", svc.authorizationFailed(ctx, NormalizeLogin(login), ip, err)
+	}
+	// сбрасываем счетчик неудачных попыток по логину, счетчик по IP адресу сбрасывается по истечении времени
+	if err := svc.storage.LoginAttemptsReset(ctx, "login:"+NormalizeLogin(login)); err != nil {
+		log.Printf("login attempts reset in ServiceCheckAuthorization error :%s", err)
+	}
+	// пересчитываем устаревший хеш, ошибка пересчета не влияет на авторизацию
+	if rehash {
+		if passwHash, err := HashPassword(dc.Password); err != nil {
+			log.Printf("password rehashing in ServiceCheckAuthorization error :%s", err)
+		} else if err := svc.storage.UpdatePasswordHash(ctx, login, passwHash); err != nil {
+			log.Printf("password hash update in ServiceCheckAuthorization error :%s", err)
+		} else {
+			log.Printf("password hash upgraded for login %s", login)
+		}
+	}
+	return login, nil
+}
+
+// получение хеша пароля по логину в каноническом виде
+// логины, зарегистрированные до приведения к нижнему регистру, ищутся в исходном написании
+func (svc *UserService) passwordHash(ctx context.Context, login string, rawLogin string) (passwHash string, storedLogin string, err error) {
+	passwHash, err = svc.storage.PasswordHash(ctx, login)
+	legacy := strings.TrimSpace(rawLogin)
+	if errors.Is(err, domainerrors.ErrInvalidCredentials) && legacy != login {
+		passwHash, err = svc.storage.PasswordHash(ctx, legacy)
+		if err == nil {
+			return passwHash, legacy, nil
+		}
+	}
+	return passwHash, login, err
+}
+
+// учет неудачной попытки входа, возвращает LockoutError, если попытка привела к блокировке, иначе исходную ошибку
+func (svc *UserService) authorizationFailed(ctx context.Context, login string, ip string, authErr error) (err error) {
+	if err = svc.registerFailure(ctx, login, ip); err != nil {
+		var lockoutErr *models.LockoutError
+		if !errors.As(err, &lockoutErr) {
+			log.Printf("login attempt registration in ServiceCheckAuthorization error :%s", err)
+			return authErr
+		}
 		return err
 	}
-	// передача пары логин:пароль в хранилище
-	err = svc.storage.CheckAuthorization(ctx, dc.Login, passwHex)
-	return err
+	return authErr
 }
 
 // функция SHA.256 хеширования строки и кодирования хеша в строку
+// используется только для проверки устаревших хешей паролей
 func ToHex(src string) (dst string, err error) {
 	h := sha256.New()
 	h.Write([]byte(src))
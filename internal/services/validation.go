@@ -0,0 +1,118 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+)
+
+// NormalizeLogin приводит логин к каноническому виду: без пробелов по краям и в нижнем регистре,
+// чтобы логины "Bob" и "bob" считались одним логином
+func NormalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
+
+// ValidateLoginPair проверяет логин в каноническом виде и пароль на соответствие правилам
+// возвращает *models.ValidationError со списком всех нарушенных правил
+func ValidateLoginPair(login string, password string) (err error) {
+	violations := append(loginViolations(login), passwordViolations(login, password)...)
+	if len(violations) > 0 {
+		return &models.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// ValidatePassword проверяет новый пароль пользователя login на соответствие правилам
+func ValidatePassword(login string, password string) (err error) {
+	violations := passwordViolations(NormalizeLogin(login), password)
+	if len(violations) > 0 {
+		return &models.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// нарушенные правила для логина
+func loginViolations(login string) (violations []models.Violation) {
+	add := func(field string, rule string, message string) {
+		violations = append(violations, models.Violation{Field: field, Rule: rule, Message: message})
+	}
+	switch n := utf8.RuneCountInString(login); {
+	case n == 0:
+		add("login", "required", "login is required")
+	case n < settings.LoginMinLength || n > settings.LoginMaxLength:
+		add("login", "length", fmt.Sprintf("login length must be from %d to %d characters", settings.LoginMinLength, settings.LoginMaxLength))
+	}
+	if strings.IndexFunc(login, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-')
+	}) >= 0 {
+		add("login", "charset", "login may contain only latin letters, digits, '.', '_' and '-'")
+	}
+	if login != "" && strings.ContainsRune("._-", rune(login[0])) {
+		add("login", "start", "login must start with a letter or a digit")
+	}
+	return violations
+}
+
+// нарушенные правила для пароля
+func passwordViolations(login string, password string) (violations []models.Violation) {
+	add := func(field string, rule string, message string) {
+		violations = append(violations, models.Violation{Field: field, Rule: rule, Message: message})
+	}
+	switch n := utf8.RuneCountInString(password); {
+	case n == 0:
+		add("password", "required", "password is required")
+	case n < settings.PasswordMinLength:
+		add("password", "length", fmt.Sprintf("password must be at least %d characters", settings.PasswordMinLength))
+	case n > settings.PasswordMaxLength:
+		add("password", "length", fmt.Sprintf("password must be at most %d characters", settings.PasswordMaxLength))
+	}
+	if password != "" {
+		if strings.IndexFunc(password, unicode.IsLetter) < 0 {
+			add("password", "letter", "password must contain a letter")
+		}
+		if strings.IndexFunc(password, unicode.IsDigit) < 0 {
+			add("password", "digit", "password must contain a digit")
+		}
+		if login != "" && strings.Contains(strings.ToLower(password), login) {
+			add("password", "login", "password must not contain the login")
+		}
+	}
+	return violations
+}
+
+// ValidateAdjustment проверяет запрос ручной корректировки баланса и возвращает все нарушенные правила
+func ValidateAdjustment(dc models.NewAdjustment) error {
+	var violations []models.Violation
+	if dc.Operation != models.AdjustmentCredit && dc.Operation != models.AdjustmentDebit {
+		violations = append(violations, models.Violation{Field: "operation", Rule: "oneof",
+			Message: "operation must be one of: " + models.AdjustmentCredit + ", " + models.AdjustmentDebit})
+	}
+	switch {
+	case !dc.Amount.IsPositive():
+		violations = append(violations, models.Violation{Field: "amount", Rule: "positive",
+			Message: "amount must be greater than zero"})
+	case !dc.Amount.Equal(dc.Amount.Truncate(2)):
+		violations = append(violations, models.Violation{Field: "amount", Rule: "precision",
+			Message: "amount must have at most 2 decimal places"})
+	}
+	if !models.Contains(models.AdjustmentReasons, dc.Reason) {
+		violations = append(violations, models.Violation{Field: "reason", Rule: "oneof",
+			Message: "reason must be one of: " + strings.Join(models.AdjustmentReasons, ", ")})
+	}
+	switch {
+	case dc.Comment == "":
+		violations = append(violations, models.Violation{Field: "comment", Rule: "required",
+			Message: "comment is required"})
+	case utf8.RuneCountInString(dc.Comment) > settings.AdjustmentCommentMaxLength:
+		violations = append(violations, models.Violation{Field: "comment", Rule: "length",
+			Message: fmt.Sprintf("comment must be at most %d characters", settings.AdjustmentCommentMaxLength)})
+	}
+	if len(violations) > 0 {
+		return &models.ValidationError{Violations: violations}
+	}
+	return nil
+}
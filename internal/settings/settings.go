@@ -3,14 +3,8 @@ package settings
 
 import (
 	"time"
-
-	"github.com/go-chi/jwtauth/v5"
-	"github.com/lestrrat-go/jwx/v2/jwa"
 )
 
-// ключ подписи
-const SignKey string = "9e9e0b4e6de418b2f84fca35165571c5"
-
 // timeout контекста
 const StorageTimeout = 600 * time.Second
 
@@ -31,12 +25,82 @@ const (
 	DefCalcSysURL = "http://localhost:8080"
 )
 
-// переменная ключа токена
-var TokenAuth *jwtauth.JWTAuth = jwtauth.New(string(jwa.HS256), []byte(SignKey), nil)
+// путь к файлу набора ключей подписи токенов в формате JWK Set или PEM
+var JWTKeysFile string
+
+// идентификатор (kid) активного ключа подписи токенов, по умолчанию первый ключ набора
+var JWTActiveKID string
+
+// секретный ключ подписи токенов HS256, если файл набора ключей не задан
+var JWTSecret string
+
+// разрешить запуск без заданных ключей подписи со случайным ключом, только для разработки:
+// токены перестают действовать после перезапуска, каждый экземпляр сервиса подписывает своим ключом
+var JWTDevRandomKey bool = false
+
+// логины пользователей, которым при запуске приложения назначается роль admin
+var AdminLogins []string
+
+// максимальная длина комментария к ручной корректировке баланса
+var AdjustmentCommentMaxLength int = 500
+
+// максимальный размер страницы списков заказов и списаний
+var ListMaxLimit int = 1000
 
 // время жизни токена
 var TokenTTL = 30 * time.Minute
 
+// время жизни токена обновления
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// время жизни токена сброса пароля
+var PasswordResetTTL = 1 * time.Hour
+
+// допустимая длина логина
+const (
+	LoginMinLength = 3
+	LoginMaxLength = 64
+)
+
+// минимальная длина пароля
+var PasswordMinLength int = 8
+
+// максимальная длина пароля, ограничивает время хеширования
+var PasswordMaxLength int = 128
+
+// количество неудачных попыток входа подряд по логину до временной блокировки, 0 - без блокировки
+var LoginMaxFailures int = 5
+
+// количество неудачных попыток входа подряд с одного IP адреса до временной блокировки, 0 - без блокировки
+var LoginIPMaxFailures int = 20
+
+// задержка следующей попытки входа после первой неудачной попытки до порога блокировки,
+// удваивается с каждой следующей неудачной попыткой, но не превышает LoginLockout, 0 - без задержки
+var LoginFailureDelay = 1 * time.Second
+
+// время первой блокировки входа, удваивается с каждой следующей неудачной попыткой
+var LoginLockout = 1 * time.Minute
+
+// максимальное время блокировки входа
+var LoginLockoutMax = 1 * time.Hour
+
+// время без неудачных попыток входа, после которого счетчик попыток сбрасывается
+var LoginFailureWindow = 15 * time.Minute
+
+// определять IP адрес клиента по заголовкам X-Forwarded-For и X-Real-IP, если сервис работает за прокси
+var TrustProxyHeaders bool = false
+
+// время хранения результата запроса с заголовком Idempotency-Key для повтора ответа
+var IdempotencyWindow = 24 * time.Hour
+
+// время резервирования ключа Idempotency-Key до сохранения результата запроса,
+// по истечении незавершенное резервирование освобождается для повторного выполнения;
+// не меньше IdempotencyLeaseMin, чтобы резервирование не истекло, пока выполняется исходный запрос
+var IdempotencyLease = IdempotencyLeaseMin
+
+// минимальное время резервирования ключа Idempotency-Key: таймаут обработчика запроса с запасом на сохранение результата
+const IdempotencyLeaseMin = StorageTimeout + 1*time.Minute
+
 // начальный таймаут для горутины запросов к сервису расчета баллов
 var RequestsTimeout = 800 * time.Millisecond
 
@@ -45,3 +109,57 @@ var WorkersQty int = 3
 
 // буффер канала task для воркеров
 var PipelineLenght int = 10
+
+// время аренды задачи воркером, по истечении задача снова доступна в очереди
+var TaskLease = 60 * time.Second
+
+// таймаут http клиента внешнего сервиса начисления баллов
+var AccrualClientTimeout = 5 * time.Second
+
+// количество ошибок запросов к внешнему сервису подряд для размыкания автоматического выключателя
+var BreakerFailureThreshold int = 5
+
+// время, на которое размыкается автоматический выключатель перед пробным запросом
+var BreakerCoolDown = 30 * time.Second
+
+// ограничение частоты запросов всех воркеров к внешнему сервису начисления баллов в секунду, 0 - без ограничения
+var AccrualRPS float64 = 0
+
+// допустимое количество запросов к внешнему сервису начисления баллов подряд без ожидания
+var AccrualBurst int = 1
+
+// максимальный размер сохраняемого для диагностики ответа внешнего сервиса начисления баллов
+var PollResponseMaxBytes int64 = 4096
+
+// начальная задержка повторного опроса внешнего сервиса по заказу
+var RetryInitialDelay = 1 * time.Second
+
+// множитель задержки для каждой следующей попытки
+var RetryMultiplier float64 = 2
+
+// максимальная задержка между попытками
+var RetryMaxDelay = 5 * time.Minute
+
+// доля случайного отклонения задержки от расчетной, от 0 до 1
+var RetryJitter float64 = 0.2
+
+// максимальное количество попыток опроса по заказу, 0 - без ограничения
+var RetryMaxAttempts int = 100
+
+// максимальное время обработки задачи по заказу, 0 - без ограничения
+var RetryMaxAge = 72 * time.Hour
+
+// интервал опроса очереди задач в хранилище, если очередь пустая
+var QueuePollInterval = 1 * time.Second
+
+// размер пачки заказов при восстановлении очереди задач на старте
+var RecoveryBatchSize int = 100
+
+// максимальный возраст заказа для восстановления очереди задач, 0 - без ограничения
+var RecoveryMaxAge = 30 * 24 * time.Hour
+
+// режим восстановления очереди задач только с логированием, без постановки в очередь
+var RecoveryDryRun bool = false
+
+// таймаут исправления баланса одного пользователя утилитой сверки балансов
+var ReconcileFixTimeout = 30 * time.Second
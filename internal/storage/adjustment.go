@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// сервис ручной корректировки баланса пользователя администратором
+// выполняется в одной транзакции с блокировкой строки баланса, как и списание, возвращает баланс после корректировки
+func (ms *StorageSQL) NewAdjustment(ctx context.Context, login string, dc models.NewAdjustment) (ec models.LoginBalance, err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StorageNewAdjustment tx.Begin : %s", err)
+		return ec, err
+	}
+	defer tx.Rollback()
+	// получаем текущий баланс с блокировкой строки
+	ec, err = lockBalance(ctx, tx, login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ec, domainerrors.ErrLoginNotFound
+	}
+	if err != nil {
+		return ec, err
+	}
+	// запись журнала операций: зачисление со счета корректировок или списание на счет корректировок
+	e := models.LedgerEntry{
+		IdempotencyKey: fmt.Sprintf("adjustment:%s:%d", login, time.Now().UnixNano()),
+		Login:          login,
+		EntryType:      models.EntryManualAdjustment,
+		DebitAccount:   models.AccountAdjustments,
+		CreditAccount:  UserAccount(login),
+		Amount:         dc.Amount,
+	}
+	delta := dc.Amount
+	if dc.Operation == models.AdjustmentDebit {
+		// проверяем наличие сресдтв для списания, если недостаточно, возвращаем ошибку "insufficient funds"
+		if dc.Amount.GreaterThan(ec.Current) {
+			err = domainerrors.ErrInsufficientFunds
+			log.Printf("error StorageNewAdjustment : %s", err)
+			return ec, err
+		}
+		delta = dc.Amount.Neg()
+		e.DebitAccount, e.CreditAccount = e.CreditAccount, e.DebitAccount
+	}
+	// изменяем баланс, корректировка не учитывается в сумме списаний
+	q := `UPDATE balance SET current_balance = current_balance + $2 WHERE login = $1`
+	_, err = tx.ExecContext(ctx, q, login, delta)
+	if err != nil {
+		log.Printf("update SQL request StorageNewAdjustment error: %s", err)
+		return ec, err
+	}
+	// добавляем запись в журнал операций
+	if _, err = ms.appendLedgerEntry(ctx, tx, e); err != nil {
+		return ec, err
+	}
+	// сохраняем причину, комментарий и автора корректировки
+	q = `INSERT INTO balance_adjustments (idempotency_key, reason, comment, created_by) VALUES ($1, $2, $3, $4)`
+	_, err = tx.ExecContext(ctx, q, e.IdempotencyKey, dc.Reason, dc.Comment, dc.CreatedBy)
+	if err != nil {
+		log.Printf("insert SQL request StorageNewAdjustment error: %s", err)
+		return ec, err
+	}
+	// сохраняем изменения
+	if err = tx.Commit(); err != nil {
+		log.Printf("error StorageNewAdjustment tx.Commit : %s", err)
+		return ec, err
+	}
+	log.Printf("balance of login %s adjusted by %s by %s, reason %s", login, delta, dc.CreatedBy, dc.Reason)
+	ec.Current = ec.Current.Add(delta)
+	return ec, nil
+}
+
+// сервис получения истории операций пользователя по журналу операций, включая ручные корректировки
+func (ms *StorageSQL) History(ctx context.Context, login string) (ec []models.LedgerEntry, err error) {
+	// создаем текст запроса
+	q := `SELECT l.entry_type, l.debit_account, l.credit_account, l.amount, l.create_time,
+	COALESCE(a.reason, ''), COALESCE(a.comment, ''), COALESCE(a.created_by, '')
+	FROM ledger_entries l
+	LEFT JOIN balance_adjustments a ON a.idempotency_key = l.idempotency_key
+	WHERE l.login = $1
+	ORDER BY l.id`
+	// делаем запрос в SQL, получаем строки и пишем результат запроса в пременные
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q, login)
+	if err != nil {
+		log.Printf("select StorageHistory SQL reqest error : %s", err)
+		return ec, err
+	}
+	defer rows.Close()
+	s := models.LedgerEntry{Login: login}
+	// пишем результат запроса (итерирование по полученному набору строк) в структуру
+	for rows.Next() {
+		err = rows.Scan(&s.EntryType, &s.DebitAccount, &s.CreditAccount, &s.Amount, &s.CreatedAt, &s.Reason, &s.Comment, &s.CreatedBy)
+		if err != nil {
+			log.Printf("row by row scan StorageHistory error : %s", err)
+			return ec, err
+		}
+		ec = append(ec, s)
+	}
+	// проверяем итерации на ошибки
+	err = rows.Err()
+	if err != nil {
+		log.Printf("request StorageHistory iteration scan error: %s", err)
+		return ec, err
+	}
+	// проверяем наличие записей
+	if len(ec) == 0 {
+		err = domainerrors.ErrNoRecords
+		log.Printf("request StorageHistory len == 0: %s", err)
+	}
+	return ec, err
+}
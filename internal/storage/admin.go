@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// получение карточки пользователя с балансом и количеством заказов
+func (ms *StorageSQL) AdminUser(ctx context.Context, login string) (ec models.AdminUser, err error) {
+	// создаем текст запроса
+	q := `SELECT u.login, u.role, u.password_changed_at,
+	COALESCE(b.current_balance, 0), COALESCE(b.total_withdrawn, 0),
+	(SELECT count(*) FROM orders o WHERE o.login = u.login)
+	FROM users u
+	LEFT JOIN balance b ON b.login = u.login
+	WHERE u.login = $1`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременные
+	var changedAt sql.NullTime
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, login).Scan(&ec.Login, &ec.Role, &changedAt,
+		&ec.Balance.Current, &ec.Balance.Withdrawn, &ec.Orders)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrLoginNotFound
+		log.Printf("select StorageAdminUser SQL: %s", err)
+		return ec, err
+	}
+	if err != nil {
+		log.Printf("select StorageAdminUser SQL request scan error: %s", err)
+		return ec, err
+	}
+	if changedAt.Valid {
+		ec.PasswordChangedAt = &changedAt.Time
+	}
+	return ec, err
+}
+
+// получение заказа по номеру с логином владельца
+func (ms *StorageSQL) AdminOrder(ctx context.Context, orderNum string) (ec models.AdminOrder, err error) {
+	// создаем текст запроса
+	q := `SELECT login, order_num, status, accrual, change_time FROM orders WHERE order_num = $1`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременные
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, orderNum).Scan(&ec.Login, &ec.Number, &ec.Status, &ec.Accrual, &ec.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrOrderNotFound
+		log.Printf("select StorageAdminOrder SQL: %s", err)
+		return ec, err
+	}
+	if err != nil {
+		log.Printf("select StorageAdminOrder SQL request scan error: %s", err)
+	}
+	return ec, err
+}
+
+// диагностика обработки заказа: история статусов, последний опрос внешнего сервиса и состояние задачи в очереди
+func (ms *StorageSQL) OrderDiagnostics(ctx context.Context, orderNum string) (ec models.OrderDiagnostics, err error) {
+	ec.AdminOrder, err = ms.AdminOrder(ctx, orderNum)
+	if err != nil {
+		return ec, err
+	}
+	ec.History, err = ms.statusHistory(ctx, orderNum)
+	if err != nil {
+		return ec, err
+	}
+	// создаем текст запроса последнего опроса внешнего сервиса
+	q := `SELECT attempt, status_code, response, error, poll_time FROM accrual_polls WHERE order_num = $1`
+	poll := models.AccrualPoll{OrderNum: orderNum}
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, orderNum).Scan(&poll.Attempt, &poll.StatusCode, &poll.Response, &poll.Error, &poll.PolledAt)
+	switch {
+	case err == nil:
+		ec.LastPoll = &poll
+		ec.Attempts = poll.Attempt
+	case !errors.Is(err, sql.ErrNoRows):
+		log.Printf("select StorageOrderDiagnostics SQL request scan error: %s", err)
+		return ec, err
+	}
+	// создаем текст запроса задачи в очереди, время следующей попытки - окончание аренды задачи
+	q = `SELECT registered, attempts, locked_until, exhausted FROM tasks WHERE order_num = $1`
+	var next time.Time
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, orderNum).Scan(&ec.Registered, &ec.Attempts, &next, &ec.Exhausted)
+	switch {
+	// бюджет попыток исчерпан, опрос возобновляется только повторным опросом администратором
+	case err == nil && ec.Exhausted:
+	case err == nil:
+		ec.Queued = true
+		ec.NextAttempt = &next
+	case errors.Is(err, sql.ErrNoRows):
+		err = nil
+	default:
+		log.Printf("select StorageOrderDiagnostics SQL request scan error: %s", err)
+	}
+	return ec, err
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// получение наибольшего времени блокировки входа по ключам попыток ("login:<логин>", "ip:<адрес>")
+// если ни один ключ не заблокирован, возвращается нулевое время
+func (ms *StorageSQL) LoginAttemptsLocked(ctx context.Context, keys []string) (lockedUntil time.Time, err error) {
+	// создаем список параметров запроса по количеству ключей
+	params := make([]string, len(keys))
+	args := make([]interface{}, len(keys))
+	for i, key := range keys {
+		params[i] = "$" + strconv.Itoa(i+1)
+		args[i] = key
+	}
+	// создаем текст запроса
+	q := `SELECT COALESCE(MAX(locked_until), 'epoch'::timestamptz) FROM login_attempts
+	WHERE attempt_key IN (` + strings.Join(params, ", ") + `) AND locked_until > CURRENT_TIMESTAMP`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, args...).Scan(&lockedUntil)
+	if err != nil {
+		log.Printf("select StorageLoginAttemptsLocked SQL request scan error: %s", err)
+		return time.Time{}, err
+	}
+	if !lockedUntil.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return lockedUntil, nil
+}
+
+// учет неудачной попытки входа по ключу, возвращает количество неудачных попыток подряд
+// счетчик начинается заново, если с последней неудачной попытки и окончания блокировки прошло больше window
+func (ms *StorageSQL) LoginAttemptFailed(ctx context.Context, key string, window time.Duration) (failures int, err error) {
+	// создаем текст запроса
+	q := `INSERT INTO login_attempts (attempt_key, failures) VALUES ($1, 1)
+	ON CONFLICT (attempt_key) DO UPDATE SET
+	 failures = CASE
+	  WHEN GREATEST(login_attempts.last_failure, login_attempts.locked_until) < CURRENT_TIMESTAMP - $2 * interval '1 millisecond' THEN 1
+	  ELSE login_attempts.failures + 1 END,
+	 last_failure = CURRENT_TIMESTAMP
+	RETURNING failures`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, key, window.Milliseconds()).Scan(&failures)
+	if err != nil {
+		log.Printf("upsert StorageLoginAttemptFailed SQL request scan error: %s", err)
+	}
+	return failures, err
+}
+
+// блокировка входа по ключу до времени until, более поздняя блокировка не сокращается
+func (ms *StorageSQL) LoginAttemptLock(ctx context.Context, key string, until time.Time) (err error) {
+	// создаем текст запроса
+	q := `UPDATE login_attempts SET locked_until = GREATEST(locked_until, $2) WHERE attempt_key = $1`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, key, until)
+	if err != nil {
+		log.Printf("update StorageLoginAttemptLock SQL request error: %s", err)
+	}
+	return err
+}
+
+// сброс счетчика неудачных попыток входа по ключу после успешного входа
+func (ms *StorageSQL) LoginAttemptsReset(ctx context.Context, key string) (err error) {
+	// создаем текст запроса
+	q := `DELETE FROM login_attempts WHERE attempt_key = $1`
+	// удаляем из хранилища
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, key)
+	if err != nil {
+		log.Printf("delete StorageLoginAttemptsReset SQL request error: %s", err)
+	}
+	return err
+}
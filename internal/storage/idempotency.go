@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// резервирование ключа идемпотентности для логина, запись с истекшим окном хранения заменяется новой,
+// как и резервирование без сохраненного результата старше lease (запрос прерван остановкой сервиса)
+// если ключ уже зарезервирован, возвращается сохраненная запись и reserved == false
+func (ms *StorageSQL) IdempotencyReserve(ctx context.Context, login string, key string, rec models.IdempotencyRecord, window time.Duration, lease time.Duration) (ec models.IdempotencyRecord, reserved bool, err error) {
+	// удаляем запись с истекшим окном хранения или с истекшим резервированием
+	q := `DELETE FROM idempotency_keys WHERE login = $1 AND idem_key = $2 AND (create_time < CURRENT_TIMESTAMP - $3 * interval '1 millisecond' OR (status_code = 0 AND create_time < CURRENT_TIMESTAMP - $4 * interval '1 millisecond'))`
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, login, key, window.Milliseconds(), lease.Milliseconds())
+	if err != nil {
+		log.Printf("delete StorageIdempotencyReserve SQL request error: %s", err)
+		return ec, false, err
+	}
+	// резервируем ключ
+	q = `INSERT INTO idempotency_keys (login, idem_key, endpoint, request_hash) VALUES ($1, $2, $3, $4) ON CONFLICT (login, idem_key) DO NOTHING`
+	res, err := ms.PostgreSQL.ExecContext(ctx, q, login, key, rec.Endpoint, rec.RequestHash)
+	if err != nil {
+		log.Printf("insert StorageIdempotencyReserve SQL request error: %s", err)
+		return ec, false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("rows affected StorageIdempotencyReserve error: %s", err)
+		return ec, false, err
+	}
+	if n > 0 {
+		return rec, true, err
+	}
+	// ключ уже зарезервирован, получаем сохраненную запись
+	q = `SELECT endpoint, request_hash, status_code, content_type, COALESCE(body, '') FROM idempotency_keys WHERE login = $1 AND idem_key = $2`
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, login, key).Scan(&ec.Endpoint, &ec.RequestHash, &ec.StatusCode, &ec.ContentType, &ec.Body)
+	if err != nil {
+		log.Printf("select StorageIdempotencyReserve SQL request scan error: %s", err)
+	}
+	return ec, false, err
+}
+
+// сохранение результата запроса для зарезервированного ключа идемпотентности
+func (ms *StorageSQL) IdempotencySave(ctx context.Context, login string, key string, rec models.IdempotencyRecord) (err error) {
+	// создаем текст запроса
+	q := `UPDATE idempotency_keys SET status_code = $3, content_type = $4, body = $5 WHERE login = $1 AND idem_key = $2`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, login, key, rec.StatusCode, rec.ContentType, rec.Body)
+	if err != nil {
+		log.Printf("update StorageIdempotencySave SQL request error: %s", err)
+	}
+	return err
+}
+
+// освобождение ключа идемпотентности, чтобы запрос с этим ключом можно было выполнить повторно
+func (ms *StorageSQL) IdempotencyRelease(ctx context.Context, login string, key string) (err error) {
+	// создаем текст запроса
+	q := `DELETE FROM idempotency_keys WHERE login = $1 AND idem_key = $2`
+	// удаляем из хранилища
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, login, key)
+	if err != nil {
+		log.Printf("delete StorageIdempotencyRelease SQL request error: %s", err)
+	}
+	return err
+}
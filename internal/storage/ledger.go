@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// UserAccount возвращает счет пользователя в журнале операций
+func UserAccount(login string) string {
+	return "user:" + login
+}
+
+// запрос расчета баланса и суммы списаний пользователя по журналу операций
+const qLedgerBalance = `SELECT
+	COALESCE(SUM(CASE WHEN credit_account = $2 THEN amount WHEN debit_account = $2 THEN -amount END), 0),
+	COALESCE(SUM(CASE WHEN entry_type = 'WITHDRAWAL' THEN amount
+		WHEN entry_type = 'REVERSAL' AND debit_account = 'system:withdrawals' THEN -amount END), 0)
+	FROM ledger_entries WHERE login = $1`
+
+// добавление записи в журнал операций в рамках транзакции tx
+// возвращает false, если запись с таким ключом идемпотентности уже проведена
+func (ms *StorageSQL) appendLedgerEntry(ctx context.Context, tx *sql.Tx, e models.LedgerEntry) (inserted bool, err error) {
+	// создаем текст запроса, повторная запись с тем же ключом игнорируется
+	q := `INSERT INTO ledger_entries (idempotency_key, login, entry_type, debit_account, credit_account, amount)
+	VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (idempotency_key) DO NOTHING`
+	// записываем в хранилице
+	res, err := tx.ExecContext(ctx, q, e.IdempotencyKey, e.Login, e.EntryType, e.DebitAccount, e.CreditAccount, e.Amount)
+	if err != nil {
+		log.Printf("insert StorageAppendLedgerEntry SQL request error: %s", err)
+		return false, err
+	}
+	// проверяем, была ли добавлена запись
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("rows affected StorageAppendLedgerEntry error: %s", err)
+		return false, err
+	}
+	return n > 0, err
+}
+
+// интерфейс выполнения запроса одной строки, общий для *sql.DB и *sql.Tx
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// сервис получения баланса и суммы списаний пользователя, рассчитанных по журналу операций
+func (ms *StorageSQL) LedgerBalance(ctx context.Context, login string) (ec models.LoginBalance, err error) {
+	return ledgerBalance(ctx, ms.PostgreSQL, login)
+}
+
+// расчет баланса и суммы списаний пользователя по журналу операций в соединении или транзакции
+func ledgerBalance(ctx context.Context, db rowQueryer, login string) (ec models.LoginBalance, err error) {
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременные
+	err = db.QueryRowContext(ctx, qLedgerBalance, login, UserAccount(login)).Scan(&ec.Current, &ec.Withdrawn)
+	if err != nil {
+		log.Printf("select StorageLedgerBalance SQL request scan error: %s", err)
+	}
+	return ec, err
+}
+
+// сервис сверки сохраненного баланса пользователя с балансом по журналу операций
+func (ms *StorageSQL) VerifyBalance(ctx context.Context, login string) (ec models.BalanceCheck, err error) {
+	ec.Login = login
+	// получаем сохраненный баланс
+	ec.Stored, err = ms.Status(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	// получаем баланс по журналу операций
+	ec.Ledger, err = ms.LedgerBalance(ctx, login)
+	if err != nil {
+		return ec, err
+	}
+	// сравниваем значения
+	ec.Consistent = ec.Stored.Current.Equal(ec.Ledger.Current) && ec.Stored.Withdrawn.Equal(ec.Ledger.Withdrawn)
+	if !ec.Consistent {
+		log.Printf("balance of login %s differs from ledger: stored %s/%s, ledger %s/%s", login,
+			ec.Stored.Current, ec.Stored.Withdrawn, ec.Ledger.Current, ec.Ledger.Withdrawn)
+	}
+	return ec, err
+}
+
+// пересчет сохраненного баланса пользователя по журналу операций в рамках транзакции tx
+func rebuildBalance(ctx context.Context, tx *sql.Tx, login string) (err error) {
+	// создаем текст запроса, расчет и запись выполняются одной инструкцией
+	q := `UPDATE balance SET current_balance = l.cur, total_withdrawn = l.withdrawn
+	FROM (` + qLedgerBalance + `) AS l (cur, withdrawn) WHERE balance.login = $1`
+	// записываем в хранилице
+	_, err = tx.ExecContext(ctx, q, login, UserAccount(login))
+	if err != nil {
+		log.Printf("update StorageRebuildBalance SQL request error: %s", err)
+	}
+	return err
+}
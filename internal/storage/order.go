@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
@@ -14,7 +17,11 @@ import (
 // сервис загрузки номера заказа для расчёта без обноления статуса
 func (ms *StorageSQL) Load(ctx context.Context, login string, orderNum string) (err error) {
 	// создаем текст запроса, часть значений дефолтные в DB Postgre (см конструктор базы)
-	q := `INSERT INTO orders (order_num, login) VALUES ($1, $2)`
+	// начальный статус заказа записывается в историю статусов тем же запросом
+	q := `WITH ins AS (
+		INSERT INTO orders (order_num, login) VALUES ($1, $2) RETURNING order_num, status, accrual
+	)
+	INSERT INTO order_status_history (order_num, status, accrual) SELECT order_num, status, accrual FROM ins`
 	// записываем в хранилице orderNum, login
 	_, err = ms.PostgreSQL.ExecContext(ctx, q, orderNum, login)
 	// если нет ошибки, возвращаем nil
@@ -35,11 +42,11 @@ func (ms *StorageSQL) Load(ctx context.Context, login string, orderNum string) (
 			return err
 		}
 		if existLogin != login {
-			err = errors.New("the same order number was loaded by another customer")
+			err = domainerrors.ErrOrderOwnedByOther
 			log.Printf("select StorageNewOrderLoad SQL request: %s", err)
 			return err
 		}
-		err = errors.New("order number from this login already exist")
+		err = domainerrors.ErrOrderLoaded
 		log.Printf("select StorageNewOrderLoad SQL request : %s", err)
 		return err
 	}
@@ -48,6 +55,7 @@ func (ms *StorageSQL) Load(ctx context.Context, login string, orderNum string) (
 }
 
 // сервис обновление статуса и начислений заказа для расчёта
+// начисление проводится через журнал операций один раз на заказ, баланс увеличивается на сумму начисления
 func (ms *StorageSQL) Update(ctx context.Context, login string, dc models.OrderSatus) (err error) {
 	// объявляем транзакцию
 	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
@@ -57,71 +65,88 @@ func (ms *StorageSQL) Update(ctx context.Context, login string, dc models.OrderS
 	}
 	defer tx.Rollback()
 	{
-		// создаем текст запроса обновление orders
-		q := `UPDATE orders SET status = $3, accrual = $4 WHERE login = $1 AND order_num = $2 AND status != $3`
+		// создаем текст запроса обновление orders, измененный статус записывается в историю статусов
+		q := `WITH upd AS (
+			UPDATE orders SET status = $3, accrual = $4 WHERE login = $1 AND order_num = $2 AND status != $3
+			RETURNING order_num, status, accrual
+		)
+		INSERT INTO order_status_history (order_num, status, accrual) SELECT order_num, status, accrual FROM upd`
 		// записываем в хранилице поля из структуры и аргумента
-		_, err := ms.PostgreSQL.Exec(q, login, dc.Order, dc.Status, dc.Accrual)
+		_, err := tx.ExecContext(ctx, q, login, dc.Order, dc.Status, dc.Accrual)
 		// логируем и возвращаем соответствующую ошибку
 		if err != nil {
 			log.Printf("update SQL request StorageNewOrderUpdate error: %s", err)
-			if rollbackErr := tx.Rollback(); rollbackErr != nil {
-				log.Printf("unable StorageCreateNewUser to rollback: %s", rollbackErr)
-			}
 			return err
 		}
 	}
-	{
-		//если сумма начистения в обновлении больше 0, то добавлеям сумму начисления к балансу
-		if dc.Accrual.GreaterThan(decimal.NewFromInt(0)) {
-			// получаем текущее значение баланса аккаунта
-			var balanceCurrent decimal.Decimal
-			// создаем текст запроса
-			q := `SELECT current_balance FROM balance WHERE login = $1`
-			// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
-			err = ms.PostgreSQL.QueryRow(q, login).Scan(&balanceCurrent)
-			if err != nil {
-				log.Printf("select StorageNewOrderUpdate SQL request scan error: %s", err)
-				if rollbackErr := tx.Rollback(); rollbackErr != nil {
-					log.Printf("unable StorageCreateNewUser to rollback: %s", rollbackErr)
-				}
-				return err
-			}
-			// добавляем значение начисления к балансу
-			log.Printf("balance before: %s", balanceCurrent)
-			balanceCurrent = dc.Accrual.Add(balanceCurrent)
-			log.Printf("balance after: %s", balanceCurrent)
+	//если сумма начистения в обновлении больше 0, то проводим начисление по журналу и добавлеям сумму начисления к балансу
+	if dc.Accrual.GreaterThan(decimal.NewFromInt(0)) {
+		// добавляем запись о начислении в журнал операций
+		inserted, err := ms.appendLedgerEntry(ctx, tx, models.LedgerEntry{
+			IdempotencyKey: "accrual:" + dc.Order,
+			Login:          login,
+			EntryType:      models.EntryAccrual,
+			DebitAccount:   models.AccountAccruals,
+			CreditAccount:  UserAccount(login),
+			Amount:         dc.Accrual,
+		})
+		if err != nil {
+			return err
+		}
+		// начисление по заказу уже проведено ранее
+		if !inserted {
+			log.Printf("accrual for order %s already exists in ledger", dc.Order)
+		} else {
 			// создаем текст запроса обновление balance
-			q = `UPDATE balance SET current_balance = $2 WHERE login = $1`
+			q := `UPDATE balance SET current_balance = current_balance + $2 WHERE login = $1`
 			// записываем в хранилице
-			_, err := ms.PostgreSQL.Exec(q, login, dc.Accrual)
+			_, err = tx.ExecContext(ctx, q, login, dc.Accrual)
 			// если не ок логируем и возвращаем соответствующую ошибку
 			if err != nil {
-				err = errors.New("error for balance udpate")
 				log.Printf("update SQL request StorageNewOrderUpdate error: %s", err)
-				if rollbackErr := tx.Rollback(); rollbackErr != nil {
-					log.Printf("unable StorageCreateNewUser to rollback: %s", rollbackErr)
-				}
-				return err
+				return errors.New("error for balance udpate")
 			}
+			log.Printf("balance of login %s increased by %s", login, dc.Accrual)
 		}
 	}
 	// сохраняем изменения
 	if err := tx.Commit(); err != nil {
 		log.Printf("error StorageNewOrderUpdate tx.Commit %s: ", err)
+		return err
 	}
 	return err
 }
 
-// сервис получения списка размещенных пользователем заказов, сортировка выдачи по времени загрузки
-func (ms *StorageSQL) List(ctx context.Context, login string) (ec []models.OrdersList, err error) {
-	// создаем текст запроса
-	q := `SELECT order_num, status, accrual, change_time FROM orders WHERE login = $1 ORDER BY change_time`
-	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременные
-	rows, err := ms.PostgreSQL.QueryContext(ctx, q, login)
+// сервис получения списка размещенных пользователем заказов по параметрам выборки, сортировка выдачи по времени загрузки
+func (ms *StorageSQL) List(ctx context.Context, login string, lq models.ListQuery) (ec []models.OrdersList, err error) {
+	// собираем записи в слайс
+	err = ms.ListEach(ctx, login, lq, func(s models.OrdersList) error {
+		ec = append(ec, s)
+		return nil
+	})
 	if err != nil {
-		log.Printf("select StorageGetOrdersList SQL reqest error %s:", err)
 		return ec, err
 	}
+	// проверяем наличие записей
+	if len(ec) == 0 {
+		log.Printf("request StorageGetOrdersList len == 0: %s", err)
+		err = domainerrors.ErrNoOrders
+	}
+	return ec, err
+}
+
+// сервис построчного чтения списка размещенных пользователем заказов по параметрам выборки:
+// fn вызывается для каждого заказа по мере чтения из хранилища, ошибка fn прекращает чтение
+func (ms *StorageSQL) ListEach(ctx context.Context, login string, lq models.ListQuery, fn func(models.OrdersList) error) (err error) {
+	// создаем текст запроса с условиями, сортировкой и ограничением выборки
+	clause, args := listClause(lq, "change_time", "order_num", "status", []interface{}{login})
+	q := `SELECT order_num, status, accrual, change_time FROM orders WHERE login = $1` + clause
+	// делаем запрос в SQL, получаем строки и передаем результат запроса по одной записи
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q, args...)
+	if err != nil {
+		log.Printf("select StorageGetOrdersList SQL reqest error %s:", err)
+		return err
+	}
 	defer rows.Close()
 	s := models.OrdersList{}
 	// пишем результат запроса (итерирование по полученному набору строк) в структуру
@@ -129,6 +154,57 @@ func (ms *StorageSQL) List(ctx context.Context, login string) (ec []models.Order
 		err = rows.Scan(&s.Number, &s.Status, &s.Accrual, &s.UploadedAt)
 		if err != nil {
 			log.Printf("row by row scan StorageGetOrdersList error : %s", err)
+			return err
+		}
+		if err = fn(s); err != nil {
+			return err
+		}
+	}
+	// проверяем итерации на ошибки
+	err = rows.Err()
+	if err != nil {
+		log.Printf("request StorageGetOrdersList iteration scan error: %s", err)
+	}
+	return err
+}
+
+// сервис получения заказа пользователя с историей изменения статуса, заказы других пользователей не выдаются
+func (ms *StorageSQL) Order(ctx context.Context, login string, orderNum string) (ec models.OrderTimeline, err error) {
+	// создаем текст запроса
+	q := `SELECT order_num, status, accrual, change_time FROM orders WHERE order_num = $1 AND login = $2`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременные
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, orderNum, login).Scan(&ec.Number, &ec.Status, &ec.Accrual, &ec.UploadedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrOrderNotFound
+		log.Printf("select StorageOrder SQL: %s", err)
+		return ec, err
+	}
+	if err != nil {
+		log.Printf("select StorageOrder SQL request scan error: %s", err)
+		return ec, err
+	}
+	ec.History, err = ms.statusHistory(ctx, orderNum)
+	return ec, err
+}
+
+// история изменения статуса заказа в порядке изменений
+func (ms *StorageSQL) statusHistory(ctx context.Context, orderNum string) (ec []models.OrderStatusChange, err error) {
+	// создаем текст запроса
+	q := `SELECT status, accrual, create_time FROM order_status_history WHERE order_num = $1 ORDER BY id`
+	// делаем запрос в SQL, получаем строки и пишем результат запроса в пременные
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q, orderNum)
+	if err != nil {
+		log.Printf("select StorageStatusHistory SQL reqest error : %s", err)
+		return ec, err
+	}
+	defer rows.Close()
+	ec = []models.OrderStatusChange{}
+	s := models.OrderStatusChange{}
+	// пишем результат запроса (итерирование по полученному набору строк) в структуру
+	for rows.Next() {
+		err = rows.Scan(&s.Status, &s.Accrual, &s.ChangedAt)
+		if err != nil {
+			log.Printf("row by row scan StorageStatusHistory error : %s", err)
 			return ec, err
 		}
 		ec = append(ec, s)
@@ -136,13 +212,42 @@ func (ms *StorageSQL) List(ctx context.Context, login string) (ec []models.Order
 	// проверяем итерации на ошибки
 	err = rows.Err()
 	if err != nil {
-		log.Printf("request StorageGetOrdersList iteration scan error: %s", err)
+		log.Printf("request StorageStatusHistory iteration scan error: %s", err)
+	}
+	return ec, err
+}
+
+// сервис получения пачки заказов с нефинальным статусом для восстановления очереди задач
+// выдача отсортирована по номеру заказа, следующая пачка запрашивается с номера последнего полученного заказа
+func (ms *StorageSQL) PendingOrders(ctx context.Context, afterOrderNum string, maxAge time.Duration, limit int) (ec []models.Task, err error) {
+	// создаем текст запроса, ограничение по возрасту заказа не применяется при нулевом maxAge
+	q := `SELECT order_num, login FROM orders
+	WHERE status NOT IN ('INVALID', 'PROCESSED') AND order_num > $1
+	AND NOT EXISTS (SELECT 1 FROM tasks WHERE tasks.order_num = orders.order_num AND tasks.exhausted)
+	AND ($2::bigint = 0 OR change_time >= CURRENT_TIMESTAMP - $2::bigint * interval '1 millisecond')
+	ORDER BY order_num
+	LIMIT $3`
+	// делаем запрос в SQL, получаем строки и пишем результат запроса в пременные
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q, afterOrderNum, maxAge.Milliseconds(), limit)
+	if err != nil {
+		log.Printf("select StoragePendingOrders SQL reqest error : %s", err)
 		return ec, err
 	}
-	// проверяем наличие записей
-	if len(ec) == 0 {
-		log.Printf("request StorageGetOrdersList len == 0: %s", err)
-		err = errors.New("no orders for this login")
+	defer rows.Close()
+	s := models.Task{}
+	// пишем результат запроса (итерирование по полученному набору строк) в структуру
+	for rows.Next() {
+		err = rows.Scan(&s.OrderNum, &s.Login)
+		if err != nil {
+			log.Printf("row by row scan StoragePendingOrders error : %s", err)
+			return ec, err
+		}
+		ec = append(ec, s)
+	}
+	// проверяем итерации на ошибки
+	err = rows.Err()
+	if err != nil {
+		log.Printf("request StoragePendingOrders iteration scan error: %s", err)
 	}
 	return ec, err
 }
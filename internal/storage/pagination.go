@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// условия, сортировка и ограничение выборки списка по параметрам q для колонок времени и ключа записи
+// statusCol == "" - список без фильтра по статусу, аргументы условий добавляются к args
+func listClause(q models.ListQuery, timeCol string, keyCol string, statusCol string, args []interface{}) (string, []interface{}) {
+	var b strings.Builder
+	// плейсхолдер следующего аргумента запроса
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if statusCol != "" && len(q.Statuses) > 0 {
+		placeholders := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			placeholders[i] = arg(status)
+		}
+		fmt.Fprintf(&b, " AND %s IN (%s)", statusCol, strings.Join(placeholders, ", "))
+	}
+	if !q.From.IsZero() {
+		fmt.Fprintf(&b, " AND %s >= %s", timeCol, arg(q.From))
+	}
+	if !q.To.IsZero() {
+		fmt.Fprintf(&b, " AND %s < %s", timeCol, arg(q.To))
+	}
+	// записи после курсора в порядке сортировки, ключ различает записи с одинаковым временем
+	direction, compare := "ASC", ">"
+	if q.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if q.After != nil {
+		fmt.Fprintf(&b, " AND (%s, %s) %s (%s, %s)", timeCol, keyCol, compare, arg(q.After.Time), arg(q.After.Key))
+	}
+	fmt.Fprintf(&b, " ORDER BY %s %s, %s %s", timeCol, direction, keyCol, direction)
+	if q.Limit > 0 {
+		fmt.Fprintf(&b, " LIMIT %s", arg(q.Limit))
+	}
+	return b.String(), args
+}
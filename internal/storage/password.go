@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+)
+
+// смена пароля пользователя в транзакции: все ранее выданные токены становятся недействительными
+func (ms *StorageSQL) ChangePassword(ctx context.Context, login string, passwHash string) (err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StorageChangePassword tx.Begin : %s", err)
+		return err
+	}
+	defer tx.Rollback()
+	if err = setPassword(ctx, tx, login, passwHash); err != nil {
+		return err
+	}
+	// сохраняем изменения
+	if err = tx.Commit(); err != nil {
+		log.Printf("error StorageChangePassword tx.Commit : %s", err)
+	}
+	return err
+}
+
+// сохранение хеша одноразового токена сброса пароля
+func (ms *StorageSQL) PasswordResetCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error) {
+	// создаем текст запроса
+	q := `INSERT INTO password_resets (token_hash, login, expires_at) VALUES ($1, $2, $3)`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, tokenHash, login, expiresAt)
+	if err != nil {
+		log.Printf("insert StoragePasswordResetCreate SQL request error: %s", err)
+	}
+	return err
+}
+
+// получение логина по действующему токену сброса пароля без использования токена
+func (ms *StorageSQL) PasswordResetLogin(ctx context.Context, tokenHash string) (login string, err error) {
+	// создаем текст запроса
+	q := `SELECT login FROM password_resets WHERE token_hash = $1 AND NOT used AND expires_at > CURRENT_TIMESTAMP`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, tokenHash).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrResetTokenNotFound
+		log.Printf("select StoragePasswordResetLogin SQL: %s", err)
+		return "", err
+	}
+	if err != nil {
+		log.Printf("select StoragePasswordResetLogin SQL request scan error: %s", err)
+	}
+	return login, err
+}
+
+// сброс пароля по токену в транзакции: токен используется однократно, пароль меняется,
+// все ранее выданные токены становятся недействительными, возвращает логин владельца токена
+func (ms *StorageSQL) PasswordReset(ctx context.Context, tokenHash string, passwHash string) (login string, err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StoragePasswordReset tx.Begin : %s", err)
+		return "", err
+	}
+	defer tx.Rollback()
+	// отмечаем токен использованным, повторное или просроченное использование не находит строку
+	q := `UPDATE password_resets SET used = true
+	WHERE token_hash = $1 AND NOT used AND expires_at > CURRENT_TIMESTAMP
+	RETURNING login`
+	err = tx.QueryRowContext(ctx, q, tokenHash).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrResetTokenNotFound
+		log.Printf("update StoragePasswordReset SQL: %s", err)
+		return "", err
+	}
+	if err != nil {
+		log.Printf("update StoragePasswordReset SQL request scan error: %s", err)
+		return "", err
+	}
+	if err = setPassword(ctx, tx, login, passwHash); err != nil {
+		return "", err
+	}
+	// сохраняем изменения
+	if err = tx.Commit(); err != nil {
+		log.Printf("error StoragePasswordReset tx.Commit : %s", err)
+		return "", err
+	}
+	return login, nil
+}
+
+// обновление хеша и времени смены пароля, отзыв токенов обновления и неиспользованных токенов сброса пароля
+func setPassword(ctx context.Context, tx *sql.Tx, login string, passwHash string) (err error) {
+	// токены доступа, выданные до смены пароля, отклоняются по времени смены пароля
+	q := `UPDATE users SET password = $2, password_changed_at = CURRENT_TIMESTAMP WHERE login = $1`
+	if _, err = tx.ExecContext(ctx, q, login, passwHash); err != nil {
+		log.Printf("update StorageSetPassword SQL request error: %s", err)
+		return err
+	}
+	q = `UPDATE refresh_tokens SET revoked = true WHERE login = $1 AND NOT revoked`
+	if _, err = tx.ExecContext(ctx, q, login); err != nil {
+		log.Printf("update StorageSetPassword refresh tokens SQL request error: %s", err)
+		return err
+	}
+	q = `UPDATE password_resets SET used = true WHERE login = $1 AND NOT used`
+	if _, err = tx.ExecContext(ctx, q, login); err != nil {
+		log.Printf("update StorageSetPassword password resets SQL request error: %s", err)
+		return err
+	}
+	return nil
+}
@@ -39,6 +39,12 @@ func NewSQLStorage(p string) *StorageSQL {
 	 password text NOT NULL,
 	 CONSTRAINT PK_1_users PRIMARY KEY ( login )
 	 );
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at timestamp with time zone;
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user';
+
+	ALTER TABLE users ADD COLUMN IF NOT EXISTS role_changed_at timestamp with time zone;
 	
 	CREATE TABLE IF NOT EXISTS orders
 	(
@@ -68,16 +74,204 @@ func NewSQLStorage(p string) *StorageSQL {
 	 withdrawal_time timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
 	 CONSTRAINT PK_1_withdrawals PRIMARY KEY ( new_order ),
 	 CONSTRAINT REF_FK_3_withdrawals FOREIGN KEY ( login ) REFERENCES users ( login )
-	);`
+	);
+
+	CREATE TABLE IF NOT EXISTS tasks
+	(
+	 order_num    text NOT NULL,
+	 login        text NOT NULL,
+	 locked_until timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	 registered   boolean NOT NULL DEFAULT false,
+	 attempts     integer NOT NULL DEFAULT 0,
+	 create_time  timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_tasks PRIMARY KEY ( order_num ),
+	 CONSTRAINT REF_FK_1_tasks FOREIGN KEY ( order_num ) REFERENCES orders ( order_num )
+	);
+
+	CREATE INDEX IF NOT EXISTS IDX_1_tasks ON tasks ( locked_until );
+
+	ALTER TABLE tasks ADD COLUMN IF NOT EXISTS exhausted boolean NOT NULL DEFAULT false;
+
+	CREATE TABLE IF NOT EXISTS order_status_history
+	(
+	 id          bigserial,
+	 order_num   text NOT NULL,
+	 status      text NOT NULL,
+	 accrual     decimal DEFAULT 0,
+	 create_time timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_order_status_history PRIMARY KEY ( id ),
+	 CONSTRAINT REF_FK_1_order_status_history FOREIGN KEY ( order_num ) REFERENCES orders ( order_num )
+	);
+
+	CREATE INDEX IF NOT EXISTS IDX_1_order_status_history ON order_status_history ( order_num );
+
+	INSERT INTO order_status_history (order_num, status, accrual, create_time)
+	SELECT o.order_num, o.status, o.accrual, o.change_time FROM orders o
+	WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_num = o.order_num);
+
+	CREATE TABLE IF NOT EXISTS accrual_polls
+	(
+	 order_num   text NOT NULL,
+	 attempt     integer NOT NULL DEFAULT 0,
+	 status_code integer NOT NULL DEFAULT 0,
+	 response    text NOT NULL DEFAULT '',
+	 error       text NOT NULL DEFAULT '',
+	 poll_time   timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_accrual_polls PRIMARY KEY ( order_num ),
+	 CONSTRAINT REF_FK_1_accrual_polls FOREIGN KEY ( order_num ) REFERENCES orders ( order_num )
+	);
+
+	CREATE TABLE IF NOT EXISTS ledger_entries
+	(
+	 id              bigserial,
+	 idempotency_key text NOT NULL UNIQUE,
+	 login           text NOT NULL,
+	 entry_type      text NOT NULL,
+	 debit_account   text NOT NULL,
+	 credit_account  text NOT NULL,
+	 amount          decimal NOT NULL CHECK ( amount > 0 ),
+	 create_time     timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_ledger_entries PRIMARY KEY ( id ),
+	 CONSTRAINT REF_FK_1_ledger_entries FOREIGN KEY ( login ) REFERENCES users ( login )
+	);
+
+	CREATE INDEX IF NOT EXISTS IDX_1_ledger_entries ON ledger_entries ( login );
+
+	CREATE TABLE IF NOT EXISTS balance_adjustments
+	(
+	 idempotency_key text NOT NULL,
+	 reason          text NOT NULL,
+	 comment         text NOT NULL,
+	 created_by      text NOT NULL,
+	 CONSTRAINT PK_1_balance_adjustments PRIMARY KEY ( idempotency_key ),
+	 CONSTRAINT REF_FK_1_balance_adjustments FOREIGN KEY ( idempotency_key ) REFERENCES ledger_entries ( idempotency_key )
+	);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys
+	(
+	 login        text NOT NULL,
+	 idem_key     text NOT NULL,
+	 endpoint     text NOT NULL,
+	 request_hash text NOT NULL,
+	 status_code  integer NOT NULL DEFAULT 0,
+	 content_type text NOT NULL DEFAULT '',
+	 body         bytea,
+	 create_time  timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_idempotency_keys PRIMARY KEY ( login, idem_key ),
+	 CONSTRAINT REF_FK_1_idempotency_keys FOREIGN KEY ( login ) REFERENCES users ( login )
+	);
+
+	CREATE TABLE IF NOT EXISTS refresh_tokens
+	(
+	 token_hash  text NOT NULL,
+	 login       text NOT NULL,
+	 expires_at  timestamp with time zone NOT NULL,
+	 revoked     boolean NOT NULL DEFAULT false,
+	 create_time timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_refresh_tokens PRIMARY KEY ( token_hash ),
+	 CONSTRAINT REF_FK_1_refresh_tokens FOREIGN KEY ( login ) REFERENCES users ( login )
+	);
+
+	CREATE INDEX IF NOT EXISTS IDX_1_refresh_tokens ON refresh_tokens ( login );
+
+	CREATE TABLE IF NOT EXISTS revoked_tokens
+	(
+	 jti        text NOT NULL,
+	 expires_at timestamp with time zone NOT NULL,
+	 CONSTRAINT PK_1_revoked_tokens PRIMARY KEY ( jti )
+	);
+
+	CREATE TABLE IF NOT EXISTS password_resets
+	(
+	 token_hash  text NOT NULL,
+	 login       text NOT NULL,
+	 expires_at  timestamp with time zone NOT NULL,
+	 used        boolean NOT NULL DEFAULT false,
+	 create_time timestamp with time zone DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_password_resets PRIMARY KEY ( token_hash ),
+	 CONSTRAINT REF_FK_1_password_resets FOREIGN KEY ( login ) REFERENCES users ( login )
+	);
+
+	CREATE TABLE IF NOT EXISTS login_attempts
+	(
+	 attempt_key  text NOT NULL,
+	 failures     integer NOT NULL DEFAULT 0,
+	 last_failure timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	 locked_until timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	 CONSTRAINT PK_1_login_attempts PRIMARY KEY ( attempt_key )
+	);
+
+	INSERT INTO ledger_entries (idempotency_key, login, entry_type, debit_account, credit_account, amount)
+	SELECT o.k, o.login, o.t, o.d, o.c, o.a FROM (
+	 SELECT 'opening:credit:' || login AS k, login, 'ADJUSTMENT' AS t, 'system:adjustments' AS d, 'user:' || login AS c,
+	  current_balance + total_withdrawn AS a FROM balance
+	 UNION ALL
+	 SELECT 'opening:withdrawn:' || login, login, 'WITHDRAWAL', 'user:' || login, 'system:withdrawals',
+	  total_withdrawn FROM balance
+	) AS o
+	WHERE o.a > 0 AND NOT EXISTS (SELECT 1 FROM ledger_entries l WHERE l.login = o.login)
+	ON CONFLICT (idempotency_key) DO NOTHING;`
 
 	// создаем таблицу в SQL базе, если не существует
+	// журнал операций заполняется начальными остатками для балансов, по которым еще нет записей,
+	// история статусов - текущими статусами заказов, по которым еще нет записей
 	_, err = db.ExecContext(ctx, q)
 	if err != nil {
 		log.Printf("request NewSQLStorage to sql db returned error: %s%s%s", settings.ColorRed, err, settings.ColorReset)
 	}
-	return &StorageSQL{
+	ms := &StorageSQL{
 		PostgreSQL: db,
 	}
+	// уникальность логина без учета регистра обеспечивается индексом
+	if _, err := ms.UniqueLoginIndex(ctx); err != nil {
+		log.Printf("unique login index NewSQLStorage error: %s%s%s", settings.ColorRed, err, settings.ColorReset)
+	}
+	return ms
+}
+
+// создание уникального индекса логинов без учета регистра, если в хранилище нет логинов,
+// совпадающих без учета регистра, иначе создается неуникальный индекс и возвращаются группы совпадающих логинов:
+// такие учетные записи объединяются или переименовываются вручную, уникальный индекс создается при следующем запуске
+func (ms *StorageSQL) UniqueLoginIndex(ctx context.Context) (duplicates []string, err error) {
+	// находим логины, совпадающие без учета регистра
+	q := `SELECT string_agg(login, ', ' ORDER BY login) FROM users GROUP BY lower(login) HAVING count(*) > 1 ORDER BY lower(login)`
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q)
+	if err != nil {
+		log.Printf("select UniqueLoginIndex SQL request error: %s", err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var logins string
+		if err = rows.Scan(&logins); err != nil {
+			log.Printf("scan UniqueLoginIndex error: %s", err)
+			return nil, err
+		}
+		duplicates = append(duplicates, logins)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("rows UniqueLoginIndex error: %s", err)
+		return nil, err
+	}
+	// совпадающие логины мешают созданию уникального индекса, оставляем неуникальный индекс для поиска
+	if len(duplicates) > 0 {
+		for _, logins := range duplicates {
+			log.Printf("logins differ only in case, unique login index is not created: %s%s%s", settings.ColorRed, logins, settings.ColorReset)
+		}
+		_, err = ms.PostgreSQL.ExecContext(ctx, `CREATE INDEX IF NOT EXISTS IDX_1_users ON users ( lower(login) )`)
+		if err != nil {
+			log.Printf("create index UniqueLoginIndex SQL request error: %s", err)
+		}
+		return duplicates, err
+	}
+	// уникальный индекс заменяет неуникальный индекс прежних версий
+	q = `CREATE UNIQUE INDEX IF NOT EXISTS UIDX_1_users ON users ( lower(login) );
+	DROP INDEX IF EXISTS IDX_1_users;`
+	_, err = ms.PostgreSQL.ExecContext(ctx, q)
+	if err != nil {
+		log.Printf("create unique index UniqueLoginIndex SQL request error: %s", err)
+	}
+	return nil, err
 }
 
 // метод закрытия совединения с SQL базой
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// сервис сверки сохраненных балансов всех пользователей с суммой начислений по рассчитанным заказам за вычетом списаний
+// с учетом ручных корректировок администраторами, возвращает только балансы с расхождениями
+func (ms *StorageSQL) ReconcileBalances(ctx context.Context) (ec []models.BalanceDrift, err error) {
+	// создаем текст запроса
+	q := `SELECT b.login, b.current_balance, b.total_withdrawn,
+	COALESCE(o.accrued, 0) - COALESCE(w.withdrawn, 0) + COALESCE(a.adjusted, 0), COALESCE(w.withdrawn, 0)
+	FROM balance b
+	LEFT JOIN (SELECT login, SUM(accrual) AS accrued FROM orders WHERE status = 'PROCESSED' GROUP BY login) o ON o.login = b.login
+	LEFT JOIN (SELECT login, SUM("sum") AS withdrawn FROM withdrawals GROUP BY login) w ON w.login = b.login
+	LEFT JOIN (SELECT login, SUM(CASE WHEN credit_account = 'user:' || login THEN amount ELSE -amount END) AS adjusted
+	 FROM ledger_entries WHERE entry_type = 'MANUAL_ADJUSTMENT' GROUP BY login) a ON a.login = b.login
+	ORDER BY b.login`
+	// делаем запрос в SQL, получаем строки и пишем результат запроса в пременные
+	rows, err := ms.PostgreSQL.QueryContext(ctx, q)
+	if err != nil {
+		log.Printf("select StorageReconcileBalances SQL reqest error : %s", err)
+		return ec, err
+	}
+	defer rows.Close()
+	s := models.BalanceDrift{}
+	// пишем результат запроса (итерирование по полученному набору строк) в структуру
+	for rows.Next() {
+		err = rows.Scan(&s.Login, &s.Stored.Current, &s.Stored.Withdrawn, &s.Expected.Current, &s.Expected.Withdrawn)
+		if err != nil {
+			log.Printf("row by row scan StorageReconcileBalances error : %s", err)
+			return ec, err
+		}
+		// пропускаем балансы без расхождений
+		if s.Stored.Current.Equal(s.Expected.Current) && s.Stored.Withdrawn.Equal(s.Expected.Withdrawn) {
+			continue
+		}
+		ec = append(ec, s)
+	}
+	// проверяем итерации на ошибки
+	err = rows.Err()
+	if err != nil {
+		log.Printf("request StorageReconcileBalances iteration scan error: %s", err)
+	}
+	return ec, err
+}
+
+// сервис исправления расхождения баланса: в журнал операций добавляются корректирующие записи
+// до совпадения с ожидаемым значением, сохраненный баланс пересчитывается по журналу операций
+// если баланс изменился после сверки, исправление не выполняется
+func (ms *StorageSQL) FixBalance(ctx context.Context, dc models.BalanceDrift) (err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StorageFixBalance tx.Begin : %s", err)
+		return err
+	}
+	defer tx.Rollback()
+	// блокируем строку баланса и проверяем, что баланс не изменился после сверки
+	var stored models.LoginBalance
+	q := `SELECT current_balance, total_withdrawn FROM balance WHERE login = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, q, dc.Login).Scan(&stored.Current, &stored.Withdrawn)
+	if err != nil {
+		log.Printf("select StorageFixBalance SQL request scan error: %s", err)
+		return err
+	}
+	if !stored.Current.Equal(dc.Stored.Current) || !stored.Withdrawn.Equal(dc.Stored.Withdrawn) {
+		err = domainerrors.ErrBalanceChanged
+		log.Printf("error StorageFixBalance for login %s: %s", dc.Login, err)
+		return err
+	}
+	// получаем баланс по журналу операций
+	ledger, err := ledgerBalance(ctx, tx, dc.Login)
+	if err != nil {
+		return err
+	}
+	// ключ корректирующих записей
+	key := fmt.Sprintf("reconcile:%s:%d", dc.Login, time.Now().UnixNano())
+	// корректируем сумму списаний в журнале списанием или сторнированием списания
+	deltaWithdrawn := dc.Expected.Withdrawn.Sub(ledger.Withdrawn)
+	if !deltaWithdrawn.IsZero() {
+		e := models.LedgerEntry{
+			IdempotencyKey: key + ":withdrawn",
+			Login:          dc.Login,
+			EntryType:      models.EntryWithdrawal,
+			DebitAccount:   UserAccount(dc.Login),
+			CreditAccount:  models.AccountWithdrawals,
+			Amount:         deltaWithdrawn.Abs(),
+		}
+		if deltaWithdrawn.IsNegative() {
+			e.EntryType = models.EntryReversal
+			e.DebitAccount, e.CreditAccount = e.CreditAccount, e.DebitAccount
+		}
+		if _, err = ms.appendLedgerEntry(ctx, tx, e); err != nil {
+			return err
+		}
+		// списание или сторнирование изменяет и баланс счета
+		ledger.Current = ledger.Current.Sub(deltaWithdrawn)
+	}
+	// корректируем баланс в журнале
+	deltaCurrent := dc.Expected.Current.Sub(ledger.Current)
+	if !deltaCurrent.IsZero() {
+		e := models.LedgerEntry{
+			IdempotencyKey: key + ":current",
+			Login:          dc.Login,
+			EntryType:      models.EntryAdjustment,
+			DebitAccount:   models.AccountAdjustments,
+			CreditAccount:  UserAccount(dc.Login),
+			Amount:         deltaCurrent.Abs(),
+		}
+		if deltaCurrent.IsNegative() {
+			e.DebitAccount, e.CreditAccount = e.CreditAccount, e.DebitAccount
+		}
+		if _, err = ms.appendLedgerEntry(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+	// пересчитываем сохраненный баланс по исправленному журналу
+	if err = rebuildBalance(ctx, tx, dc.Login); err != nil {
+		return err
+	}
+	// сохраняем изменения
+	if err := tx.Commit(); err != nil {
+		log.Printf("error StorageFixBalance tx.Commit : %s", err)
+		return err
+	}
+	return err
+}
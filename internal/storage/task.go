@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/rs/zerolog/log"
+)
+
+// добавление задачи регистрации и опроса внешнего сервиса в очередь, повторное добавление существующей задачи
+// игнорируется, в том числе задачи с исчерпанным бюджетом попыток
+func (ms *StorageSQL) TaskAppend(ctx context.Context, task models.Task) (err error) {
+	// создаем текст запроса, часть значений дефолтные в DB Postgre (см конструктор базы)
+	q := `INSERT INTO tasks (order_num, login) VALUES ($1, $2) ON CONFLICT (order_num) DO NOTHING`
+	// записываем в хранилице orderNum, login
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, task.OrderNum, task.Login)
+	if err != nil {
+		log.Printf("insert StorageTaskAppend SQL request error: %s", err)
+	}
+	return err
+}
+
+// получение задачи из очереди с арендой на время lease, задача недоступна другим воркерам до истечения аренды
+// если доступных задач нет, возвращается ошибка sql.ErrNoRows
+func (ms *StorageSQL) TaskClaim(ctx context.Context, lease time.Duration) (task models.Task, err error) {
+	// создаем текст запроса, блокируем строку и пропускаем строки, заблокированные другими транзакциями
+	q := `UPDATE tasks SET locked_until = CURRENT_TIMESTAMP + $1 * interval '1 millisecond'
+	WHERE order_num = (
+		SELECT order_num FROM tasks
+		WHERE locked_until <= CURRENT_TIMESTAMP AND NOT exhausted
+		ORDER BY locked_until
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING order_num, login, registered, attempts, create_time, locked_until`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в структуру
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, lease.Milliseconds()).Scan(&task.OrderNum, &task.Login, &task.Registered, &task.Attempts, &task.CreatedAt, &task.LockedUntil)
+	return task, err
+}
+
+// продление аренды задачи на время lease от текущего момента, аренда продлевается, только если задача
+// не была получена заново или поставлена в очередь повторно после истечения аренды task.LockedUntil
+// если аренда перехвачена, возвращается ошибка sql.ErrNoRows
+func (ms *StorageSQL) TaskRenew(ctx context.Context, task models.Task, lease time.Duration) (lockedUntil time.Time, err error) {
+	// создаем текст запроса
+	q := `UPDATE tasks SET locked_until = CURRENT_TIMESTAMP + $3 * interval '1 millisecond'
+	WHERE order_num = $1 AND locked_until = $2 AND NOT exhausted
+	RETURNING locked_until`
+	// делаем запрос в SQL, получаем новое время окончания аренды
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, task.OrderNum, task.LockedUntil, lease.Milliseconds()).Scan(&lockedUntil)
+	return lockedUntil, err
+}
+
+// возврат задачи в очередь с сохранением отметки о регистрации, счетчика попыток и времени следующей попытки
+func (ms *StorageSQL) TaskRelease(ctx context.Context, task models.Task) (err error) {
+	// создаем текст запроса
+	q := `UPDATE tasks SET registered = $2, attempts = $3, locked_until = $4 WHERE order_num = $1`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, task.OrderNum, task.Registered, task.Attempts, task.NextAttempt)
+	if err != nil {
+		log.Printf("update StorageTaskRelease SQL request error: %s", err)
+	}
+	return err
+}
+
+// удаление выполненной задачи из очереди
+func (ms *StorageSQL) TaskDelete(ctx context.Context, orderNum string) (err error) {
+	// создаем текст запроса
+	q := `DELETE FROM tasks WHERE order_num = $1`
+	// удаляем из хранилища
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, orderNum)
+	if err != nil {
+		log.Printf("delete StorageTaskDelete SQL request error: %s", err)
+	}
+	return err
+}
+
+// задача с исчерпанным бюджетом попыток остается в очереди отметкой, недоступной воркерам,
+// чтобы заказ не восстанавливался в очереди при запуске сервиса
+func (ms *StorageSQL) TaskExhaust(ctx context.Context, task models.Task) (err error) {
+	// создаем текст запроса
+	q := `UPDATE tasks SET exhausted = true, registered = $2, attempts = $3, locked_until = CURRENT_TIMESTAMP WHERE order_num = $1`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, task.OrderNum, task.Registered, task.Attempts)
+	if err != nil {
+		log.Printf("update StorageTaskExhaust SQL request error: %s", err)
+	}
+	return err
+}
+
+// немедленная постановка задачи в очередь: существующая задача становится доступной воркерам сразу,
+// счетчик попыток, отметка об исчерпании и время создания задачи сбрасываются для нового бюджета повторных опросов
+// задача, полученная воркером по действующей аренде, не изменяется - возвращается ErrPollInProgress
+func (ms *StorageSQL) TaskRequeue(ctx context.Context, task models.Task) (err error) {
+	// создаем текст запроса
+	q := `INSERT INTO tasks (order_num, login) VALUES ($1, $2)
+	ON CONFLICT (order_num) DO UPDATE SET locked_until = CURRENT_TIMESTAMP, attempts = 0, exhausted = false, create_time = CURRENT_TIMESTAMP
+	WHERE tasks.locked_until <= CURRENT_TIMESTAMP`
+	// записываем в хранилице
+	res, err := ms.PostgreSQL.ExecContext(ctx, q, task.OrderNum, task.Login)
+	if err != nil {
+		log.Printf("insert StorageTaskRequeue SQL request error: %s", err)
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("rows affected StorageTaskRequeue error: %s", err)
+		return err
+	}
+	// задача обрабатывается воркером, аренду не перехватываем
+	if n == 0 {
+		return domainerrors.ErrPollInProgress
+	}
+	return nil
+}
+
+// сохранение результата последнего опроса внешнего сервиса по заказу
+func (ms *StorageSQL) TaskObserve(ctx context.Context, poll models.AccrualPoll) (err error) {
+	// создаем текст запроса
+	q := `INSERT INTO accrual_polls (order_num, attempt, status_code, response, error, poll_time)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (order_num) DO UPDATE SET attempt = $2, status_code = $3, response = $4, error = $5, poll_time = $6`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, poll.OrderNum, poll.Attempt, poll.StatusCode, poll.Response, poll.Error, poll.PolledAt)
+	if err != nil {
+		log.Printf("insert StorageTaskObserve SQL request error: %s", err)
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_NewAdjustment(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name    string
+		dc      models.NewAdjustment
+		mock    func()
+		want    models.LoginBalance
+		wantErr error
+	}{
+		{
+			name: "Positive test - credit inside transaction with row lock",
+			dc: models.NewAdjustment{Operation: models.AdjustmentCredit, Amount: decimal.NewFromInt(100),
+				Reason: "GOODWILL", Comment: "compensation", CreatedBy: "admin"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "42"))
+				mock.ExpectExec(`UPDATE balance SET current_balance = current_balance \+ (.+) WHERE login = (.+)`).
+					WithArgs("dimma", decimal.NewFromInt(100)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+)`).
+					WithArgs(sqlmock.AnyArg(), "dimma", models.EntryManualAdjustment, models.AccountAdjustments, "user:dimma", decimal.NewFromInt(100)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`INSERT INTO balance_adjustments (.+)`).
+					WithArgs(sqlmock.AnyArg(), "GOODWILL", "compensation", "admin").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: models.LoginBalance{Current: decimal.NewFromInt(600), Withdrawn: decimal.NewFromInt(42)},
+		},
+		{
+			name: "Positive test - debit does not change withdrawn sum",
+			dc: models.NewAdjustment{Operation: models.AdjustmentDebit, Amount: decimal.NewFromInt(100),
+				Reason: "DUPLICATE_ACCRUAL", Comment: "order accrued twice", CreatedBy: "admin"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "42"))
+				mock.ExpectExec(`UPDATE balance SET current_balance = current_balance \+ (.+) WHERE login = (.+)`).
+					WithArgs("dimma", decimal.NewFromInt(-100)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+)`).
+					WithArgs(sqlmock.AnyArg(), "dimma", models.EntryManualAdjustment, "user:dimma", models.AccountAdjustments, decimal.NewFromInt(100)).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`INSERT INTO balance_adjustments (.+)`).
+					WithArgs(sqlmock.AnyArg(), "DUPLICATE_ACCRUAL", "order accrued twice", "admin").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			want: models.LoginBalance{Current: decimal.NewFromInt(400), Withdrawn: decimal.NewFromInt(42)},
+		},
+		{
+			name: "Negative test - debit over balance",
+			dc: models.NewAdjustment{Operation: models.AdjustmentDebit, Amount: decimal.NewFromInt(501),
+				Reason: "FRAUD", Comment: "fraud", CreatedBy: "admin"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "42"))
+				mock.ExpectRollback()
+			},
+			want:    models.LoginBalance{Current: decimal.NewFromInt(500), Withdrawn: decimal.NewFromInt(42)},
+			wantErr: domainerrors.ErrInsufficientFunds,
+		},
+		{
+			name: "Negative test - login not exist",
+			dc: models.NewAdjustment{Operation: models.AdjustmentCredit, Amount: decimal.NewFromInt(100),
+				Reason: "GOODWILL", Comment: "compensation", CreatedBy: "admin"},
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrLoginNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, err := r.NewAdjustment(ctx, "dimma", tt.dc)
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			if tt.want.Current.IsZero() {
+				assert.True(t, ec.Current.IsZero())
+			} else {
+				assert.True(t, tt.want.Current.Equal(ec.Current), "current %s", ec.Current)
+				assert.True(t, tt.want.Withdrawn.Equal(ec.Withdrawn), "withdrawn %s", ec.Withdrawn)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
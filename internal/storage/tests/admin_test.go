@@ -0,0 +1,301 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_AdminUser(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	changedAt := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    func()
+		want    models.AdminUser
+		wantErr error
+	}{
+		{
+			name: "Positive test - user with changed password",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"login", "role", "password_changed_at", "current_balance", "total_withdrawn", "count"}).
+					AddRow("dimma", "admin", changedAt, "500.5", "42", 3)
+				mock.ExpectQuery(`SELECT u.login, u.role, u.password_changed_at, (.+) FROM users u (.+) WHERE u.login = (.+)`).
+					WithArgs("dimma").
+					WillReturnRows(rows)
+			},
+			want: models.AdminUser{
+				Login: "dimma",
+				Role:  "admin",
+				Balance: models.LoginBalance{
+					Current:   decimal.RequireFromString("500.5"),
+					Withdrawn: decimal.RequireFromString("42"),
+				},
+				Orders:            3,
+				PasswordChangedAt: &changedAt,
+			},
+		},
+		{
+			name: "Positive test - user without password change",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"login", "role", "password_changed_at", "current_balance", "total_withdrawn", "count"}).
+					AddRow("dimma", "user", nil, "0", "0", 0)
+				mock.ExpectQuery(`SELECT u.login, u.role, (.+) FROM users u`).
+					WithArgs("dimma").
+					WillReturnRows(rows)
+			},
+			want: models.AdminUser{
+				Login:   "dimma",
+				Role:    "user",
+				Balance: models.LoginBalance{Current: decimal.RequireFromString("0"), Withdrawn: decimal.RequireFromString("0")},
+			},
+		},
+		{
+			name: "Negative test - login not exist",
+			mock: func() {
+				mock.ExpectQuery(`SELECT u.login, u.role, (.+) FROM users u`).
+					WithArgs("dimma").
+					WillReturnError(sql.ErrNoRows)
+			},
+			want:    models.AdminUser{},
+			wantErr: domainerrors.ErrLoginNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, err := r.AdminUser(ctx, "dimma")
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, ec)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_OrderDiagnostics(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	uploadedAt := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	polledAt := uploadedAt.Add(time.Minute)
+	nextAttempt := uploadedAt.Add(2 * time.Minute)
+	// ожидаемый запрос заказа
+	expectOrder := func(status string) {
+		rows := sqlmock.NewRows([]string{"login", "order_num", "status", "accrual", "change_time"}).
+			AddRow("dimma", "2377225624", status, "0", uploadedAt)
+		mock.ExpectQuery(`SELECT login, order_num, status, accrual, change_time FROM orders WHERE order_num = (.+)`).
+			WithArgs("2377225624").
+			WillReturnRows(rows)
+	}
+	order := models.AdminOrder{
+		Login: "dimma",
+		OrdersList: models.OrdersList{
+			Number:     "2377225624",
+			Accrual:    decimal.RequireFromString("0"),
+			UploadedAt: uploadedAt,
+		},
+	}
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    func()
+		want    func() models.OrderDiagnostics
+		wantErr error
+	}{
+		{
+			name: "Positive test - order in queue with last poll",
+			mock: func() {
+				expectOrder("PROCESSING")
+				mock.ExpectQuery(`SELECT status, accrual, create_time FROM order_status_history WHERE order_num = (.+) ORDER BY id`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"status", "accrual", "create_time"}).
+						AddRow("NEW", "0", uploadedAt).
+						AddRow("PROCESSING", "0", polledAt))
+				mock.ExpectQuery(`SELECT attempt, status_code, response, error, poll_time FROM accrual_polls WHERE order_num = (.+)`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"attempt", "status_code", "response", "error", "poll_time"}).
+						AddRow(3, 200, `{"status":"PROCESSING"}`, "", polledAt))
+				mock.ExpectQuery(`SELECT registered, attempts, locked_until, exhausted FROM tasks WHERE order_num = (.+)`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"registered", "attempts", "locked_until", "exhausted"}).
+						AddRow(true, 3, nextAttempt, false))
+			},
+			want: func() models.OrderDiagnostics {
+				ec := models.OrderDiagnostics{AdminOrder: order}
+				ec.Status = "PROCESSING"
+				ec.History = []models.OrderStatusChange{
+					{Status: "NEW", Accrual: decimal.RequireFromString("0"), ChangedAt: uploadedAt},
+					{Status: "PROCESSING", Accrual: decimal.RequireFromString("0"), ChangedAt: polledAt},
+				}
+				ec.LastPoll = &models.AccrualPoll{OrderNum: "2377225624", Attempt: 3, StatusCode: 200, Response: `{"status":"PROCESSING"}`, PolledAt: polledAt}
+				ec.Queued = true
+				ec.Registered = true
+				ec.Attempts = 3
+				ec.NextAttempt = &nextAttempt
+				return ec
+			},
+		},
+		{
+			name: "Positive test - order retry budget exhausted",
+			mock: func() {
+				expectOrder("PROCESSING")
+				mock.ExpectQuery(`SELECT status, accrual, create_time FROM order_status_history`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"status", "accrual", "create_time"}).
+						AddRow("PROCESSING", "0", uploadedAt))
+				mock.ExpectQuery(`SELECT attempt, (.+) FROM accrual_polls`).
+					WithArgs("2377225624").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(`SELECT registered, attempts, locked_until, exhausted FROM tasks`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"registered", "attempts", "locked_until", "exhausted"}).
+						AddRow(true, 20, nextAttempt, true))
+			},
+			want: func() models.OrderDiagnostics {
+				ec := models.OrderDiagnostics{AdminOrder: order}
+				ec.Status = "PROCESSING"
+				ec.History = []models.OrderStatusChange{{Status: "PROCESSING", Accrual: decimal.RequireFromString("0"), ChangedAt: uploadedAt}}
+				ec.Exhausted = true
+				ec.Registered = true
+				ec.Attempts = 20
+				return ec
+			},
+		},
+		{
+			name: "Positive test - order not in queue without polls",
+			mock: func() {
+				expectOrder("NEW")
+				mock.ExpectQuery(`SELECT status, accrual, create_time FROM order_status_history`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"status", "accrual", "create_time"}).
+						AddRow("NEW", "0", uploadedAt))
+				mock.ExpectQuery(`SELECT attempt, (.+) FROM accrual_polls`).
+					WithArgs("2377225624").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery(`SELECT registered, (.+) FROM tasks`).
+					WithArgs("2377225624").
+					WillReturnError(sql.ErrNoRows)
+			},
+			want: func() models.OrderDiagnostics {
+				ec := models.OrderDiagnostics{AdminOrder: order}
+				ec.Status = "NEW"
+				ec.History = []models.OrderStatusChange{{Status: "NEW", Accrual: decimal.RequireFromString("0"), ChangedAt: uploadedAt}}
+				return ec
+			},
+		},
+		{
+			name: "Negative test - order not exist",
+			mock: func() {
+				mock.ExpectQuery(`SELECT login, order_num, (.+) FROM orders`).
+					WithArgs("2377225624").
+					WillReturnError(sql.ErrNoRows)
+			},
+			want:    func() models.OrderDiagnostics { return models.OrderDiagnostics{} },
+			wantErr: domainerrors.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, err := r.OrderDiagnostics(ctx, "2377225624")
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want(), ec)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_SetUserRole(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr error
+	}{
+		{
+			name: "Positive test - role changed, refresh tokens revoked",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE users SET role = (.+), role_changed_at = CURRENT_TIMESTAMP WHERE login = (.+)`).
+					WithArgs("dimma", "admin").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked = true WHERE login = (.+) AND NOT revoked`).
+					WithArgs("dimma").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Negative test - login not exist",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE users SET role = (.+) WHERE login = (.+)`).
+					WithArgs("dimma", "admin").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrLoginNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			err := r.SetUserRole(ctx, "dimma", "admin")
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_LoginAttemptFailed(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name         string
+		mock         func()
+		wantFailures int
+		wantErr      error
+	}{
+		{
+			name: "Positive test - failed attempt counted",
+			mock: func() {
+				rows := sqlmock.NewRows([]string{"failures"}).AddRow(3)
+				mock.ExpectQuery(`INSERT INTO login_attempts (.+) ON CONFLICT (.+) DO UPDATE SET (.+) RETURNING failures`).
+					WithArgs("login:dimma", (15 * time.Minute).Milliseconds()).
+					WillReturnRows(rows)
+			},
+			wantFailures: 3,
+		},
+		{
+			name: "Negative test - sql DB down",
+			mock: func() {
+				mock.ExpectQuery(`INSERT INTO login_attempts (.+)`).
+					WithArgs("login:dimma", (15 * time.Minute).Milliseconds()).
+					WillReturnError(errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"))
+			},
+			wantErr: errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			failures, err := r.LoginAttemptFailed(ctx, "login:dimma", 15*time.Minute)
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantFailures, failures)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_LoginAttemptsLocked(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	lockedUntil := time.Now().Add(time.Minute).Truncate(time.Second)
+	// табличный тест
+	tests := []struct {
+		name    string
+		rowTime time.Time
+		want    time.Time
+	}{
+		{
+			name:    "Positive test - login is locked",
+			rowTime: lockedUntil,
+			want:    lockedUntil,
+		},
+		{
+			name:    "Positive test - login is not locked",
+			rowTime: time.Unix(0, 0),
+			want:    time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := sqlmock.NewRows([]string{"locked_until"}).AddRow(tt.rowTime)
+			mock.ExpectQuery(`SELECT (.+) FROM login_attempts WHERE attempt_key IN (.+)`).
+				WithArgs("login:dimma", "ip:192.0.2.1").
+				WillReturnRows(rows)
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			got, err := r.LoginAttemptsLocked(ctx, []string{"login:dimma", "ip:192.0.2.1"})
+			// проверки
+			assert.NoError(t, err)
+			assert.True(t, tt.want.Equal(got))
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/jackc/pgconn"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_NewWithdrawal(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// принимаемые аргументы
+	type args struct {
+		login string
+		dc    models.NewWithdrawal
+	}
+	// тип поведения заглушки
+	type mockBehavior func(args args)
+	// ошибка нарушение уникальности значений при вставке
+	duplicateErr := &pgconn.PgError{
+		Severity:       "ERROR",
+		Code:           "23505",
+		Message:        "duplicate key value violates unique constraint \"pk_1_withdrawals\"",
+		TableName:      "withdrawals",
+		ConstraintName: "pk_1_withdrawals",
+	}
+	// табличный тест
+	tests := []struct {
+		name  string
+		mock  mockBehavior
+		input args
+		want  error
+	}{
+		{
+			name: "Positive test - withdrawal inside transaction with row lock",
+			input: args{
+				login: "dimma",
+				dc:    models.NewWithdrawal{Order: "2377225624", Sum: decimal.NewFromInt(42)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs(args.login).
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "0"))
+				mock.ExpectExec(`INSERT INTO withdrawals (.+)`).
+					WithArgs(args.dc.Order, args.login, args.dc.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE balance SET current_balance = current_balance - (.+), total_withdrawn = total_withdrawn \+ (.+)`).
+					WithArgs(args.login, args.dc.Sum).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+)`).
+					WithArgs("withdrawal:"+args.dc.Order, args.login, models.EntryWithdrawal, "user:"+args.login, models.AccountWithdrawals, args.dc.Sum).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Negative test - insufficient funds",
+			input: args{
+				login: "dimma",
+				dc:    models.NewWithdrawal{Order: "2377225624", Sum: decimal.NewFromInt(751)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs(args.login).
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "0"))
+				mock.ExpectRollback()
+			},
+			want: domainerrors.ErrInsufficientFunds,
+		},
+		{
+			name: "Negative test - new order number already exist",
+			input: args{
+				login: "dimma",
+				dc:    models.NewWithdrawal{Order: "2377225624", Sum: decimal.NewFromInt(42)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs(args.login).
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("500", "0"))
+				mock.ExpectExec(`INSERT INTO withdrawals (.+)`).
+					WithArgs(args.dc.Order, args.login, args.dc.Sum).
+					WillReturnError(duplicateErr)
+				mock.ExpectRollback()
+			},
+			want: domainerrors.ErrWithdrawalExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			err := r.NewWithdrawal(ctx, tt.input.login, tt.input.dc)
+			// проверки
+			assert.Equal(t, tt.want, err)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ShiraazMoollatjie/goluhn"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/handlers"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/services"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/storage"
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// тест параллельных списаний на реальной базе PostgreSQL, адрес базы задается переменной окружения TEST_DATABASE_URI
+func TestStorage_NewWithdrawalConcurrency(t *testing.T) {
+	dlink, ok := os.LookupEnv("TEST_DATABASE_URI")
+	if !ok {
+		t.Skip("TEST_DATABASE_URI is not set")
+	}
+	s := storage.NewSQLStorage(dlink)
+	require.NotNil(t, s)
+	defer s.ConnectionClose()
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// создаем пользователя и начисляем 100 баллов
+	login := fmt.Sprintf("concurrency%d", time.Now().UnixNano())
+	require.NoError(t, s.Create(ctx, login, "passw"))
+	order := goluhn.Generate(12)
+	require.NoError(t, s.Load(ctx, login, order))
+	require.NoError(t, s.Update(ctx, login, models.OrderSatus{Order: order, Status: "PROCESSED", Accrual: decimal.NewFromInt(100)}))
+	// обработчик списаний поверх реального хранилища
+	h := handlers.NewBalanceHandler(services.NewBalanceService(s))
+	// параллельно отправляем запросы на списание по 10 баллов, суммарно больше баланса
+	const requests = 25
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"order": "%s", "sum": 10}`, goluhn.Generate(12))
+			request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+			// контекст логина
+			tkn := jwt.New()
+			tkn.Set(`login`, login)
+			request = request.WithContext(jwtauth.NewContext(request.Context(), tkn, nil))
+			w := httptest.NewRecorder()
+			h.NewWithdrawal(w, request)
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	// проверки: успешно ровно 10 списаний, остальные отклонены из-за недостатка средств
+	var okCount, paymentRequired int
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			okCount++
+		case http.StatusPaymentRequired:
+			paymentRequired++
+		default:
+			t.Errorf("unexpected status code %d", code)
+		}
+	}
+	assert.Equal(t, 10, okCount)
+	assert.Equal(t, requests-10, paymentRequired)
+	// баланс не ушел в минус и совпадает с журналом операций
+	ec, err := s.VerifyBalance(ctx, login)
+	require.NoError(t, err)
+	assert.True(t, ec.Stored.Current.Equal(decimal.Zero))
+	assert.True(t, ec.Stored.Withdrawn.Equal(decimal.NewFromInt(100)))
+	assert.True(t, ec.Consistent)
+}
@@ -0,0 +1,83 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_IdempotencyReserve(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// тип поведения заглушки
+	type mockBehavior func()
+	rec := models.IdempotencyRecord{Endpoint: "POST /api/user/orders", RequestHash: "hash"}
+	window, lease := 24*time.Hour, time.Minute
+	// табличный тест
+	tests := []struct {
+		name         string
+		mock         mockBehavior
+		want         models.IdempotencyRecord
+		wantReserved bool
+	}{
+		{
+			name: "Positive test - key reserved, expired reservation without result reclaimed",
+			mock: func() {
+				mock.ExpectExec(`DELETE FROM idempotency_keys WHERE (.+) OR \(status_code = 0 AND create_time < (.+)\)`).
+					WithArgs("dimma", "key-1", window.Milliseconds(), lease.Milliseconds()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO idempotency_keys (.+) ON CONFLICT (.+) DO NOTHING`).
+					WithArgs("dimma", "key-1", rec.Endpoint, rec.RequestHash).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			want:         rec,
+			wantReserved: true,
+		},
+		{
+			name: "Positive test - key is in progress within lease",
+			mock: func() {
+				mock.ExpectExec(`DELETE FROM idempotency_keys`).
+					WithArgs("dimma", "key-1", window.Milliseconds(), lease.Milliseconds()).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO idempotency_keys`).
+					WithArgs("dimma", "key-1", rec.Endpoint, rec.RequestHash).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				rows := sqlmock.NewRows([]string{"endpoint", "request_hash", "status_code", "content_type", "body"}).
+					AddRow(rec.Endpoint, rec.RequestHash, 0, "", []byte{})
+				mock.ExpectQuery(`SELECT endpoint, request_hash, status_code, content_type, (.+) FROM idempotency_keys`).
+					WithArgs("dimma", "key-1").
+					WillReturnRows(rows)
+			},
+			want: models.IdempotencyRecord{Endpoint: rec.Endpoint, RequestHash: rec.RequestHash, Body: []byte{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, reserved, err := r.IdempotencyReserve(ctx, "dimma", "key-1", rec, window, lease)
+			// проверки
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantReserved, reserved)
+			assert.Equal(t, tt.want, ec)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
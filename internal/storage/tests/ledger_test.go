@@ -0,0 +1,178 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_Update(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// принимаемые аргументы
+	type args struct {
+		login string
+		dc    models.OrderSatus
+	}
+	// тип поведения заглушки
+	type mockBehavior func(args args)
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    mockBehavior
+		input   args
+		wantErr bool
+	}{
+		{
+			name: "Positive test - accrual is added to balance through ledger",
+			input: args{
+				login: "dimma",
+				dc:    models.OrderSatus{Order: "2377225624", Status: "PROCESSED", Accrual: decimal.NewFromInt(500)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE orders SET status (.+)`).
+					WithArgs(args.login, args.dc.Order, args.dc.Status, args.dc.Accrual).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+) ON CONFLICT (.+) DO NOTHING`).
+					WithArgs("accrual:"+args.dc.Order, args.login, models.EntryAccrual, models.AccountAccruals, "user:"+args.login, args.dc.Accrual).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec(`UPDATE balance SET current_balance = current_balance \+ (.+)`).
+					WithArgs(args.login, args.dc.Accrual).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Positive test - accrual already in ledger, balance is not changed",
+			input: args{
+				login: "dimma",
+				dc:    models.OrderSatus{Order: "2377225624", Status: "PROCESSED", Accrual: decimal.NewFromInt(500)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE orders SET status (.+)`).
+					WithArgs(args.login, args.dc.Order, args.dc.Status, args.dc.Accrual).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+)`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Positive test - status without accrual",
+			input: args{
+				login: "dimma",
+				dc:    models.OrderSatus{Order: "2377225624", Status: "PROCESSING"},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE orders SET status (.+)`).
+					WithArgs(args.login, args.dc.Order, args.dc.Status, args.dc.Accrual).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Negative test - database down at ledger insert",
+			input: args{
+				login: "dimma",
+				dc:    models.OrderSatus{Order: "2377225624", Status: "PROCESSED", Accrual: decimal.NewFromInt(500)},
+			},
+			mock: func(args args) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE orders SET status (.+)`).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+)`).
+					WillReturnError(errors.New(`FATAL: terminating connection due to administrator command (SQLSTATE 57P01)`))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.input)
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			err := r.Update(ctx, tt.input.login, tt.input.dc)
+			// проверки
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_VerifyBalance(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name   string
+		stored []string
+		ledger []string
+		want   bool
+	}{
+		{
+			name:   "Positive test - balance matches ledger",
+			stored: []string{"500", "42"},
+			ledger: []string{"500", "42"},
+			want:   true,
+		},
+		{
+			name:   "Negative test - balance overwritten with last accrual",
+			stored: []string{"100", "42"},
+			ledger: []string{"500", "42"},
+			want:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login (.+)`).
+				WithArgs("dimma").
+				WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow(tt.stored[0], tt.stored[1]))
+			mock.ExpectQuery(`SELECT (.+) FROM ledger_entries WHERE login (.+)`).
+				WithArgs("dimma", "user:dimma").
+				WillReturnRows(sqlmock.NewRows([]string{"current", "withdrawn"}).AddRow(tt.ledger[0], tt.ledger[1]))
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, err := r.VerifyBalance(ctx, "dimma")
+			// проверки
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ec.Consistent)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_PendingOrders(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// ожидаемый запрос: заказы с исчерпанным бюджетом попыток не восстанавливаются
+	rows := sqlmock.NewRows([]string{"order_num", "login"}).
+		AddRow("12345678903", "dimma").
+		AddRow("2377225624", "dimma2")
+	mock.ExpectQuery(`SELECT order_num, login FROM orders WHERE status NOT IN (.+) AND NOT EXISTS \(SELECT 1 FROM tasks WHERE (.+) AND tasks.exhausted\) (.+) ORDER BY order_num LIMIT (.+)`).
+		WithArgs("1", time.Hour.Milliseconds(), 2).
+		WillReturnRows(rows)
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	ec, err := r.PendingOrders(ctx, "1", time.Hour, 2)
+	// проверки
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Task{
+		{OrderNum: "12345678903", Login: "dimma"},
+		{OrderNum: "2377225624", Login: "dimma2"},
+	}, ec)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_LoadStatusHistory(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// ожидаемый запрос: начальный статус записывается в историю тем же запросом
+	mock.ExpectExec(`INSERT INTO orders (.+) RETURNING order_num, status, accrual (.+) INSERT INTO order_status_history (.+) FROM ins`).
+		WithArgs("2377225624", "dimma").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	err = r.Load(ctx, "dimma", "2377225624")
+	// проверки
+	assert.NoError(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_Order(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	uploadedAt := time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC)
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    func()
+		want    models.OrderTimeline
+		wantErr error
+	}{
+		{
+			name: "Positive test - order with status timeline",
+			mock: func() {
+				mock.ExpectQuery(`SELECT order_num, status, accrual, change_time FROM orders WHERE order_num = (.+) AND login = (.+)`).
+					WithArgs("2377225624", "dimma").
+					WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}).
+						AddRow("2377225624", "PROCESSED", "500", uploadedAt))
+				mock.ExpectQuery(`SELECT status, accrual, create_time FROM order_status_history WHERE order_num = (.+) ORDER BY id`).
+					WithArgs("2377225624").
+					WillReturnRows(sqlmock.NewRows([]string{"status", "accrual", "create_time"}).
+						AddRow("NEW", "0", uploadedAt).
+						AddRow("PROCESSED", "500", uploadedAt.Add(time.Minute)))
+			},
+			want: models.OrderTimeline{
+				OrdersList: models.OrdersList{
+					Number:     "2377225624",
+					Status:     "PROCESSED",
+					Accrual:    decimal.RequireFromString("500"),
+					UploadedAt: uploadedAt,
+				},
+				History: []models.OrderStatusChange{
+					{Status: "NEW", Accrual: decimal.RequireFromString("0"), ChangedAt: uploadedAt},
+					{Status: "PROCESSED", Accrual: decimal.RequireFromString("500"), ChangedAt: uploadedAt.Add(time.Minute)},
+				},
+			},
+		},
+		{
+			name: "Negative test - order of another login",
+			mock: func() {
+				mock.ExpectQuery(`SELECT order_num, (.+) FROM orders`).
+					WithArgs("2377225624", "dimma").
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: domainerrors.ErrOrderNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			ec, err := r.Order(ctx, "dimma", "2377225624")
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.want, ec)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_ListQuery(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	uploadedAt := time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC)
+	from := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2020, time.May, 16, 0, 0, 0, 0, time.UTC)
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test - orders without parameters", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT order_num, status, accrual, change_time FROM orders WHERE login = $1 ORDER BY change_time ASC, order_num ASC`)).
+			WithArgs("dimma").
+			WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}).
+				AddRow("2377225624", "NEW", "0", uploadedAt))
+		ec, err := r.List(ctx, "dimma", models.ListQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, ec, 1)
+	})
+
+	t.Run("Positive test - orders with filters, cursor and limit", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`WHERE login = $1 AND status IN ($2, $3) AND change_time >= $4 AND change_time < $5 AND (change_time, order_num) < ($6, $7) ORDER BY change_time DESC, order_num DESC LIMIT $8`)).
+			WithArgs("dimma", "NEW", "PROCESSING", from, to, uploadedAt, "2377225624", 3).
+			WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}).
+				AddRow("12345678903", "PROCESSING", "0", uploadedAt))
+		ec, err := r.List(ctx, "dimma", models.ListQuery{
+			Limit:    3,
+			After:    &models.Cursor{Time: uploadedAt, Key: "2377225624"},
+			Statuses: []string{"NEW", "PROCESSING"},
+			From:     from,
+			To:       to,
+			Desc:     true,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "12345678903", ec[0].Number)
+	})
+
+	t.Run("Negative test - empty page", func(t *testing.T) {
+		mock.ExpectQuery(`FROM orders WHERE login = (.+) AND \(change_time, order_num\) > (.+) LIMIT (.+)`).
+			WithArgs("dimma", uploadedAt, "2377225624", 2).
+			WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}))
+		_, err := r.List(ctx, "dimma", models.ListQuery{Limit: 2, After: &models.Cursor{Time: uploadedAt, Key: "2377225624"}})
+		assert.Equal(t, domainerrors.ErrNoOrders, err)
+	})
+
+	t.Run("Positive test - withdrawals with period and limit", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM withdrawals WHERE login = $1 AND withdrawal_time >= $2 ORDER BY withdrawal_time ASC, new_order ASC LIMIT $3`)).
+			WithArgs("dimma", from, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"new_order", "sum", "withdrawal_time"}).
+				AddRow("2377225624", "42", uploadedAt))
+		ec, err := r.WithdrawalsList(ctx, "dimma", models.ListQuery{Limit: 2, From: from})
+		assert.NoError(t, err)
+		assert.Equal(t, "2377225624", ec[0].Order)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_ListEach(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	uploadedAt := time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC)
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+
+	t.Run("Positive test - orders passed one by one", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT order_num, status, accrual, change_time FROM orders WHERE login = (.+)`).
+			WithArgs("dimma").
+			WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}).
+				AddRow("2377225624", "NEW", "0", uploadedAt).
+				AddRow("12345678903", "PROCESSED", "500", uploadedAt))
+		var numbers []string
+		err := r.ListEach(ctx, "dimma", models.ListQuery{}, func(s models.OrdersList) error {
+			numbers = append(numbers, s.Number)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"2377225624", "12345678903"}, numbers)
+	})
+
+	t.Run("Positive test - empty list without error", func(t *testing.T) {
+		mock.ExpectQuery(`FROM orders WHERE login = (.+)`).
+			WithArgs("dimma").
+			WillReturnRows(sqlmock.NewRows([]string{"order_num", "status", "accrual", "change_time"}))
+		err := r.ListEach(ctx, "dimma", models.ListQuery{}, func(s models.OrdersList) error {
+			t.Errorf("unexpected order %s", s.Number)
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Negative test - callback error stops reading", func(t *testing.T) {
+		mock.ExpectQuery(`FROM withdrawals WHERE login = (.+)`).
+			WithArgs("dimma").
+			WillReturnRows(sqlmock.NewRows([]string{"new_order", "sum", "withdrawal_time"}).
+				AddRow("2377225624", "42", uploadedAt).
+				AddRow("2377225625", "8", uploadedAt))
+		calls := 0
+		writeErr := errors.New("client disconnected")
+		err := r.WithdrawalsEach(ctx, "dimma", models.ListQuery{}, func(s models.WithdrawalsList) error {
+			calls++
+			return writeErr
+		})
+		assert.Equal(t, writeErr, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
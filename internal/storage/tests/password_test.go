@@ -0,0 +1,96 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_PasswordReset(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name      string
+		mock      func()
+		wantLogin string
+		wantErr   error
+	}{
+		{
+			name: "Positive test - password reset by token",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"login"}).AddRow("dimma")
+				mock.ExpectQuery(`UPDATE password_resets SET used = true (.+) RETURNING login`).
+					WithArgs("reset").
+					WillReturnRows(rows)
+				mock.ExpectExec(`UPDATE users SET password = (.+), password_changed_at = (.+) WHERE login = (.+)`).
+					WithArgs("dimma", "hash").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked = true WHERE login = (.+)`).
+					WithArgs("dimma").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectExec(`UPDATE password_resets SET used = true WHERE login = (.+)`).
+					WithArgs("dimma").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectCommit()
+			},
+			wantLogin: "dimma",
+		},
+		{
+			name: "Negative test - token used or expired",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`UPDATE password_resets SET used = true (.+) RETURNING login`).
+					WithArgs("reset").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrResetTokenNotFound,
+		},
+		{
+			name: "Negative test - password update error",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"login"}).AddRow("dimma")
+				mock.ExpectQuery(`UPDATE password_resets SET used = true (.+) RETURNING login`).
+					WithArgs("reset").
+					WillReturnRows(rows)
+				mock.ExpectExec(`UPDATE users SET password = (.+)`).
+					WithArgs("dimma", "hash").
+					WillReturnError(errors.New("something wrong with server"))
+				mock.ExpectRollback()
+			},
+			wantErr: errors.New("something wrong with server"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			login, err := r.PasswordReset(ctx, "reset", "hash")
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantLogin, login)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
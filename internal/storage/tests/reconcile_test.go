@@ -0,0 +1,141 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_ReconcileBalances(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// баланс dimma2 перезаписан последним начислением вместо суммы начислений
+	rows := sqlmock.NewRows([]string{"login", "current_balance", "total_withdrawn", "expected_current", "expected_withdrawn"}).
+		AddRow("dimma", "458", "42", "458", "42").
+		AddRow("dimma2", "100", "0", "600", "0")
+	// ожидаемый баланс учитывает ручные корректировки администраторами
+	mock.ExpectQuery(`SELECT (.+) FROM balance b LEFT JOIN (.+) WHERE entry_type = 'MANUAL_ADJUSTMENT' (.+) ORDER BY b.login`).
+		WillReturnRows(rows)
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	ec, err := r.ReconcileBalances(ctx)
+	// проверки
+	assert.NoError(t, err)
+	assert.Equal(t, []models.BalanceDrift{
+		{
+			Login:    "dimma2",
+			Stored:   models.LoginBalance{Current: decimal.NewFromInt(100), Withdrawn: decimal.NewFromInt(0)},
+			Expected: models.LoginBalance{Current: decimal.NewFromInt(600), Withdrawn: decimal.NewFromInt(0)},
+		},
+	}, ec)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_FixBalance(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// баланс dimma2 перезаписан последним начислением, журнал операций содержит все начисления
+	drift := models.BalanceDrift{
+		Login:    "dimma2",
+		Stored:   models.LoginBalance{Current: decimal.NewFromInt(100), Withdrawn: decimal.NewFromInt(0)},
+		Expected: models.LoginBalance{Current: decimal.NewFromInt(600), Withdrawn: decimal.NewFromInt(0)},
+	}
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    func()
+		wantErr error
+	}{
+		{
+			name: "Positive test - ledger matches expected, balance rebuilt from ledger",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma2").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("100", "0"))
+				mock.ExpectQuery(`SELECT (.+) FROM ledger_entries WHERE login = (.+)`).
+					WithArgs("dimma2", "user:dimma2").
+					WillReturnRows(sqlmock.NewRows([]string{"cur", "withdrawn"}).AddRow("600", "0"))
+				mock.ExpectExec(`UPDATE balance SET current_balance = l.cur, total_withdrawn = l.withdrawn FROM \(SELECT (.+) FROM ledger_entries WHERE login = \$1\) AS l \(cur, withdrawn\) WHERE balance.login = \$1`).
+					WithArgs("dimma2", "user:dimma2").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Positive test - ledger corrected by adjustment and withdrawal reversal",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma2").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("100", "0"))
+				// в журнале лишнее списание 50 и не хватает начисления
+				mock.ExpectQuery(`SELECT (.+) FROM ledger_entries WHERE login = (.+)`).
+					WithArgs("dimma2", "user:dimma2").
+					WillReturnRows(sqlmock.NewRows([]string{"cur", "withdrawn"}).AddRow("50", "50"))
+				// сторнирование списания возвращает 50 на счет пользователя
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+) ON CONFLICT \(idempotency_key\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "dimma2", models.EntryReversal, models.AccountWithdrawals, "user:dimma2", decimal.NewFromInt(50)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				// корректировка доводит баланс журнала до ожидаемых 600
+				mock.ExpectExec(`INSERT INTO ledger_entries (.+) ON CONFLICT \(idempotency_key\) DO NOTHING`).
+					WithArgs(sqlmock.AnyArg(), "dimma2", models.EntryAdjustment, models.AccountAdjustments, "user:dimma2", decimal.NewFromInt(500)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`UPDATE balance SET current_balance = l.cur, total_withdrawn = l.withdrawn FROM (.+) WHERE balance.login = \$1`).
+					WithArgs("dimma2", "user:dimma2").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+		},
+		{
+			name: "Negative test - balance changed after reconciliation",
+			mock: func() {
+				mock.ExpectBegin()
+				// после сверки заказ был рассчитан и баланс увеличился
+				mock.ExpectQuery(`SELECT current_balance, total_withdrawn FROM balance WHERE login = (.+) FOR UPDATE`).
+					WithArgs("dimma2").
+					WillReturnRows(sqlmock.NewRows([]string{"current_balance", "total_withdrawn"}).AddRow("150", "0"))
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrBalanceChanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			err := r.FixBalance(ctx, drift)
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 	"github.com/jackc/pgconn"
 
@@ -19,7 +20,7 @@ func NewPostgreProvider(db *sql.DB) *storage.StorageSQL {
 	return &storage.StorageSQL{PostgreSQL: db}
 }
 
-func TestStorage_CheckAuthorization(t *testing.T) {
+func TestStorage_PasswordHash(t *testing.T) {
 	// создание заглушки
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -34,7 +35,7 @@ func TestStorage_CheckAuthorization(t *testing.T) {
 	}
 	// тип поведения заглушки
 	type mockBehavior func(args args, err error)
-	// табличный тест, будет дополнен негатвными сценариями
+	// табличный тест
 	tests := []struct {
 		name    string
 		mock    mockBehavior
@@ -43,10 +44,10 @@ func TestStorage_CheckAuthorization(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "Positive test - autorization",
+			name: "Positive test - password hash",
 			input: args{
 				login:    "dimma",
-				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+				passwHex: "$argon2id$v=19$m=65536,t=1,p=4$h3XVtg4x84dDwhpjtiI4Ug$EfUcoABkerT6C1UFc//ZuY5xZObgwDM7IirOKK17Kbs",
 			},
 			want: nil,
 			mock: func(args args, err error) {
@@ -58,25 +59,22 @@ func TestStorage_CheckAuthorization(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "Negative test - wrong passw or login",
+			name: "Negative test - login not exist",
 			input: args{
-				login:    "dimma",
-				passwHex: "1",
+				login: "dimma",
 			},
-			want: errors.New("login or password not exist"),
+			want: domainerrors.ErrInvalidCredentials,
 			mock: func(args args, err error) {
-				rows := sqlmock.NewRows([]string{"password"}).AddRow("2")
 				mock.ExpectQuery(`SELECT (.+) FROM users WHERE login (.+)`).
 					WithArgs(args.login).
-					WillReturnRows(rows)
+					WillReturnError(sql.ErrNoRows)
 			},
 			wantErr: true,
 		},
 		{
 			name: "Negative test - sql DB down",
 			input: args{
-				login:    "dimma",
-				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+				login: "dimma",
 			},
 			want: errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"),
 			mock: func(args args, err error) {
@@ -97,13 +95,14 @@ func TestStorage_CheckAuthorization(t *testing.T) {
 			// освобождаем ресурс
 			defer cancel()
 			// запуск метода запроса
-			err := r.CheckAuthorization(ctx, tt.input.login, tt.input.passwHex)
+			passwHash, err := r.PasswordHash(ctx, tt.input.login)
 			// проверки
 			if tt.wantErr {
 				assert.Error(t, err)
+				assert.Equal(t, tt.want, err)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.want, err)
+				assert.Equal(t, tt.input.passwHex, passwHash)
 			}
 			if err := mock.ExpectationsWereMet(); err != nil {
 				t.Errorf("there were unfulfilled expectations: %s", err)
@@ -165,7 +164,7 @@ func TestStorage_Create(t *testing.T) {
 			want: nil,
 			mock: func(args args, err error) {
 				mock.ExpectBegin()
-				mock.ExpectExec(`INSERT INTO users VALUES (.+)`).
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
 					WithArgs(args.login, args.passwHex).WillReturnResult(sqlmock.NewResult(0, 1)).WillReturnError(nil)
 				mock.ExpectExec(`INSERT INTO balance VALUES (.+)`).
 					WithArgs(args.login).WillReturnResult(sqlmock.NewResult(0, 1)).WillReturnError(nil)
@@ -179,15 +178,52 @@ func TestStorage_Create(t *testing.T) {
 				login:    "dimma2",
 				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
 			},
-			want: errors.New("login exist"),
+			want: domainerrors.ErrLoginExists,
 			mock: func(args args, err error) {
 				mock.ExpectBegin()
-				mock.ExpectExec(`INSERT INTO users VALUES (.+)`).
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
 					WithArgs(args.login, args.passwHex).WillReturnError(duplicateErr)
 				mock.ExpectRollback()
 			},
 			wantErr: true,
 		},
+		{
+			name: "Negative test - create user - login exist in another case",
+			input: args{
+				login:    "dimma",
+				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+			},
+			want: domainerrors.ErrLoginExists,
+			mock: func(args args, err error) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
+					WithArgs(args.login, args.passwHex).WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
+		{
+			name: "Negative test - create user - concurrent registration in another case rejected by unique index",
+			input: args{
+				login:    "Dimma2",
+				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
+			},
+			want: domainerrors.ErrLoginExists,
+			mock: func(args args, err error) {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
+					WithArgs(args.login, args.passwHex).WillReturnError(&pgconn.PgError{
+					Severity:       "ERROR",
+					Code:           "23505",
+					Message:        "duplicate key value violates unique constraint \"uidx_1_users\"",
+					Detail:         "Key (lower(login))=(dimma2) already exists.",
+					TableName:      "users",
+					ConstraintName: "uidx_1_users",
+				})
+				mock.ExpectRollback()
+			},
+			wantErr: true,
+		},
 		{
 			name: "Negative test - create user - database down at 1st sql instruction",
 			input: args{
@@ -197,7 +233,7 @@ func TestStorage_Create(t *testing.T) {
 			want: errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"),
 			mock: func(args args, err error) {
 				mock.ExpectBegin()
-				mock.ExpectExec(`INSERT INTO users VALUES (.+)`).
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
 					WithArgs(args.login, args.passwHex).WillReturnError(errors.New(`FATAL: terminating connection due to administrator command (SQLSTATE 57P01)`))
 				mock.ExpectRollback()
 			},
@@ -213,7 +249,7 @@ func TestStorage_Create(t *testing.T) {
 			want: errors.New("FATAL: terminating connection due to administrator command (SQLSTATE 57P01)"),
 			mock: func(args args, err error) {
 				mock.ExpectBegin()
-				mock.ExpectExec(`INSERT INTO users VALUES (.+)`).
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
 					WithArgs(args.login, args.passwHex).WillReturnResult(sqlmock.NewResult(0, 1)).WillReturnError(nil)
 				mock.ExpectExec(`INSERT INTO balance VALUES (.+)`).
 					WithArgs(args.login).WillReturnError(errors.New(`FATAL: terminating connection due to administrator command (SQLSTATE 57P01)`))
@@ -227,10 +263,10 @@ func TestStorage_Create(t *testing.T) {
 				login:    "dimma",
 				passwHex: "5994471abb01112afcc18159f6cc74b4f511b99806da59b3caf5a9c173cacfc5",
 			},
-			want: errors.New("login exist"),
+			want: domainerrors.ErrLoginExists,
 			mock: func(args args, err error) {
 				mock.ExpectBegin()
-				mock.ExpectExec(`INSERT INTO users VALUES (.+)`).
+				mock.ExpectExec(`INSERT INTO users SELECT (.+) WHERE NOT EXISTS (.+)`).
 					WithArgs(args.login, args.passwHex).WillReturnResult(sqlmock.NewResult(0, 1)).WillReturnError(nil)
 				mock.ExpectExec(`INSERT INTO balance VALUES (.+)`).
 					WithArgs(args.login).WillReturnError(duplicateErr)
@@ -262,3 +298,59 @@ func TestStorage_Create(t *testing.T) {
 		})
 	}
 }
+
+func TestStorage_UniqueLoginIndex(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// тип поведения заглушки
+	type mockBehavior func()
+	// табличный тест
+	tests := []struct {
+		name string
+		mock mockBehavior
+		want []string
+	}{
+		{
+			name: "Positive test - unique index replaces plain index",
+			mock: func() {
+				mock.ExpectQuery(`SELECT string_agg(.+) FROM users GROUP BY lower\(login\) HAVING count\(\*\) > 1`).
+					WillReturnRows(sqlmock.NewRows([]string{"logins"}))
+				mock.ExpectExec(`CREATE UNIQUE INDEX IF NOT EXISTS UIDX_1_users ON users \( lower\(login\) \); DROP INDEX IF EXISTS IDX_1_users`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "Negative test - logins differ only in case, plain index kept",
+			mock: func() {
+				mock.ExpectQuery(`SELECT string_agg(.+) FROM users GROUP BY lower\(login\) HAVING count\(\*\) > 1`).
+					WillReturnRows(sqlmock.NewRows([]string{"logins"}).AddRow("Dimma, dimma"))
+				mock.ExpectExec(`CREATE INDEX IF NOT EXISTS IDX_1_users ON users \( lower\(login\) \)`).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: []string{"Dimma, dimma"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			duplicates, err := r.UniqueLoginIndex(ctx)
+			// проверки
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, duplicates)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
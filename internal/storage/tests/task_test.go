@@ -0,0 +1,255 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_TaskClaim(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// тип поведения заглушки
+	type mockBehavior func(lease time.Duration)
+	// табличный тест
+	tests := []struct {
+		name    string
+		mock    mockBehavior
+		lease   time.Duration
+		want    models.Task
+		wantErr error
+	}{
+		{
+			name:  "Positive test - claim task",
+			lease: time.Minute,
+			want:  models.Task{OrderNum: "2377225624", Login: "dimma", Registered: true, Attempts: 3, CreatedAt: time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC), LockedUntil: time.Date(2020, time.May, 16, 10, 1, 0, 0, time.UTC)},
+			mock: func(lease time.Duration) {
+				rows := sqlmock.NewRows([]string{"order_num", "login", "registered", "attempts", "create_time", "locked_until"}).
+					AddRow("2377225624", "dimma", true, 3, time.Date(2020, time.May, 15, 17, 45, 12, 0, time.UTC), time.Date(2020, time.May, 16, 10, 1, 0, 0, time.UTC))
+				mock.ExpectQuery(`UPDATE tasks SET locked_until (.+) WHERE locked_until <= CURRENT_TIMESTAMP AND NOT exhausted (.+) FOR UPDATE SKIP LOCKED (.+) RETURNING order_num, login, registered, attempts, create_time, locked_until`).
+					WithArgs(lease.Milliseconds()).
+					WillReturnRows(rows)
+			},
+		},
+		{
+			name:  "Negative test - queue is empty",
+			lease: time.Minute,
+			mock: func(lease time.Duration) {
+				mock.ExpectQuery(`UPDATE tasks SET locked_until (.+) RETURNING order_num, login`).
+					WithArgs(lease.Milliseconds()).
+					WillReturnError(sql.ErrNoRows)
+			},
+			wantErr: sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock(tt.lease)
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			task, err := r.TaskClaim(ctx, tt.lease)
+			// проверки
+			assert.True(t, errors.Is(err, tt.wantErr))
+			assert.Equal(t, tt.want, task)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_TaskRenew(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	claimed := time.Date(2020, time.May, 16, 10, 1, 0, 0, time.UTC)
+	renewed := time.Date(2020, time.May, 16, 10, 5, 0, 0, time.UTC)
+	// табличный тест
+	tests := []struct {
+		name    string
+		rows    *sqlmock.Rows
+		want    time.Time
+		wantErr error
+	}{
+		{
+			name: "Positive test - lease renewed",
+			rows: sqlmock.NewRows([]string{"locked_until"}).AddRow(renewed),
+			want: renewed,
+		},
+		{
+			name:    "Negative test - task claimed again after lease expired",
+			rows:    sqlmock.NewRows([]string{"locked_until"}),
+			wantErr: sql.ErrNoRows,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ожидаемый запрос: аренда продлевается только по времени окончания полученной аренды
+			mock.ExpectQuery(`UPDATE tasks SET locked_until (.+) WHERE order_num = (.+) AND locked_until = (.+) AND NOT exhausted RETURNING locked_until`).
+				WithArgs("2377225624", claimed, time.Minute.Milliseconds()).
+				WillReturnRows(tt.rows)
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			lockedUntil, err := r.TaskRenew(ctx, models.Task{OrderNum: "2377225624", LockedUntil: claimed}, time.Minute)
+			// проверки
+			assert.True(t, errors.Is(err, tt.wantErr))
+			assert.Equal(t, tt.want, lockedUntil)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_TaskAppend(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// ожидаемый запрос
+	mock.ExpectExec(`INSERT INTO tasks (.+) ON CONFLICT (.+) DO NOTHING`).
+		WithArgs("2377225624", "dimma").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	err = r.TaskAppend(ctx, models.Task{OrderNum: "2377225624", Login: "dimma"})
+	// проверки
+	assert.NoError(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_TaskExhaust(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// ожидаемый запрос: задача остается в очереди с отметкой об исчерпании бюджета попыток
+	mock.ExpectExec(`UPDATE tasks SET exhausted = true, registered = (.+), attempts = (.+) WHERE order_num = (.+)`).
+		WithArgs("2377225624", true, 20).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	err = r.TaskExhaust(ctx, models.Task{OrderNum: "2377225624", Login: "dimma", Registered: true, Attempts: 20})
+	// проверки
+	assert.NoError(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestStorage_TaskRequeue(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// табличный тест
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		wantErr      error
+	}{
+		{
+			name:         "Positive test - existing task becomes available with new attempts budget",
+			rowsAffected: 1,
+		},
+		{
+			name:         "Negative test - task lease is held by worker",
+			rowsAffected: 0,
+			wantErr:      domainerrors.ErrPollInProgress,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// ожидаемый запрос: задача с действующей арендой воркера не изменяется
+			mock.ExpectExec(`INSERT INTO tasks (.+) ON CONFLICT (.+) DO UPDATE SET locked_until = CURRENT_TIMESTAMP, attempts = 0, exhausted = false, (.+) WHERE tasks.locked_until <= CURRENT_TIMESTAMP`).
+				WithArgs("2377225624", "dimma").
+				WillReturnResult(sqlmock.NewResult(0, tt.rowsAffected))
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			err := r.TaskRequeue(ctx, models.Task{OrderNum: "2377225624", Login: "dimma"})
+			// проверки
+			assert.True(t, errors.Is(err, tt.wantErr))
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_TaskObserve(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	polledAt := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// ожидаемый запрос
+	mock.ExpectExec(`INSERT INTO accrual_polls (.+) ON CONFLICT (.+) DO UPDATE`).
+		WithArgs("2377225624", 2, 200, `{"status":"PROCESSING"}`, "", polledAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	err = r.TaskObserve(ctx, models.AccrualPoll{
+		OrderNum:   "2377225624",
+		Attempt:    2,
+		StatusCode: 200,
+		Response:   `{"status":"PROCESSING"}`,
+		PolledAt:   polledAt,
+	})
+	// проверки
+	assert.NoError(t, err)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
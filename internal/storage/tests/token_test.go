@@ -0,0 +1,136 @@
+package sqlstorage_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorage_RefreshTokenRotate(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// срок действия нового токена
+	expiresAt := time.Date(2030, time.May, 15, 17, 45, 12, 0, time.UTC)
+	// табличный тест
+	tests := []struct {
+		name      string
+		mock      func()
+		wantLogin string
+		wantErr   error
+	}{
+		{
+			name: "Positive test - rotate refresh token",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"login", "revoked", "expired"}).AddRow("dimma", false, false)
+				mock.ExpectQuery(`SELECT login, revoked, (.+) FROM refresh_tokens WHERE token_hash = (.+) FOR UPDATE`).
+					WithArgs("old").
+					WillReturnRows(rows)
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked = true WHERE token_hash = (.+)`).
+					WithArgs("old").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`INSERT INTO refresh_tokens (.+)`).
+					WithArgs("new", "dimma", expiresAt).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			},
+			wantLogin: "dimma",
+		},
+		{
+			name: "Negative test - token not exist",
+			mock: func() {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT login, revoked, (.+) FROM refresh_tokens`).
+					WithArgs("old").
+					WillReturnError(sql.ErrNoRows)
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrRefreshTokenNotFound,
+		},
+		{
+			name: "Negative test - token expired",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"login", "revoked", "expired"}).AddRow("dimma", false, true)
+				mock.ExpectQuery(`SELECT login, revoked, (.+) FROM refresh_tokens`).
+					WithArgs("old").
+					WillReturnRows(rows)
+				mock.ExpectRollback()
+			},
+			wantErr: domainerrors.ErrRefreshTokenNotFound,
+		},
+		{
+			name: "Negative test - reuse of rotated token revokes all user tokens",
+			mock: func() {
+				mock.ExpectBegin()
+				rows := sqlmock.NewRows([]string{"login", "revoked", "expired"}).AddRow("dimma", true, false)
+				mock.ExpectQuery(`SELECT login, revoked, (.+) FROM refresh_tokens`).
+					WithArgs("old").
+					WillReturnRows(rows)
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked = true WHERE login = (.+)`).
+					WithArgs("dimma").
+					WillReturnResult(sqlmock.NewResult(0, 2))
+				mock.ExpectCommit()
+			},
+			wantErr: domainerrors.ErrRefreshTokenNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.mock()
+			// создаем контекст
+			ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+			// освобождаем ресурс
+			defer cancel()
+			// запуск метода запроса
+			login, err := r.RefreshTokenRotate(ctx, "old", "new", expiresAt)
+			// проверки
+			assert.Equal(t, tt.wantErr, err)
+			assert.Equal(t, tt.wantLogin, login)
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestStorage_AccessTokenRevoked(t *testing.T) {
+	// создание заглушки
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+	r := NewPostgreProvider(db)
+	// время выдачи токена с точностью до микросекунды
+	issuedAt := time.Date(2020, time.May, 15, 17, 45, 12, 400000000, time.UTC)
+	// ожидаемый запрос: время смены пароля и роли сравнивается без округления до секунды,
+	// токен, выданный в момент смены, отзывается
+	mock.ExpectQuery(`SELECT EXISTS (.+) FROM revoked_tokens (.+) AND \(password_changed_at >= \$3 OR role_changed_at >= \$3\)`).
+		WithArgs("jti", "dimma", issuedAt).
+		WillReturnRows(sqlmock.NewRows([]string{"revoked"}).AddRow(true))
+	// создаем контекст
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// запуск метода запроса
+	revoked, err := r.AccessTokenRevoked(ctx, "dimma", "jti", issuedAt)
+	// проверки
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
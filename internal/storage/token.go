@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
+)
+
+// сохранение хеша нового токена обновления пользователя
+func (ms *StorageSQL) RefreshTokenCreate(ctx context.Context, login string, tokenHash string, expiresAt time.Time) (err error) {
+	// создаем текст запроса
+	q := `INSERT INTO refresh_tokens (token_hash, login, expires_at) VALUES ($1, $2, $3)`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, tokenHash, login, expiresAt)
+	if err != nil {
+		log.Printf("insert StorageRefreshTokenCreate SQL request error: %s", err)
+	}
+	return err
+}
+
+// замена токена обновления на новый в транзакции, возвращает логин владельца токена
+// повторное предъявление уже замененного токена отзывает все токены обновления пользователя
+func (ms *StorageSQL) RefreshTokenRotate(ctx context.Context, tokenHash string, newHash string, expiresAt time.Time) (login string, err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StorageRefreshTokenRotate tx.Begin : %s", err)
+		return "", err
+	}
+	defer tx.Rollback()
+	// получаем токен с блокировкой строки до конца транзакции
+	var revoked, expired bool
+	q := `SELECT login, revoked, expires_at <= CURRENT_TIMESTAMP FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, q, tokenHash).Scan(&login, &revoked, &expired)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrRefreshTokenNotFound
+		log.Printf("select StorageRefreshTokenRotate SQL: %s", err)
+		return "", err
+	}
+	if err != nil {
+		log.Printf("select StorageRefreshTokenRotate SQL request scan error: %s", err)
+		return "", err
+	}
+	// токен уже был заменен - вероятна кража токена, отзываем все токены обновления пользователя
+	if revoked {
+		q = `UPDATE refresh_tokens SET revoked = true WHERE login = $1 AND NOT revoked`
+		if _, err = tx.ExecContext(ctx, q, login); err != nil {
+			log.Printf("update StorageRefreshTokenRotate SQL request error: %s", err)
+			return "", err
+		}
+		if err = tx.Commit(); err != nil {
+			log.Printf("error StorageRefreshTokenRotate tx.Commit : %s", err)
+			return "", err
+		}
+		log.Printf("refresh token reuse detected for login %s, all refresh tokens revoked", login)
+		return "", domainerrors.ErrRefreshTokenNotFound
+	}
+	if expired {
+		err = domainerrors.ErrRefreshTokenNotFound
+		log.Printf("StorageRefreshTokenRotate for login %s: token expired", login)
+		return "", err
+	}
+	// отзываем предъявленный токен
+	q = `UPDATE refresh_tokens SET revoked = true WHERE token_hash = $1`
+	if _, err = tx.ExecContext(ctx, q, tokenHash); err != nil {
+		log.Printf("update StorageRefreshTokenRotate SQL request error: %s", err)
+		return "", err
+	}
+	// сохраняем новый токен
+	q = `INSERT INTO refresh_tokens (token_hash, login, expires_at) VALUES ($1, $2, $3)`
+	if _, err = tx.ExecContext(ctx, q, newHash, login, expiresAt); err != nil {
+		log.Printf("insert StorageRefreshTokenRotate SQL request error: %s", err)
+		return "", err
+	}
+	// сохраняем изменения
+	if err = tx.Commit(); err != nil {
+		log.Printf("error StorageRefreshTokenRotate tx.Commit : %s", err)
+		return "", err
+	}
+	return login, nil
+}
+
+// отзыв всех токенов обновления пользователя
+func (ms *StorageSQL) RefreshTokensRevoke(ctx context.Context, login string) (err error) {
+	// создаем текст запроса
+	q := `UPDATE refresh_tokens SET revoked = true WHERE login = $1 AND NOT revoked`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, login)
+	if err != nil {
+		log.Printf("update StorageRefreshTokensRevoke SQL request error: %s", err)
+	}
+	return err
+}
+
+// добавление идентификатора токена доступа в список отозванных до окончания срока действия токена
+// записи с истекшим сроком действия удаляются
+func (ms *StorageSQL) AccessTokenRevoke(ctx context.Context, jti string, expiresAt time.Time) (err error) {
+	// создаем текст запроса
+	q := `DELETE FROM revoked_tokens WHERE expires_at <= CURRENT_TIMESTAMP`
+	// удаляем из хранилища
+	_, err = ms.PostgreSQL.ExecContext(ctx, q)
+	if err != nil {
+		log.Printf("delete StorageAccessTokenRevoke SQL request error: %s", err)
+		return err
+	}
+	// создаем текст запроса
+	q = `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2) ON CONFLICT (jti) DO NOTHING`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, jti, expiresAt)
+	if err != nil {
+		log.Printf("insert StorageAccessTokenRevoke SQL request error: %s", err)
+	}
+	return err
+}
+
+// проверка, отозван ли токен доступа: идентификатор jti в списке отозванных
+// или токен выдан пользователю login не позже последней смены пароля или роли
+func (ms *StorageSQL) AccessTokenRevoked(ctx context.Context, login string, jti string, issuedAt time.Time) (revoked bool, err error) {
+	// создаем текст запроса, время выдачи токена и время смены сравниваются с точностью до микросекунды,
+	// токены прежних версий со временем выдачи до секунды отзываются и при смене в ту же секунду
+	q := `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+	OR EXISTS (SELECT 1 FROM users WHERE login = $2
+	AND (password_changed_at >= $3 OR role_changed_at >= $3))`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, jti, login, issuedAt).Scan(&revoked)
+	if err != nil {
+		log.Printf("select StorageAccessTokenRevoked SQL request scan error: %s", err)
+	}
+	return revoked, err
+}
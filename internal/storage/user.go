@@ -2,11 +2,14 @@ package storage
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgerrcode"
 	"github.com/rs/zerolog/log"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/domainerrors"
 )
 
 // добавление нового пользователя в хранилище, запись в две таблицы в транзакции
@@ -19,15 +22,22 @@ func (ms *StorageSQL) Create(ctx context.Context, login string, passwHex string)
 	}
 	defer tx.Rollback()
 	{
-		// создаем текст запроса
-		q := `INSERT INTO users VALUES ($1, $2)`
+		// создаем текст запроса, логин не должен совпадать с существующим без учета регистра,
+		// при одновременной регистрации совпадающих логинов вторая запись отклоняется уникальным индексом по lower(login)
+		q := `INSERT INTO users SELECT $1, $2 WHERE NOT EXISTS (SELECT 1 FROM users WHERE lower(login) = lower($1))`
 		// записываем в хранилице login, passwHex
-		_, err = tx.Exec(q, login, passwHex)
+		var res sql.Result
+		var inserted int64
+		res, err = tx.Exec(q, login, passwHex)
+		if err == nil {
+			inserted, err = res.RowsAffected()
+		}
 		// если login есть в хранилище, возвращаем соответствующую ошибку "login exist"
 		var pgErr *pgconn.PgError
 		switch {
-		case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
-			err = errors.New("login exist")
+		case err == nil && inserted == 0,
+			errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
+			err = domainerrors.ErrLoginExists
 			log.Printf("insert 1st instruction of transaction StorageCreateNewUser SQL UniqueViolation error : %s", err)
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
 				log.Printf("unable StorageCreateNewUser to rollback: %s", rollbackErr)
@@ -51,7 +61,7 @@ func (ms *StorageSQL) Create(ctx context.Context, login string, passwHex string)
 		var pgErr *pgconn.PgError
 		switch {
 		case errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation:
-			err = errors.New("login exist")
+			err = domainerrors.ErrLoginExists
 			log.Printf("insert 2nd instruction of transaction StorageCreateNewUser SQL UniqueViolation error : %s", err)
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
 				log.Printf("unable StorageCreateNewUser to rollback: %s", rollbackErr)
@@ -73,21 +83,92 @@ func (ms *StorageSQL) Create(ctx context.Context, login string, passwHex string)
 	return err
 }
 
-// проверка наличия нового пользователя в хранилище - авторизация
-func (ms *StorageSQL) CheckAuthorization(ctx context.Context, login string, passwHex string) (err error) {
-	var passwDB string
+// получение хеша пароля пользователя для проверки при авторизации
+func (ms *StorageSQL) PasswordHash(ctx context.Context, login string) (passwHash string, err error) {
 	// создаем текст запроса
 	q := `SELECT password FROM users WHERE login = $1`
 	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
-	err = ms.PostgreSQL.QueryRowContext(ctx, q, login).Scan(&passwDB)
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, login).Scan(&passwHash)
+	// если логина нет в хранилище, возвращаем ту же ошибку, что и при неверном пароле
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrInvalidCredentials
+		log.Printf("select StoragePasswordHash SQL: %s", err)
+		return passwHash, err
+	}
+	if err != nil {
+		log.Printf("select StoragePasswordHash SQL request scan error: %s", err)
+	}
+	return passwHash, err
+}
+
+// обновление хеша пароля пользователя
+func (ms *StorageSQL) UpdatePasswordHash(ctx context.Context, login string, passwHash string) (err error) {
+	// создаем текст запроса
+	q := `UPDATE users SET password = $2 WHERE login = $1`
+	// записываем в хранилице
+	_, err = ms.PostgreSQL.ExecContext(ctx, q, login, passwHash)
 	if err != nil {
-		log.Printf("select StorageAuthorizationCheck SQL request scan error: %s", err)
+		log.Printf("update StorageUpdatePasswordHash SQL request error: %s", err)
+	}
+	return err
+}
+
+// получение роли пользователя
+func (ms *StorageSQL) UserRole(ctx context.Context, login string) (role string, err error) {
+	// создаем текст запроса
+	q := `SELECT role FROM users WHERE login = $1`
+	// делаем запрос в SQL, получаем строку и пишем результат запроса в пременную
+	err = ms.PostgreSQL.QueryRowContext(ctx, q, login).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = domainerrors.ErrLoginNotFound
+		log.Printf("select StorageUserRole SQL: %s", err)
+		return role, err
+	}
+	if err != nil {
+		log.Printf("select StorageUserRole SQL request scan error: %s", err)
+	}
+	return role, err
+}
+
+// установка роли пользователя с отзывом токенов обновления, токены доступа,
+// выданные до смены роли, отклоняются по времени смены роли
+func (ms *StorageSQL) SetUserRole(ctx context.Context, login string, role string) (err error) {
+	// объявляем транзакцию
+	tx, err := ms.PostgreSQL.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error StorageSetUserRole tx.Begin : %s", err)
+		return err
+	}
+	// откат транзакции при ошибке
+	defer tx.Rollback()
+	// создаем текст запроса
+	q := `UPDATE users SET role = $2, role_changed_at = CURRENT_TIMESTAMP WHERE login = $1`
+	// записываем в хранилице
+	res, err := tx.ExecContext(ctx, q, login, role)
+	if err != nil {
+		log.Printf("update StorageSetUserRole SQL request error: %s", err)
 		return err
 	}
-	// сравнение паролей из базы данных и полученного
-	if passwDB != passwHex {
-		err = errors.New("login or password not exist")
-		log.Printf("select StorageAuthorizationCheck SQL: %s", err)
+	// проверяем наличие пользователя
+	updated, err := res.RowsAffected()
+	if err != nil {
+		log.Printf("update StorageSetUserRole RowsAffected error: %s", err)
+		return err
+	}
+	if updated == 0 {
+		err = domainerrors.ErrLoginNotFound
+		log.Printf("update StorageSetUserRole SQL: %s", err)
+		return err
+	}
+	// отзываем токены обновления, новая пара токенов с новой ролью выдается при входе
+	q = `UPDATE refresh_tokens SET revoked = true WHERE login = $1 AND NOT revoked`
+	if _, err = tx.ExecContext(ctx, q, login); err != nil {
+		log.Printf("update StorageSetUserRole refresh tokens SQL request error: %s", err)
+		return err
+	}
+	// сохраняем изменения
+	if err = tx.Commit(); err != nil {
+		log.Printf("error StorageSetUserRole tx.Commit : %s", err)
 	}
 	return err
 }
@@ -0,0 +1,48 @@
+package workerpool
+
+import (
+	"math"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+)
+
+// NewRetryPolicy - конструктор политики повторных опросов из настроек приложения
+func NewRetryPolicy() models.RetryPolicy {
+	return models.RetryPolicy{
+		InitialDelay: settings.RetryInitialDelay,
+		Multiplier:   settings.RetryMultiplier,
+		MaxDelay:     settings.RetryMaxDelay,
+		Jitter:       settings.RetryJitter,
+		MaxAttempts:  settings.RetryMaxAttempts,
+		MaxAge:       settings.RetryMaxAge,
+	}
+}
+
+// NextDelay рассчитывает задержку перед следующей попыткой: экспоненциальный рост от начальной задержки
+// с ограничением максимальной задержкой и случайным отклонением в пределах доли Jitter,
+// random - источник случайных чисел в диапазоне [0, 1), воркеры используют rand.Float64
+func NextDelay(policy models.RetryPolicy, attempts int, random func() float64) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := float64(policy.InitialDelay) * math.Pow(policy.Multiplier, float64(attempts-1))
+	// ограничиваем задержку сверху
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+	// добавляем случайное отклонение, чтобы разнести во времени попытки разных заказов
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (2*random() - 1)
+	}
+	return time.Duration(delay)
+}
+
+// Exhausted проверяет, исчерпан ли на момент now бюджет попыток задачи по количеству или по времени
+func Exhausted(task models.Task, now time.Time) bool {
+	if task.Policy.MaxAttempts > 0 && task.Attempts >= task.Policy.MaxAttempts {
+		return true
+	}
+	return task.Policy.MaxAge > 0 && !task.CreatedAt.IsZero() && now.Sub(task.CreatedAt) > task.Policy.MaxAge
+}
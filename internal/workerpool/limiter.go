@@ -0,0 +1,92 @@
+package workerpool
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter - общий для всех воркеров пула ограничитель запросов к внешнему сервису по алгоритму token bucket
+// с возможностью глобальной паузы по заголовку Retry-After
+type Limiter struct {
+	mu          sync.Mutex
+	rps         float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	now         func() time.Time
+}
+
+// NewLimiter - конструктор ограничителя запросов, rps <= 0 - без ограничения частоты запросов
+func NewLimiter(rps float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rps:    rps,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		now:    time.Now,
+	}
+}
+
+// WithClock задает источник текущего времени для пополнения токенов и паузы, используется в тестах
+func (l *Limiter) WithClock(now func() time.Time) *Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+	l.last = now()
+	return l
+}
+
+// Wait блокирует вызывающую горутину до получения разрешения на запрос или до отмены контекста
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.Reserve()
+		if delay <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Pause приостанавливает запросы всех воркеров на время d
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// продлеваем паузу, только если новая пауза заканчивается позже текущей
+	if until := l.now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// Reserve забирает токен, если он доступен, иначе возвращает время ожидания до следующей проверки
+func (l *Limiter) Reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	// глобальная пауза по Retry-After
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+	// без ограничения частоты
+	if l.rps <= 0 {
+		return 0
+	}
+	// пополняем корзину токенов пропорционально прошедшему времени
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rps)
+	l.last = now
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+	// время до появления следующего токена
+	return time.Duration((1 - l.tokens) / l.rps * float64(time.Second))
+}
@@ -1,9 +1,10 @@
-// пакет группы горутин-обработчиков для задач, с очередью обработки и закрытием группы и обработчиков по сигналу прерывания через contex
+// пакет группы горутин-обработчиков для задач, с очередью обработки в хранилище и закрытием группы и обработчиков по сигналу прерывания через contex
 package workerpool
 
 import (
-	"container/list"
 	"context"
+	"database/sql"
+	"errors"
 	"net/http"
 	"sync"
 	"time"
@@ -16,18 +17,16 @@ import (
 
 // структура пула воркеров
 type Pool struct {
-	TasksQ        *list.List
-	Workers       []*Worker
-	concurrency   int
-	collector     chan models.Task
-	runBackground chan bool
-	task          *models.Task
-	timeout       *time.Ticker
-	mu            sync.Mutex
-	storage       StorageProvider
-	calcSys       string
-	wg            *sync.WaitGroup
-	httprequest   HTTPRequestProvider
+	Workers     []*Worker
+	concurrency int
+	collector   chan models.Task
+	timeout     *time.Ticker
+	storage     StorageProvider
+	calcSys     string
+	wg          *sync.WaitGroup
+	httprequest HTTPRequestProvider
+	policy      models.RetryPolicy
+	limiter     *Limiter
 }
 
 // NewTask - конструктор структуры задач для воркера
@@ -38,10 +37,9 @@ func NewTask(orderNum string, Login string) *models.Task {
 	}
 }
 
-// NewPool инициализирует новый пул с заданными задачами и при заданном параллелизме
-func NewPool(tasks *list.List, concurrency int, timeout *time.Ticker, storage StorageProvider, calcSys string, wg *sync.WaitGroup, httprequest HTTPRequestProvider) *Pool {
+// NewPool инициализирует новый пул с очередью задач в хранилище и при заданном параллелизме
+func NewPool(concurrency int, timeout *time.Ticker, storage StorageProvider, calcSys string, wg *sync.WaitGroup, httprequest HTTPRequestProvider) *Pool {
 	return &Pool{
-		TasksQ:      tasks,
 		concurrency: concurrency,
 		collector:   make(chan models.Task, settings.PipelineLenght),
 		timeout:     timeout,
@@ -49,22 +47,41 @@ func NewPool(tasks *list.List, concurrency int, timeout *time.Ticker, storage St
 		calcSys:     calcSys,
 		wg:          wg,
 		httprequest: httprequest,
+		policy:      NewRetryPolicy(),
+		limiter:     NewLimiter(settings.AccrualRPS, settings.AccrualBurst),
 	}
 }
 
-// AppendTask добавляет задачи в pool
+// AppendTask добавляет задачи в очередь pool в хранилище
 func (p *Pool) AppendTask(login, orderNum string) {
 	// создаем структуру для передачи в очередь пула воркеров
 	task := models.Task{
 		OrderNum: orderNum,
 		Login:    login,
 	}
-	// используем мьютексы для многопоточной работы с очередью
-	// container/list потокоНЕбезопасен
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	// добавлем задачу в конец очереди
-	p.TasksQ.PushBack(task)
+	// создаем контекст и оснащаем его таймаутом
+	ctx, cancel := context.WithTimeout(context.Background(), settings.StorageTimeout)
+	// освобождаем ресурс
+	defer cancel()
+	// добавлем задачу в очередь, при ошибке задача будет восстановлена при следующем запуске
+	err := p.storage.TaskAppend(ctx, task)
+	if err != nil {
+		log.Printf("append task for order %s error: %s", orderNum, err)
+	}
+}
+
+// Requeue ставит задачу по заказу в очередь для немедленного опроса внешнего сервиса
+// с новым бюджетом повторных опросов, в том числе если задача была завершена или удалена из очереди
+func (p *Pool) Requeue(ctx context.Context, login, orderNum string) (err error) {
+	err = p.storage.TaskRequeue(ctx, models.Task{
+		OrderNum: orderNum,
+		Login:    login,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("task for order %s requeued", orderNum)
+	return nil
 }
 
 // RunBackground запускает пул воркеров
@@ -73,7 +90,7 @@ func (p *Pool) RunBackground(ctx context.Context) {
 	// запуск воркеров с каналами получения задач
 	for i := 1; i <= p.concurrency; i++ {
 		// констуруируем воркер
-		worker := NewWorker(p.collector, i, p.timeout, p.storage, p.wg, p.httprequest)
+		worker := NewWorker(p.collector, i, p.timeout, p.storage, p.wg, p.httprequest, p.limiter)
 		// добавляем воркер в слайс воркеров
 		p.Workers = append(p.Workers, worker)
 		// увеличиваем счетчик запущенных горутин
@@ -83,22 +100,35 @@ func (p *Pool) RunBackground(ctx context.Context) {
 	}
 	// передача задач из очереди в каналы воркеров
 	for {
-		select {
+		// получаем задачу из очереди в хранилище с арендой
+		task, err := p.storage.TaskClaim(ctx, settings.TaskLease)
+		switch {
+		// очередь пустая или хранилище недоступно - ждем следующего опроса очереди
+		case err != nil:
+			if !errors.Is(err, sql.ErrNoRows) && ctx.Err() == nil {
+				log.Printf("task claim error: %s", err)
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(settings.QueuePollInterval):
+			}
+		// передаем задачу с политикой повторных опросов в канал воркеров
+		// воркер продлевает аренду перед опросом, время ожидания в канале и в ограничителе запросов
+		// не сокращает аренду на время опроса
+		default:
+			task.Policy = p.policy
+			select {
+			case <-ctx.Done():
+			case p.collector <- task:
+			}
+		}
 		// остановка пула по сигналу контекста
-		case <-ctx.Done():
+		// незавершенные задачи останутся в хранилище и будут получены после истечения аренды
+		if ctx.Err() != nil {
 			log.Print("closing Pool")
 			// уменьшем счетчик запущенных горутин
 			p.wg.Done()
 			return
-		default:
-			// container/list потокоНЕбезопасен
-			p.mu.Lock()
-			// если очередь не пустая, берем из нее задачу и отправляем в канал воркеров
-			if lenQ := p.TasksQ.Len(); lenQ > 0 {
-				// удалем элемент из очереди с получением его значения и приведением его к типу models.Task с делаьнейшей передачей в канал
-				p.collector <- p.TasksQ.Remove(p.TasksQ.Front()).(models.Task)
-			}
-			p.mu.Unlock()
 		}
 	}
 }
@@ -106,8 +136,17 @@ func (p *Pool) RunBackground(ctx context.Context) {
 // StorageProvider интерфейс доступа к хранилищу для методов пула воркеров
 type StorageProvider interface {
 	Update(ctx context.Context, login string, dc models.OrderSatus) (err error)
+	TaskAppend(ctx context.Context, task models.Task) (err error)
+	TaskClaim(ctx context.Context, lease time.Duration) (task models.Task, err error)
+	TaskRenew(ctx context.Context, task models.Task, lease time.Duration) (lockedUntil time.Time, err error)
+	TaskRelease(ctx context.Context, task models.Task) (err error)
+	TaskDelete(ctx context.Context, orderNum string) (err error)
+	TaskExhaust(ctx context.Context, task models.Task) (err error)
+	TaskRequeue(ctx context.Context, task models.Task) (err error)
+	TaskObserve(ctx context.Context, poll models.AccrualPoll) (err error)
 }
 
 type HTTPRequestProvider interface {
 	RequestGet(url string) (rsp *http.Response, err error)
+	RequestPost(orderNum string) (err error)
 }
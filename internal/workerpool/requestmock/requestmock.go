@@ -0,0 +1,27 @@
+package requestmock
+
+import (
+	"net/http"
+)
+
+// имплементация интерфейса HTTPRequestProvider с заданными ответами внешнего сервиса
+type HTTPRequest struct {
+	// ошибка регистрации заказа
+	PostErr error
+	// ответ и ошибка запроса статуса заказа
+	GetResponse *http.Response
+	GetErr      error
+	// количество выполненных запросов
+	Posts int
+	Gets  int
+}
+
+func (mr *HTTPRequest) RequestPost(orderNum string) (err error) {
+	mr.Posts++
+	return mr.PostErr
+}
+
+func (mr *HTTPRequest) RequestGet(orderNum string) (rsp *http.Response, err error) {
+	mr.Gets++
+	return mr.GetResponse, mr.GetErr
+}
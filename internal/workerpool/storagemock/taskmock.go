@@ -0,0 +1,72 @@
+package storagemock
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+)
+
+// имплементация интерфейса StorageProvider с сохранением операций над очередью задач
+type Storage struct {
+	// задачи, возвращенные в очередь с отсрочкой
+	Released []models.Task
+	// заказы, задачи по которым удалены из очереди
+	Deleted []string
+	// задачи с исчерпанным бюджетом попыток
+	Exhausted []models.Task
+	// сохраненные результаты опроса внешнего сервиса
+	Polls []models.AccrualPoll
+	// обновленные статусы заказов
+	Updated []models.OrderSatus
+	// аренда задачи перехвачена другим воркером
+	LeaseLost bool
+	// количество продлений аренды задач
+	Renewals int
+}
+
+func (ms *Storage) Update(ctx context.Context, login string, dc models.OrderSatus) (err error) {
+	ms.Updated = append(ms.Updated, dc)
+	return nil
+}
+
+func (ms *Storage) TaskAppend(ctx context.Context, task models.Task) (err error) {
+	return nil
+}
+
+func (ms *Storage) TaskClaim(ctx context.Context, lease time.Duration) (task models.Task, err error) {
+	return models.Task{}, sql.ErrNoRows
+}
+
+func (ms *Storage) TaskRenew(ctx context.Context, task models.Task, lease time.Duration) (lockedUntil time.Time, err error) {
+	if ms.LeaseLost {
+		return time.Time{}, sql.ErrNoRows
+	}
+	ms.Renewals++
+	return time.Now().Add(lease), nil
+}
+
+func (ms *Storage) TaskRelease(ctx context.Context, task models.Task) (err error) {
+	ms.Released = append(ms.Released, task)
+	return nil
+}
+
+func (ms *Storage) TaskDelete(ctx context.Context, orderNum string) (err error) {
+	ms.Deleted = append(ms.Deleted, orderNum)
+	return nil
+}
+
+func (ms *Storage) TaskExhaust(ctx context.Context, task models.Task) (err error) {
+	ms.Exhausted = append(ms.Exhausted, task)
+	return nil
+}
+
+func (ms *Storage) TaskRequeue(ctx context.Context, task models.Task) (err error) {
+	return nil
+}
+
+func (ms *Storage) TaskObserve(ctx context.Context, poll models.AccrualPoll) (err error) {
+	ms.Polls = append(ms.Polls, poll)
+	return nil
+}
@@ -0,0 +1,102 @@
+package workerpool__test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/workerpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// источник случайных чисел с постоянным значением
+func fixed(v float64) func() float64 {
+	return func() float64 { return v }
+}
+
+func TestNextDelay(t *testing.T) {
+	jittered := models.RetryPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second, Jitter: 0.2}
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name     string
+		policy   models.RetryPolicy
+		attempts int
+		random   float64
+		expected time.Duration
+	}{
+		// определяем все тесты
+		{name: "first attempt - initial delay", policy: policy, attempts: 1, random: 0.5, expected: time.Second},
+		{name: "attempts below one - initial delay", policy: policy, attempts: 0, random: 0.5, expected: time.Second},
+		{name: "exponential growth", policy: policy, attempts: 4, random: 0.5, expected: 8 * time.Second},
+		{name: "delay capped by max delay", policy: policy, attempts: 30, random: 0.5, expected: time.Minute},
+		{name: "jitter lower bound", policy: jittered, attempts: 2, random: 0, expected: 1600 * time.Millisecond},
+		{name: "jitter midpoint", policy: jittered, attempts: 2, random: 0.5, expected: 2 * time.Second},
+		{name: "jitter upper bound", policy: jittered, attempts: 2, random: 0.999, expected: 2399200 * time.Microsecond},
+		{name: "jitter applied after cap", policy: jittered, attempts: 10, random: 0, expected: 8 * time.Second},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, workerpool.NextDelay(tt.policy, tt.attempts, fixed(tt.random)))
+		})
+	}
+}
+
+func TestNextDelay_JitterBounds(t *testing.T) {
+	jittered := models.RetryPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second, Jitter: 0.2}
+	// задержка с отклонением не выходит за пределы ±Jitter от расчетной и не превышает MaxDelay с учетом отклонения
+	for attempts := 1; attempts <= 10; attempts++ {
+		base := workerpool.NextDelay(models.RetryPolicy{InitialDelay: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second}, attempts, fixed(0))
+		for _, random := range []float64{0, 0.25, 0.5, 0.75, 0.999} {
+			delay := workerpool.NextDelay(jittered, attempts, fixed(random))
+			assert.GreaterOrEqual(t, delay, time.Duration(float64(base)*0.8))
+			assert.LessOrEqual(t, delay, time.Duration(float64(base)*1.2))
+			assert.LessOrEqual(t, delay, 12*time.Second)
+		}
+	}
+}
+
+func TestExhausted(t *testing.T) {
+	now := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name     string
+		task     models.Task
+		expected bool
+	}{
+		// определяем все тесты
+		{
+			name:     "budget left",
+			task:     models.Task{Attempts: 9, CreatedAt: now.Add(-time.Hour + time.Second), Policy: policy},
+			expected: false,
+		},
+		{
+			name:     "attempts budget exhausted",
+			task:     models.Task{Attempts: 10, CreatedAt: now, Policy: policy},
+			expected: true,
+		},
+		{
+			name:     "time budget exhausted",
+			task:     models.Task{Attempts: 1, CreatedAt: now.Add(-time.Hour - time.Second), Policy: policy},
+			expected: true,
+		},
+		{
+			name:     "creation time unknown - only attempts counted",
+			task:     models.Task{Attempts: 1, Policy: policy},
+			expected: false,
+		},
+		{
+			name:     "no limits in policy",
+			task:     models.Task{Attempts: 1000, CreatedAt: now.Add(-24 * time.Hour)},
+			expected: false,
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, workerpool.Exhausted(tt.task, now))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package workerpool__test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/workerpool"
+	"github.com/stretchr/testify/assert"
+)
+
+// шаг сценария ограничителя запросов
+type limiterStep struct {
+	// сдвиг часов перед шагом
+	advance time.Duration
+	// пауза по Retry-After перед запросом токена
+	pause time.Duration
+	// ожидаемое время ожидания, 0 - токен получен
+	wantDelay time.Duration
+}
+
+func TestLimiter_Reserve(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и сценарий
+	tests := []struct {
+		name  string
+		rps   float64
+		burst int
+		steps []limiterStep
+	}{
+		// определяем все тесты
+		{
+			name: "no rate limit",
+			rps:  0, burst: 1,
+			steps: []limiterStep{{}, {}, {}},
+		},
+		{
+			name: "requests spaced by 1/rps",
+			rps:  2, burst: 1,
+			steps: []limiterStep{
+				{wantDelay: 0},
+				{wantDelay: 500 * time.Millisecond},
+				{advance: 200 * time.Millisecond, wantDelay: 300 * time.Millisecond},
+				{advance: 300 * time.Millisecond, wantDelay: 0},
+				{wantDelay: 500 * time.Millisecond},
+			},
+		},
+		{
+			name: "burst allows requests in a row",
+			rps:  1, burst: 3,
+			steps: []limiterStep{
+				{}, {}, {},
+				{wantDelay: time.Second},
+				// корзина пополняется не больше burst
+				{advance: 10 * time.Second}, {}, {},
+				{wantDelay: time.Second},
+			},
+		},
+		{
+			name: "Retry-After pause blocks requests",
+			rps:  0, burst: 1,
+			steps: []limiterStep{
+				{pause: 5 * time.Second, wantDelay: 5 * time.Second},
+				{advance: 2 * time.Second, wantDelay: 3 * time.Second},
+				// более короткая пауза не сокращает текущую
+				{pause: time.Second, wantDelay: 3 * time.Second},
+				{advance: 3 * time.Second, wantDelay: 0},
+			},
+		},
+		{
+			name: "Retry-After pause keeps tokens refill",
+			rps:  1, burst: 1,
+			steps: []limiterStep{
+				{},
+				{pause: 10 * time.Second, wantDelay: 10 * time.Second},
+				{advance: 10 * time.Second, wantDelay: 0},
+				{wantDelay: time.Second},
+			},
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+			l := workerpool.NewLimiter(tt.rps, tt.burst).WithClock(func() time.Time { return now })
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				if step.pause > 0 {
+					l.Pause(step.pause)
+				}
+				assert.Equal(t, step.wantDelay, l.Reserve(), "step %d", i)
+			}
+		})
+	}
+}
+
+func TestLimiter_WaitCanceled(t *testing.T) {
+	now := time.Date(2022, time.November, 10, 23, 0, 0, 0, time.UTC)
+	l := workerpool.NewLimiter(0, 1).WithClock(func() time.Time { return now })
+	l.Pause(time.Hour)
+	// ожидание прерывается отменой контекста
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, l.Wait(ctx), context.Canceled)
+}
@@ -0,0 +1,244 @@
+// тесты воркеров опроса системы расчета баллов лояльности
+package workerpool__test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/workerpool"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/workerpool/requestmock"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/workerpool/storagemock"
+	"github.com/stretchr/testify/assert"
+)
+
+// политика повторных опросов без случайной составляющей
+var policy = models.RetryPolicy{
+	InitialDelay: time.Second,
+	Multiplier:   2,
+	MaxDelay:     time.Minute,
+	MaxAttempts:  10,
+	MaxAge:       time.Hour,
+}
+
+func TestWorker_JobPost(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name               string
+		postErr            error
+		expectedAttempts   int
+		expectedRegistered bool
+		expectedDelay      time.Duration
+		expectedGets       int
+		expectedRenewals   int
+		expectedPaused     bool
+	}{
+		// определяем все тесты
+		{
+			name:               "Positive test for order registration - status requested",
+			postErr:            nil,
+			expectedAttempts:   1,
+			expectedRegistered: true,
+			expectedGets:       1,
+			expectedRenewals:   2,
+		},
+		{
+			name:             "Negative test for order registration - 429 with Retry-After, limiter paused, attempt not counted",
+			postErr:          &httprequest.RetryAfterError{RetryAfter: 2 * time.Second},
+			expectedAttempts: 0,
+			expectedDelay:    2 * time.Second,
+			expectedRenewals: 1,
+			expectedPaused:   true,
+		},
+		{
+			name:             "Negative test for order registration - 429 without Retry-After, retry by policy",
+			postErr:          &httprequest.RetryAfterError{},
+			expectedAttempts: 1,
+			expectedDelay:    time.Second,
+			expectedRenewals: 1,
+		},
+		{
+			name:             "Negative test for order registration - 400 status, order stays unregistered",
+			postErr:          errors.New("http POST request has wrong status: 400 Bad Request"),
+			expectedAttempts: 1,
+			expectedDelay:    time.Second,
+			expectedRenewals: 1,
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &storagemock.Storage{}
+			request := &requestmock.HTTPRequest{
+				PostErr: tt.postErr,
+				GetResponse: &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader(`{"order":"2377225624","status":"PROCESSING"}`)),
+				},
+			}
+			limiter := workerpool.NewLimiter(0, 1)
+			worker := workerpool.NewWorker(make(chan models.Task), 1, time.NewTicker(time.Second), storage, &sync.WaitGroup{}, request, limiter)
+			start := time.Now()
+			worker.Job(context.Background(), models.Task{OrderNum: "2377225624", Login: "dimma", Policy: policy})
+			// задача возвращена в очередь с ожидаемой отсрочкой
+			if assert.Len(t, storage.Released, 1) {
+				task := storage.Released[0]
+				assert.Equal(t, tt.expectedAttempts, task.Attempts)
+				assert.Equal(t, tt.expectedRegistered, task.Registered)
+				if tt.expectedDelay > 0 {
+					assert.WithinDuration(t, start.Add(tt.expectedDelay), task.NextAttempt, time.Second/2)
+				}
+			}
+			assert.Empty(t, storage.Deleted)
+			assert.Equal(t, 1, request.Posts)
+			assert.Equal(t, tt.expectedGets, request.Gets)
+			// аренда продлевается перед каждым запросом к сервису
+			assert.Equal(t, tt.expectedRenewals, storage.Renewals)
+			// при паузе ограничителя следующий запрос к сервису не разрешается до истечения Retry-After
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			assert.Equal(t, tt.expectedPaused, limiter.Wait(ctx) != nil)
+		})
+	}
+}
+
+func TestWorker_JobGetBodyLimit(t *testing.T) {
+	storage := &storagemock.Storage{}
+	// ответ внешнего сервиса больше допустимого размера читается только до settings.PollResponseMaxBytes
+	request := &requestmock.HTTPRequest{
+		GetResponse: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(strings.Repeat("a", int(settings.PollResponseMaxBytes)*4))),
+		},
+	}
+	worker := workerpool.NewWorker(make(chan models.Task), 1, time.NewTicker(time.Second), storage, &sync.WaitGroup{}, request, workerpool.NewLimiter(0, 1))
+	worker.Job(context.Background(), models.Task{OrderNum: "2377225624", Login: "dimma", Registered: true, Policy: policy})
+	if assert.Len(t, storage.Polls, 1) {
+		assert.Len(t, storage.Polls[0].Response, int(settings.PollResponseMaxBytes))
+	}
+	// некорректный ответ - задача возвращается в очередь
+	assert.Len(t, storage.Released, 1)
+}
+
+func TestWorker_JobBudgetExhausted(t *testing.T) {
+	storage := &storagemock.Storage{}
+	request := &requestmock.HTTPRequest{
+		GetResponse: &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader(`{"order":"2377225624","status":"PROCESSING"}`)),
+		},
+	}
+	worker := workerpool.NewWorker(make(chan models.Task), 1, time.NewTicker(time.Second), storage, &sync.WaitGroup{}, request, workerpool.NewLimiter(0, 1))
+	// последняя попытка бюджета с нефинальным статусом - задача отмечается исчерпанной и остается в очереди
+	worker.Job(context.Background(), models.Task{OrderNum: "2377225624", Login: "dimma", Registered: true, Attempts: policy.MaxAttempts - 1, Policy: policy})
+	if assert.Len(t, storage.Exhausted, 1) {
+		assert.Equal(t, policy.MaxAttempts, storage.Exhausted[0].Attempts)
+	}
+	assert.Empty(t, storage.Deleted)
+	assert.Empty(t, storage.Released)
+	assert.Len(t, storage.Updated, 1)
+}
+
+func TestWorker_JobLeaseLost(t *testing.T) {
+	// аренда задачи истекла во время ожидания и задача получена другим воркером
+	storage := &storagemock.Storage{LeaseLost: true}
+	request := &requestmock.HTTPRequest{}
+	worker := workerpool.NewWorker(make(chan models.Task), 1, time.NewTicker(time.Second), storage, &sync.WaitGroup{}, request, workerpool.NewLimiter(0, 1))
+	worker.Job(context.Background(), models.Task{OrderNum: "2377225624", Login: "dimma", Policy: policy})
+	// задача пропускается без запросов к сервису и без изменения очереди
+	assert.Zero(t, request.Posts)
+	assert.Zero(t, request.Gets)
+	assert.Empty(t, storage.Released)
+	assert.Empty(t, storage.Polls)
+}
+
+func TestWorker_JobGetTooManyRequests(t *testing.T) {
+	// определяем структуру теста
+	// создаём массив тестов: имя и желаемый результат
+	tests := []struct {
+		name             string
+		retryAfter       string
+		attempts         int
+		expectedAttempts int
+		expectedDelay    time.Duration
+		expectedPaused   bool
+		expectedExhaust  bool
+	}{
+		// определяем все тесты
+		{
+			name:             "Positive test for 429 status - Retry-After, limiter paused, attempt not counted",
+			retryAfter:       "2",
+			expectedAttempts: 0,
+			expectedDelay:    2 * time.Second,
+			expectedPaused:   true,
+		},
+		{
+			name:             "Negative test for 429 status - no Retry-After, attempt counted, retry by policy",
+			retryAfter:       "",
+			expectedAttempts: 1,
+			expectedDelay:    time.Second,
+		},
+		{
+			name:             "Negative test for 429 status - malformed Retry-After, attempt counted, retry by policy",
+			retryAfter:       "soon",
+			expectedAttempts: 1,
+			expectedDelay:    time.Second,
+		},
+		{
+			name:             "Negative test for 429 status - no Retry-After exhausts retry budget",
+			retryAfter:       "",
+			attempts:         policy.MaxAttempts - 1,
+			expectedAttempts: policy.MaxAttempts,
+			expectedExhaust:  true,
+		},
+	}
+	for _, tt := range tests {
+		// запускаем каждый тест
+		t.Run(tt.name, func(t *testing.T) {
+			storage := &storagemock.Storage{}
+			header := http.Header{}
+			if tt.retryAfter != "" {
+				header.Set("Retry-After", tt.retryAfter)
+			}
+			request := &requestmock.HTTPRequest{
+				GetResponse: &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Header:     header,
+					Body:       io.NopCloser(strings.NewReader("")),
+				},
+			}
+			limiter := workerpool.NewLimiter(0, 1)
+			worker := workerpool.NewWorker(make(chan models.Task), 1, time.NewTicker(time.Second), storage, &sync.WaitGroup{}, request, limiter)
+			start := time.Now()
+			worker.Job(context.Background(), models.Task{OrderNum: "2377225624", Login: "dimma", Registered: true, Attempts: tt.attempts, Policy: policy})
+			if tt.expectedExhaust {
+				// бюджет попыток исчерпан, задача не возвращается в очередь
+				assert.Empty(t, storage.Released)
+				if assert.Len(t, storage.Exhausted, 1) {
+					assert.Equal(t, tt.expectedAttempts, storage.Exhausted[0].Attempts)
+				}
+			} else if assert.Len(t, storage.Released, 1) {
+				// задача возвращена в очередь с ожидаемой отсрочкой
+				task := storage.Released[0]
+				assert.Equal(t, tt.expectedAttempts, task.Attempts)
+				assert.WithinDuration(t, start.Add(tt.expectedDelay), task.NextAttempt, time.Second/2)
+			}
+			// при паузе ограничителя следующий запрос к сервису не разрешается до истечения Retry-After
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			assert.Equal(t, tt.expectedPaused, limiter.Wait(ctx) != nil)
+		})
+	}
+}
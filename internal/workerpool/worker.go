@@ -2,13 +2,20 @@ package workerpool
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/httprequest"
 	"github.com/dimsonson/go-yandex-diploma-tpl/internal/models"
+	"github.com/dimsonson/go-yandex-diploma-tpl/internal/settings"
 	"github.com/rs/zerolog/log"
 )
 
@@ -21,10 +28,11 @@ type Worker struct {
 	storage     StorageProvider
 	wg          *sync.WaitGroup
 	httprequest HTTPRequestProvider
+	limiter     *Limiter
 }
 
 // NewWorker - конструктор экземпляра воркера
-func NewWorker(taskChan chan models.Task, ID int, timeout *time.Ticker, storage StorageProvider, wg *sync.WaitGroup, httprequest HTTPRequestProvider) *Worker {
+func NewWorker(taskChan chan models.Task, ID int, timeout *time.Ticker, storage StorageProvider, wg *sync.WaitGroup, httprequest HTTPRequestProvider, limiter *Limiter) *Worker {
 	return &Worker{
 		ID:          ID,
 		taskChan:    taskChan,
@@ -33,6 +41,7 @@ func NewWorker(taskChan chan models.Task, ID int, timeout *time.Ticker, storage
 		storage:     storage,
 		wg:          wg,
 		httprequest: httprequest,
+		limiter:     limiter,
 	}
 }
 
@@ -62,56 +71,198 @@ func (wr *Worker) StartBackground(ctx context.Context) {
 	}
 }
 
-// Job - метод выполнения задачи для воркера
+// Job - метод выполнения задачи для воркера, один опрос внешнего сервиса на одно получение задачи из очереди
+// при нефинальном статусе или ошибке задача возвращается в очередь с экспоненциальной отсрочкой
 func (wr *Worker) Job(ctx context.Context, task models.Task) {
-	for {
-		// отпарвляем запрос в внешний сервис на получения обновленных данных по заказу
-		rGet, err := wr.httprequest.RequestGet(task.OrderNum)
-		if err != nil {
-			log.Printf("gorutine http Get error :%s", err)
+	// ожидаем разрешения общего для пула ограничителя запросов и продлеваем аренду задачи,
+	// при остановке задача останется в очереди
+	if err := wr.acquire(ctx, &task); err != nil {
+		return
+	}
+	// учитываем попытку опроса
+	task.Attempts++
+	// регистрируем заказ в системе расчета баллов, если заказ был принят в период недоступности сервиса
+	if !task.Registered {
+		err := wr.httprequest.RequestPost(task.OrderNum)
+		// внешний сервис недоступен по автоматическому выключателю, откладываем задачу без учета попытки
+		if errors.Is(err, httprequest.ErrCircuitOpen) {
+			task.Attempts--
+			wr.observe(ctx, task, 0, nil, err)
+			wr.release(ctx, task, settings.BreakerCoolDown)
 			return
 		}
-		// завершаем задачу, если ордера нет в системе расчета баллов лояльности или заказ уже рассчитан
-		if rGet.StatusCode == http.StatusNoContent || rGet.StatusCode == http.StatusNotFound || rGet.StatusCode == http.StatusConflict {
-			log.Printf("status code %v recieved from extenal calculation service", rGet.StatusCode)
+		// внешний сервис ограничил частоту запросов, приостанавливаем запросы всех воркеров
+		// и откладываем задачу на значение в Retry-After без учета попытки
+		var retryAfterErr *httprequest.RetryAfterError
+		if errors.As(err, &retryAfterErr) && retryAfterErr.RetryAfter > 0 {
+			task.Attempts--
+			wr.observe(ctx, task, http.StatusTooManyRequests, nil, err)
+			wr.limiter.Pause(retryAfterErr.RetryAfter)
+			wr.release(ctx, task, retryAfterErr.RetryAfter)
 			return
 		}
-		// логгируем полученный статус код ответа внешнего сервиса
-		log.Printf("http status code %v recieved from extenal calculation service", rGet.StatusCode)
-		// выполняем дальше, если 200 код ответа
-		if rGet.StatusCode == http.StatusOK {
-			// десериализация тела ответа системы
-			dc := models.OrderSatus{}
-			err = json.NewDecoder(rGet.Body).Decode(&dc)
-			if err != nil {
-				log.Printf("unmarshal error Worker Job gorutine: %s", err)
-				return
-			}
-			// обновляем статус ордера в хранилище
-			err = wr.storage.Update(ctx, task.Login, dc)
-			if err != nil {
-				log.Printf("storage.Update Worker Job error :%s", err)
-				return
-			}
-			// логируем обновление в хранилище
-			log.Printf("login %s update order %s status to %s with accrual %v", task.Login, dc.Order, dc.Status, dc.Accrual)
-			// останавливаем задачу, если получен финальный стаус
-			if dc.Status == "INVALID" || dc.Status == "PROCESSED" {
-				log.Printf("order %s has updated status to %s", dc.Order, dc.Status)
-				return
-			}
+		if err != nil {
+			log.Printf("gorutine http Post error :%s", err)
+			wr.observe(ctx, task, 0, nil, err)
+			wr.retry(ctx, task)
+			return
 		}
-		// если приходит 429 код ответа, делаем паузу на значение в Retry-After
-		if rGet.StatusCode == http.StatusTooManyRequests {
-			timeout, err := strconv.Atoi(rGet.Header.Get("Retry-After"))
-			if err != nil {
-				log.Printf("error converting Retry-After to int:%s", err)
-				return
+		task.Registered = true
+		log.Printf("order %s registered in extenal calculation service", task.OrderNum)
+		// ожидаем разрешения ограничителя для запроса статуса, при остановке сохраняем отметку о регистрации
+		if err := wr.acquire(ctx, &task); err != nil {
+			if ctx.Err() != nil {
+				wr.release(context.Background(), task, 0)
 			}
-			// делаем паузу в соотвествии с Retry-After
-			<-time.After(time.Duration(timeout) * time.Second)
+			return
+		}
+	}
+	// отпарвляем запрос в внешний сервис на получения обновленных данных по заказу
+	rGet, err := wr.httprequest.RequestGet(task.OrderNum)
+	// внешний сервис недоступен по автоматическому выключателю, откладываем задачу без учета попытки
+	if errors.Is(err, httprequest.ErrCircuitOpen) {
+		task.Attempts--
+		wr.observe(ctx, task, 0, nil, err)
+		wr.release(ctx, task, settings.BreakerCoolDown)
+		return
+	}
+	if err != nil {
+		log.Printf("gorutine http Get error :%s", err)
+		wr.observe(ctx, task, 0, nil, err)
+		wr.retry(ctx, task)
+		return
+	}
+	// закрываем ресурс
+	defer rGet.Body.Close()
+	// логгируем полученный статус код ответа внешнего сервиса
+	log.Printf("http status code %v recieved from extenal calculation service", rGet.StatusCode)
+	// читаем тело ответа и сохраняем результат опроса для диагностики
+	body, err := io.ReadAll(io.LimitReader(rGet.Body, settings.PollResponseMaxBytes))
+	wr.observe(ctx, task, rGet.StatusCode, body, err)
+	if err != nil {
+		log.Printf("read body error Worker Job gorutine: %s", err)
+		wr.retry(ctx, task)
+		return
+	}
+	switch rGet.StatusCode {
+	// завершаем задачу, если ордера нет в системе расчета баллов лояльности или заказ уже рассчитан
+	case http.StatusNoContent, http.StatusNotFound, http.StatusConflict:
+		wr.complete(ctx, task)
+	// выполняем дальше, если 200 код ответа
+	case http.StatusOK:
+		// десериализация тела ответа системы
+		dc := models.OrderSatus{}
+		err = json.Unmarshal(body, &dc)
+		if err != nil {
+			log.Printf("unmarshal error Worker Job gorutine: %s", err)
+			wr.retry(ctx, task)
+			return
+		}
+		// обновляем статус ордера в хранилище
+		err = wr.storage.Update(ctx, task.Login, dc)
+		if err != nil {
+			log.Printf("storage.Update Worker Job error :%s", err)
+			wr.retry(ctx, task)
+			return
+		}
+		// логируем обновление в хранилище
+		log.Printf("login %s update order %s status to %s with accrual %v", task.Login, dc.Order, dc.Status, dc.Accrual)
+		// завершаем задачу, если получен финальный стаус
+		if dc.Status == "INVALID" || dc.Status == "PROCESSED" {
+			log.Printf("order %s has updated status to %s", dc.Order, dc.Status)
+			wr.complete(ctx, task)
+			return
+		}
+		// возвращаем задачу в очередь до следующего опроса
+		wr.retry(ctx, task)
+	// если приходит 429 код ответа, приостанавливаем запросы всех воркеров и откладываем задачу
+	// на значение в Retry-After без учета попытки
+	// без корректного Retry-After попытка учитывается и задача откладывается по политике повторных опросов,
+	// чтобы постоянные ответы 429 исчерпывали бюджет попыток
+	case http.StatusTooManyRequests:
+		timeout, err := strconv.Atoi(rGet.Header.Get("Retry-After"))
+		if err != nil || timeout <= 0 {
+			log.Printf("error converting Retry-After to int: %q", rGet.Header.Get("Retry-After"))
+			wr.retry(ctx, task)
+			return
+		}
+		task.Attempts--
+		wr.limiter.Pause(time.Duration(timeout) * time.Second)
+		wr.release(ctx, task, time.Duration(timeout)*time.Second)
+	// иные коды ответа - возвращаем задачу в очередь до следующего опроса
+	default:
+		wr.retry(ctx, task)
+	}
+}
+
+// acquire ожидает разрешения ограничителя запросов и продлевает аренду задачи на settings.TaskLease от начала опроса,
+// если аренда истекла во время ожидания и задача получена другим воркером, задача пропускается
+func (wr *Worker) acquire(ctx context.Context, task *models.Task) (err error) {
+	if err = wr.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	lockedUntil, err := wr.storage.TaskRenew(ctx, *task, settings.TaskLease)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("order %s lease expired before poll, task skipped by Worker %d", task.OrderNum, wr.ID)
+		return err
+	}
+	if err != nil {
+		log.Printf("storage.TaskRenew Worker Job error :%s", err)
+		return err
+	}
+	task.LockedUntil = lockedUntil
+	return nil
+}
+
+// complete удаляет выполненную задачу из очереди
+func (wr *Worker) complete(ctx context.Context, task models.Task) {
+	err := wr.storage.TaskDelete(ctx, task.OrderNum)
+	if err != nil {
+		log.Printf("storage.TaskDelete Worker Job error :%s", err)
+	}
+}
+
+// retry возвращает задачу в очередь с задержкой по политике повторных опросов
+// или отмечает задачу исчерпанной, если бюджет попыток исчерпан
+func (wr *Worker) retry(ctx context.Context, task models.Task) {
+	if Exhausted(task, time.Now()) {
+		log.Printf("order %s retry budget exhausted after %d attempts", task.OrderNum, task.Attempts)
+		if err := wr.storage.TaskExhaust(ctx, task); err != nil {
+			log.Printf("storage.TaskExhaust Worker Job error :%s", err)
 		}
-		// закрываем ресурс
-		defer rGet.Body.Close()
+		return
+	}
+	wr.release(ctx, task, NextDelay(task.Policy, task.Attempts, rand.Float64))
+}
+
+// release возвращает задачу в очередь с отсрочкой следующей обработки
+func (wr *Worker) release(ctx context.Context, task models.Task, delay time.Duration) {
+	task.NextAttempt = time.Now().Add(delay)
+	err := wr.storage.TaskRelease(ctx, task)
+	if err != nil {
+		log.Printf("storage.TaskRelease Worker Job error :%s", err)
+		return
+	}
+	log.Printf("order %s attempt %d, next attempt at %s", task.OrderNum, task.Attempts, task.NextAttempt.Format(time.RFC3339))
+}
+
+// observe сохраняет результат опроса внешнего сервиса по заказу для диагностики,
+// тело ответа обрезается до settings.PollResponseMaxBytes
+func (wr *Worker) observe(ctx context.Context, task models.Task, statusCode int, body []byte, err error) {
+	if int64(len(body)) > settings.PollResponseMaxBytes {
+		body = body[:settings.PollResponseMaxBytes]
+	}
+	poll := models.AccrualPoll{
+		OrderNum:   task.OrderNum,
+		Attempt:    task.Attempts,
+		StatusCode: statusCode,
+		Response:   strings.ToValidUTF8(string(body), ""),
+		PolledAt:   time.Now(),
+	}
+	if err != nil {
+		poll.Error = err.Error()
+	}
+	if err := wr.storage.TaskObserve(ctx, poll); err != nil {
+		log.Printf("storage.TaskObserve Worker Job error :%s", err)
 	}
 }